@@ -0,0 +1,177 @@
+// Command dbcopy moves a SQLite deployment to Postgres. It migrates both databases to the
+// latest schema and copies every row, keeping the original IDs, in one Postgres transaction.
+// The Postgres side is read from the usual DB_* variables (or CONFIG_FILE) and must be empty,
+// so run it before the server has started against Postgres and seeded it.
+//
+//	go run ./cmd/dbcopy                       # copies the file at DB_PATH
+//	go run ./cmd/dbcopy -from data/courts.db
+//
+// Stop the server first; rows written to SQLite during the copy would be lost.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	_ "github.com/lib/pq"
+	"main.go/config"
+	"main.go/migrations"
+	"main.go/sqlitedb"
+)
+
+// table to copy and its SERIAL column, if any. Parents come before the tables that reference
+// them. schema_migrations is written by the migrations and rate_limit_buckets is Postgres only.
+type table struct {
+	name   string
+	serial string
+}
+
+var tables = []table{
+	{"users", "UserID"},
+	{"courts", "CourtID"},
+	{"bookings", "BookingID"},
+	{"notification_preferences", ""},
+	{"notifications", "NotificationID"},
+	{"push_subscriptions", "SubscriptionID"},
+	{"price_rates", "RateID"},
+	{"price_bands", "BandID"},
+	{"price_tiers", ""},
+	{"payments", "PaymentID"},
+	{"ledger_accounts", "AccountID"},
+	{"ledger_transactions", "TxID"},
+	{"ledger_entries", "EntryID"},
+	{"vouchers", "VoucherID"},
+	{"voucher_redemptions", "RedemptionID"},
+	{"auth_sessions", ""},
+	{"refresh_tokens", "TokenID"},
+	{"password_resets", "ResetID"},
+	{"email_verification_sends", ""},
+	{"login_attempts", "AttemptID"},
+	{"login_lockouts", "LockoutID"},
+	{"oidc_logins", ""},
+	{"user_identities", ""},
+	{"user_mfa", ""},
+	{"mfa_recovery_codes", "CodeID"},
+	{"mfa_challenges", ""},
+	{"account_deletions", "RequestID"},
+	{"policy_documents", "DocumentID"},
+	{"policy_acceptances", ""},
+	{"role_permissions", ""},
+}
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	from := flag.String("from", cfg.Database.Path, "SQLite database file to copy")
+	flag.Parse()
+
+	src, err := sqlitedb.Open(*from)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := sql.Open("postgres", cfg.Database.DSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dst.Close()
+
+	for _, db := range []*sql.DB{src, dst} {
+		if err := db.Ping(); err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		if _, err := migrations.Up(db); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := copyAll(src, dst); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func copyAll(src, dst *sql.DB) error {
+	tx, err := dst.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range tables {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM " + t.name + ")").Scan(&exists); err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		if exists {
+			return fmt.Errorf("target table %s is not empty, refusing to copy", t.name)
+		}
+	}
+
+	for _, t := range tables {
+		n, err := copyTable(src, tx, t)
+		if err != nil {
+			return fmt.Errorf("copying %s: %v", t.name, err)
+		}
+		fmt.Printf("%-26s %d rows\n", t.name, n)
+	}
+
+	return tx.Commit()
+}
+
+func copyTable(src *sql.DB, tx *sql.Tx, t table) (int, error) {
+	rows, err := src.Query("SELECT * FROM " + t.name)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		t.name, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	n := 0
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+
+	if t.serial != "" {
+		// Move the sequence past the copied IDs so new rows don't collide with them
+		_, err = tx.Exec(fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL) FROM %s",
+			t.name, strings.ToLower(t.serial), t.serial, t.serial, t.name))
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
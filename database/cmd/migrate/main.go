@@ -0,0 +1,100 @@
+// Command migrate applies or rolls back schema migrations by hand. It reads the database
+// settings the same way as the server (CONFIG_FILE and DB_* variables), so DB_DRIVER=sqlite
+// migrates the SQLite file at DB_PATH.
+//
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate up        # apply everything pending
+//	go run ./cmd/migrate up 3      # apply up to and including version 3
+//	go run ./cmd/migrate down      # roll back the latest migration
+//	go run ./cmd/migrate down 2    # roll back the latest two
+//
+// The server applies pending migrations at startup, so "up" is only needed to migrate ahead
+// of a deploy.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	_ "github.com/lib/pq"
+	"main.go/config"
+	"main.go/migrations"
+	"main.go/sqlitedb"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+	n := 0
+	if len(os.Args) > 2 {
+		v, err := strconv.Atoi(os.Args[2])
+		if err != nil || v < 1 {
+			usage()
+		}
+		n = v
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var db *sql.DB
+	if cfg.Database.Driver == "sqlite" {
+		db, err = sqlitedb.Open(cfg.Database.Path)
+	} else {
+		db, err = sql.Open("postgres", cfg.Database.DSN())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrations.UpTo(db, n)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migrations applied\n", applied)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		rolledBack, err := migrations.Down(db, n)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migrations rolled back\n", rolledBack)
+	case "status":
+		statuses, err := migrations.List(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			name := s.Name
+			if s.Unknown {
+				name = "(unknown to this build)"
+			}
+			fmt.Printf("%04d  %-30s  %s\n", s.Version, name, state)
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate up [version] | down [steps] | status")
+	os.Exit(2)
+}
@@ -0,0 +1,236 @@
+// Command mockidp is a minimal OpenID Connect provider for trying out SSO login locally.
+// It signs in whoever fills in the form, so never expose it outside a development machine.
+//
+//	go run ./cmd/mockidp
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=court-booking \
+//	OIDC_REDIRECT_URL=http://localhost:8080/api/auth/oidc/callback go run .
+//
+// then open http://localhost:8080/api/auth/oidc/login in a browser.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-idp"
+
+var (
+	issuer     = "http://localhost:9000"
+	signingKey *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes = map[string]authCode{}
+)
+
+// authCode is an issued authorization code waiting to be redeemed
+type authCode struct {
+	ClientID    string
+	RedirectURI string
+	Challenge   string
+	Nonce       string
+	Claims      jwt.MapClaims
+	Expires     time.Time
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><title>Mock university sign-in</title></head>
+<body style="font-family: sans-serif; max-width: 28em; margin: 3em auto">
+<h2>Mock university sign-in</h2>
+<form method="post">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}<p><label>Email<br><input name="email" value="student@uni.ac.th" size="40"></label></p>
+<p><label>Student ID<br><input name="student_id" value="6510000001"></label></p>
+<p><label>First name<br><input name="given_name" value="Test"></label></p>
+<p><label>Last name<br><input name="family_name" value="Student"></label></p>
+<p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+<p><button type="submit">Sign in</button> <button type="submit" name="deny" value="1">Deny</button></p>
+</form></body></html>`))
+
+func main() {
+	addr := ":9000"
+	if v := os.Getenv("MOCK_IDP_ADDR"); v != "" {
+		addr = v
+	}
+	if v := os.Getenv("MOCK_IDP_ISSUER"); v != "" {
+		issuer = v
+	}
+
+	var err error
+	signingKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", handleDiscovery)
+	http.HandleFunc("/authorize", handleAuthorize)
+	http.HandleFunc("/token", handleToken)
+	http.HandleFunc("/jwks", handleJWKS)
+
+	log.Printf("Mock IdP listening on %s (issuer %s)", addr, issuer)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
+
+func handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// GET shows the sign-in form; POST issues a code and redirects back to the client
+func handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := url.Values{}
+	for _, k := range []string{"client_id", "redirect_uri", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		q.Set(k, r.Form.Get(k))
+	}
+	if q.Get("client_id") == "" || q.Get("redirect_uri") == "" || q.Get("code_challenge") == "" {
+		http.Error(w, "client_id, redirect_uri and code_challenge are required", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "only S256 PKCE is supported", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		loginPage.Execute(w, map[string]interface{}{"Params": q})
+		return
+	}
+
+	back, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := back.Query()
+	params.Set("state", q.Get("state"))
+
+	if r.Form.Get("deny") != "" {
+		params.Set("error", "access_denied")
+	} else {
+		email := r.Form.Get("email")
+		sum := sha256.Sum256([]byte(email))
+		code := randomString()
+
+		mu.Lock()
+		codes[code] = authCode{
+			ClientID:    q.Get("client_id"),
+			RedirectURI: q.Get("redirect_uri"),
+			Challenge:   q.Get("code_challenge"),
+			Nonce:       q.Get("nonce"),
+			Claims: jwt.MapClaims{
+				"sub":                hex.EncodeToString(sum[:8]),
+				"email":              email,
+				"email_verified":     r.Form.Get("email_verified") == "true",
+				"student_id":         r.Form.Get("student_id"),
+				"given_name":         r.Form.Get("given_name"),
+				"family_name":        r.Form.Get("family_name"),
+				"preferred_username": r.Form.Get("student_id"),
+			},
+			Expires: time.Now().Add(time.Minute),
+		}
+		mu.Unlock()
+		params.Set("code", code)
+	}
+
+	back.RawQuery = params.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID := r.Form.Get("client_id")
+	if id, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(id)
+	}
+
+	mu.Lock()
+	code, ok := codes[r.Form.Get("code")]
+	delete(codes, r.Form.Get("code"))
+	mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || time.Now().After(code.Expires) || code.ClientID != clientID || code.RedirectURI != r.Form.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != code.Challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := code.Claims
+	claims["iss"] = issuer
+	claims["aud"] = clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	claims["nonce"] = code.Nonce
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(signingKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := signingKey.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,528 @@
+// Package config loads the server configuration: built-in defaults, then an optional YAML or
+// TOML file (CONFIG_FILE), then environment variables. The result is validated before use and
+// production mode refuses to start with missing or development secrets.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // venue time zones work in images without zoneinfo
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Environments
+const (
+	Development = "development"
+	Staging     = "staging"
+	Production  = "production"
+)
+
+// Development defaults that must not be used in production
+const (
+	devJWTSecret     = "your-secret-key-change-in-production"
+	devDBPassword    = "courts_password"
+	devAdminPassword = "012345"
+)
+
+type Config struct {
+	Env           string              `yaml:"env" toml:"env"`
+	Server        ServerConfig        `yaml:"server" toml:"server"`
+	Database      DatabaseConfig      `yaml:"database" toml:"database"`
+	Auth          AuthConfig          `yaml:"auth" toml:"auth"`
+	Admin         AdminConfig         `yaml:"admin" toml:"admin"`
+	OIDC          OIDCConfig          `yaml:"oidc" toml:"oidc"`
+	SMTP          SMTPConfig          `yaml:"smtp" toml:"smtp"`
+	Notifications NotificationsConfig `yaml:"notifications" toml:"notifications"`
+	Payments      PaymentsConfig      `yaml:"payments" toml:"payments"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit" toml:"rate_limit"`
+	Privacy       PrivacyConfig       `yaml:"privacy" toml:"privacy"`
+	Venue         VenueConfig         `yaml:"venue" toml:"venue"`
+}
+
+type ServerConfig struct {
+	Addr        string   `yaml:"addr" toml:"addr"`
+	BaseURL     string   `yaml:"base_url" toml:"base_url"`
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
+	UploadDir   string   `yaml:"upload_dir" toml:"upload_dir"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For is believed for the client IP; by default none, so the client IP
+	// (used by login lockouts and rate limits) is always the connection's address
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	// Driver is "postgres" or "sqlite" (single machine, file at Path)
+	Driver   string `yaml:"driver" toml:"driver"`
+	Path     string `yaml:"path" toml:"path"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+}
+
+type AuthConfig struct {
+	// JWTSecret signs HS256 tokens when no signing key file is set
+	JWTSecret           string   `yaml:"jwt_secret" toml:"jwt_secret"`
+	SigningKeyFile      string   `yaml:"signing_key_file" toml:"signing_key_file"`
+	KeyID               string   `yaml:"key_id" toml:"key_id"`
+	VerifyKeyFiles      []string `yaml:"verify_key_files" toml:"verify_key_files"` // [kid=]path
+	Issuer              string   `yaml:"issuer" toml:"issuer"`
+	Audience            string   `yaml:"audience" toml:"audience"`
+	AccessTokenTTL      Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL     Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+	EmailVerifySecret   string   `yaml:"email_verify_secret" toml:"email_verify_secret"`
+	MFAEncryptionKey    string   `yaml:"mfa_encryption_key" toml:"mfa_encryption_key"`
+	AllowedEmailDomains []string `yaml:"allowed_email_domains" toml:"allowed_email_domains"`
+}
+
+// AdminConfig is the account seeded into an empty database
+type AdminConfig struct {
+	Password string `yaml:"password" toml:"password"`
+}
+
+// OIDCConfig enables university SSO when Issuer is set
+type OIDCConfig struct {
+	Issuer         string   `yaml:"issuer" toml:"issuer"`
+	ClientID       string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret   string   `yaml:"client_secret" toml:"client_secret"`
+	RedirectURL    string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes         []string `yaml:"scopes" toml:"scopes"`
+	StudentIDClaim string   `yaml:"student_id_claim" toml:"student_id_claim"`
+}
+
+// SMTPConfig sends account mail and email notifications when Host is set
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	From     string `yaml:"from" toml:"from"`
+}
+
+type NotificationsConfig struct {
+	LineChannelAccessToken string `yaml:"line_channel_access_token" toml:"line_channel_access_token"`
+	VAPIDPublicKey         string `yaml:"vapid_public_key" toml:"vapid_public_key"`
+	VAPIDPrivateKey        string `yaml:"vapid_private_key" toml:"vapid_private_key"`
+	VAPIDSubject           string `yaml:"vapid_subject" toml:"vapid_subject"`
+	Log                    bool   `yaml:"log" toml:"log"`
+}
+
+type PaymentsConfig struct {
+	// Provider is "", "promptpay" or "fake"
+	Provider      string   `yaml:"provider" toml:"provider"`
+	PromptPayID   string   `yaml:"promptpay_id" toml:"promptpay_id"`
+	WebhookSecret string   `yaml:"webhook_secret" toml:"webhook_secret"`
+	HoldTTL       Duration `yaml:"hold_ttl" toml:"hold_ttl"`
+	RefundCutoff  Duration `yaml:"refund_cutoff" toml:"refund_cutoff"`
+}
+
+type RateLimitConfig struct {
+	// Store is "memory" (per process) or "postgres" (shared between instances)
+	Store string `yaml:"store" toml:"store"`
+}
+
+type PrivacyConfig struct {
+	AccountDeletionGrace Duration `yaml:"account_deletion_grace" toml:"account_deletion_grace"`
+}
+
+type VenueConfig struct {
+	// Timezone of the courts (IANA name): opening hours, price and voucher bands and
+	// calendar days are local to it, whatever the server's own zone is
+	Timezone string `yaml:"timezone" toml:"timezone"`
+}
+
+// Location loads the venue time zone
+func (v VenueConfig) Location() (*time.Location, error) {
+	loc, err := time.LoadLocation(v.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown venue.timezone %q", v.Timezone)
+	}
+	return loc, nil
+}
+
+// Duration reads Go duration strings such as "15m" or "720h" from config files
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the development configuration
+func Default() *Config {
+	return &Config{
+		Env: Development,
+		Server: ServerConfig{
+			Addr:        ":8080",
+			BaseURL:     "http://localhost:3000",
+			CORSOrigins: []string{"*"},
+			UploadDir:   "uploads/profile",
+		},
+		Database: DatabaseConfig{
+			Driver:   "postgres",
+			Path:     "data/courts.db",
+			Host:     "localhost",
+			Port:     5432,
+			User:     "courts_user",
+			Password: devDBPassword,
+			Name:     "courts",
+			SSLMode:  "disable",
+		},
+		Auth: AuthConfig{
+			JWTSecret:       devJWTSecret,
+			Issuer:          "court-booking",
+			Audience:        "court-booking",
+			AccessTokenTTL:  Duration{15 * time.Minute},
+			RefreshTokenTTL: Duration{30 * 24 * time.Hour},
+		},
+		Admin:     AdminConfig{Password: devAdminPassword},
+		SMTP:      SMTPConfig{Port: "587"},
+		RateLimit: RateLimitConfig{Store: "memory"},
+		Payments: PaymentsConfig{
+			HoldTTL:      Duration{15 * time.Minute},
+			RefundCutoff: Duration{24 * time.Hour},
+		},
+		Privacy: PrivacyConfig{AccountDeletionGrace: Duration{14 * 24 * time.Hour}},
+		Venue:   VenueConfig{Timezone: "Asia/Bangkok"},
+	}
+}
+
+// Load builds the configuration from defaults, CONFIG_FILE and the environment
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// DSN returns the Postgres connection string. Values are quoted as libpq expects, so a
+// password with spaces or quotes stays one value.
+func (db DatabaseConfig) DSN() string {
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace
+	return fmt.Sprintf("host='%s' port=%d user='%s' password='%s' dbname='%s' sslmode='%s'",
+		quote(db.Host), db.Port, quote(db.User), quote(db.Password), quote(db.Name), quote(db.SSLMode))
+}
+
+func (c *Config) IsProduction() bool { return c.Env == Production }
+
+// Validate checks the configuration; in production, secrets must be set and must not be the
+// development defaults
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Env {
+	case Development, Staging, Production:
+	default:
+		fail("env must be development, staging or production, got %q", c.Env)
+	}
+
+	if c.Server.Addr == "" {
+		fail("server.addr is required")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				fail("server.trusted_proxies: %q is not an IP address or CIDR range", proxy)
+			}
+		}
+	}
+	switch c.Database.Driver {
+	case "postgres":
+		if c.Database.Host == "" || c.Database.User == "" || c.Database.Name == "" {
+			fail("database host, user and name are required")
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			fail("database.port must be between 1 and 65535")
+		}
+	case "sqlite":
+		if c.Database.Path == "" {
+			fail("database.path is required for sqlite")
+		}
+	default:
+		fail("database.driver must be postgres or sqlite, got %q", c.Database.Driver)
+	}
+	if c.Admin.Password == "" {
+		fail("admin.password is required")
+	}
+	if c.Auth.AccessTokenTTL.Duration <= 0 || c.Auth.RefreshTokenTTL.Duration <= 0 {
+		fail("token lifetimes must be positive")
+	}
+	if c.Auth.AccessTokenTTL.Duration >= c.Auth.RefreshTokenTTL.Duration {
+		fail("auth.access_token_ttl must be shorter than auth.refresh_token_ttl")
+	}
+	if c.Payments.HoldTTL.Duration <= 0 {
+		fail("payments.hold_ttl must be positive")
+	}
+	if c.Payments.RefundCutoff.Duration < 0 || c.Privacy.AccountDeletionGrace.Duration < 0 {
+		fail("payments.refund_cutoff and privacy.account_deletion_grace cannot be negative")
+	}
+
+	switch c.Payments.Provider {
+	case "", "fake":
+	case "promptpay":
+		if c.Payments.PromptPayID == "" || c.Payments.WebhookSecret == "" {
+			fail("promptpay needs payments.promptpay_id and payments.webhook_secret")
+		}
+	default:
+		fail("payments.provider must be promptpay or fake, got %q", c.Payments.Provider)
+	}
+
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		fail("rate_limit.store must be memory or postgres, got %q", c.RateLimit.Store)
+	}
+	if c.RateLimit.Store == "postgres" && c.Database.Driver != "postgres" {
+		fail("rate_limit.store postgres needs the postgres database driver")
+	}
+
+	if c.Venue.Timezone == "" {
+		fail("venue.timezone is required")
+	} else if _, err := c.Venue.Location(); err != nil {
+		fail("%v", err)
+	}
+
+	if c.OIDC.Issuer != "" && (c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "") {
+		fail("oidc needs client_id and redirect_url when issuer is set")
+	}
+
+	if c.IsProduction() {
+		if c.Auth.SigningKeyFile == "" && (c.Auth.JWTSecret == devJWTSecret || len(c.Auth.JWTSecret) < 32) {
+			fail("production needs auth.signing_key_file or a jwt_secret of at least 32 characters")
+		}
+		if c.Database.Driver == "postgres" && (c.Database.Password == "" || c.Database.Password == devDBPassword) {
+			fail("production needs database.password")
+		}
+		if c.Admin.Password == devAdminPassword || len(c.Admin.Password) < 12 {
+			fail("production needs admin.password of at least 12 characters")
+		}
+		if c.Auth.EmailVerifySecret == "" {
+			fail("production needs auth.email_verify_secret")
+		}
+		if c.Auth.MFAEncryptionKey == "" {
+			fail("production needs auth.mfa_encryption_key")
+		}
+		for _, origin := range c.Server.CORSOrigins {
+			if origin == "*" {
+				fail("production cannot allow every CORS origin, list them in server.cors_origins")
+			}
+		}
+		if c.Payments.Provider == "fake" {
+			fail("the fake payment provider cannot be used in production")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Internal functions
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(data, c, yaml.Strict())
+	case ".toml":
+		err = toml.NewDecoder(strings.NewReader(string(data))).DisallowUnknownFields().Decode(c)
+	default:
+		return fmt.Errorf("config file must be .yaml, .yml or .toml: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// loadEnv applies environment variables over the defaults and the config file
+func (c *Config) loadEnv() error {
+	e := envReader{}
+
+	e.str(&c.Env, "APP_ENV")
+
+	if port := os.Getenv("PORT"); port != "" {
+		c.Server.Addr = ":" + port
+	}
+	e.str(&c.Server.Addr, "LISTEN_ADDR")
+	e.str(&c.Server.BaseURL, "APP_BASE_URL")
+	e.list(&c.Server.CORSOrigins, "CORS_ORIGINS", ",")
+	e.list(&c.Server.TrustedProxies, "TRUSTED_PROXIES", ",")
+	e.str(&c.Server.UploadDir, "UPLOAD_DIR")
+
+	e.str(&c.Database.Driver, "DB_DRIVER")
+	e.str(&c.Database.Path, "DB_PATH")
+	e.str(&c.Database.Host, "DB_HOST")
+	e.int(&c.Database.Port, "DB_PORT")
+	e.str(&c.Database.User, "DB_USER")
+	e.str(&c.Database.Password, "DB_PASSWORD")
+	e.str(&c.Database.Name, "DB_NAME")
+	e.str(&c.Database.SSLMode, "DB_SSLMODE")
+
+	e.str(&c.Auth.JWTSecret, "JWT_SECRET")
+	e.str(&c.Auth.SigningKeyFile, "JWT_SIGNING_KEY_FILE")
+	e.str(&c.Auth.KeyID, "JWT_KEY_ID")
+	e.list(&c.Auth.VerifyKeyFiles, "JWT_VERIFY_KEY_FILES", ",")
+	e.str(&c.Auth.Issuer, "JWT_ISSUER")
+	e.str(&c.Auth.Audience, "JWT_AUDIENCE")
+	e.duration(&c.Auth.AccessTokenTTL, "ACCESS_TOKEN_MINUTES", time.Minute)
+	e.duration(&c.Auth.RefreshTokenTTL, "REFRESH_TOKEN_DAYS", 24*time.Hour)
+	e.str(&c.Auth.EmailVerifySecret, "EMAIL_VERIFY_SECRET")
+	e.str(&c.Auth.MFAEncryptionKey, "MFA_ENCRYPTION_KEY")
+	e.list(&c.Auth.AllowedEmailDomains, "ALLOWED_EMAIL_DOMAINS", ",")
+
+	e.str(&c.Admin.Password, "ADMIN_PASSWORD")
+
+	e.str(&c.OIDC.Issuer, "OIDC_ISSUER")
+	e.str(&c.OIDC.ClientID, "OIDC_CLIENT_ID")
+	e.str(&c.OIDC.ClientSecret, "OIDC_CLIENT_SECRET")
+	e.str(&c.OIDC.RedirectURL, "OIDC_REDIRECT_URL")
+	e.list(&c.OIDC.Scopes, "OIDC_SCOPES", " ")
+	e.str(&c.OIDC.StudentIDClaim, "OIDC_STUDENT_ID_CLAIM")
+
+	e.str(&c.SMTP.Host, "SMTP_HOST")
+	e.str(&c.SMTP.Port, "SMTP_PORT")
+	e.str(&c.SMTP.Username, "SMTP_USERNAME")
+	e.str(&c.SMTP.Password, "SMTP_PASSWORD")
+	e.str(&c.SMTP.From, "SMTP_FROM")
+
+	e.str(&c.Notifications.LineChannelAccessToken, "LINE_CHANNEL_ACCESS_TOKEN")
+	e.str(&c.Notifications.VAPIDPublicKey, "VAPID_PUBLIC_KEY")
+	e.str(&c.Notifications.VAPIDPrivateKey, "VAPID_PRIVATE_KEY")
+	e.str(&c.Notifications.VAPIDSubject, "VAPID_SUBJECT")
+	e.bool(&c.Notifications.Log, "NOTIFY_LOG")
+
+	e.str(&c.Payments.Provider, "PAYMENT_PROVIDER")
+	e.str(&c.Payments.PromptPayID, "PROMPTPAY_ID")
+	e.str(&c.Payments.WebhookSecret, "PAYMENT_WEBHOOK_SECRET")
+	e.duration(&c.Payments.HoldTTL, "PAYMENT_HOLD_MINUTES", time.Minute)
+	e.duration(&c.Payments.RefundCutoff, "REFUND_CUTOFF_HOURS", time.Hour)
+	// Setting a PromptPay ID alone has always been enough to turn PromptPay on
+	if c.Payments.PromptPayID != "" && c.Payments.Provider == "" {
+		c.Payments.Provider = "promptpay"
+	}
+
+	e.str(&c.RateLimit.Store, "RATE_LIMIT_STORE")
+	e.duration(&c.Privacy.AccountDeletionGrace, "ACCOUNT_DELETION_GRACE_DAYS", 24*time.Hour)
+	e.str(&c.Venue.Timezone, "VENUE_TIMEZONE")
+
+	return e.err()
+}
+
+// envReader copies set environment variables into config fields and collects parse errors
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) str(field *string, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		*field = v
+	}
+}
+
+func (e *envReader) list(field *[]string, name, sep string) {
+	v, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(v) == "" {
+		return
+	}
+	var items []string
+	if sep == " " {
+		items = strings.Fields(v)
+	} else {
+		for _, item := range strings.Split(v, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	*field = items
+}
+
+func (e *envReader) int(field *int, name string) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a number", name))
+		return
+	}
+	*field = n
+}
+
+// duration reads a whole number of unit, e.g. ACCESS_TOKEN_MINUTES=15
+func (e *envReader) duration(field *Duration, name string, unit time.Duration) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a number", name))
+		return
+	}
+	if n < 0 {
+		e.errs = append(e.errs, fmt.Errorf("%s cannot be negative", name))
+		return
+	}
+	field.Duration = time.Duration(n) * unit
+}
+
+func (e *envReader) bool(field *bool, name string) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be true or false", name))
+		return
+	}
+	*field = b
+}
+
+func (e *envReader) err() error {
+	if len(e.errs) > 0 {
+		return fmt.Errorf("invalid environment: %w", errors.Join(e.errs...))
+	}
+	return nil
+}
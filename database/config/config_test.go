@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDSNQuotesValues(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		want     string
+	}{
+		{"secret", `host='db' port=5432 user='courts' password='secret' dbname='courts' sslmode='disable'`},
+		{"", `host='db' port=5432 user='courts' password='' dbname='courts' sslmode='disable'`},
+		{"two words", `host='db' port=5432 user='courts' password='two words' dbname='courts' sslmode='disable'`},
+		{"x sslmode=disable", `host='db' port=5432 user='courts' password='x sslmode=disable' dbname='courts' sslmode='disable'`},
+		{`it's`, `host='db' port=5432 user='courts' password='it\'s' dbname='courts' sslmode='disable'`},
+		{`back\slash`, `host='db' port=5432 user='courts' password='back\\slash' dbname='courts' sslmode='disable'`},
+	} {
+		db := DatabaseConfig{Host: "db", Port: 5432, User: "courts", Password: tc.password, Name: "courts", SSLMode: "disable"}
+		if got := db.DSN(); got != tc.want {
+			t.Errorf("DSN with password %q =\n%s\nwant\n%s", tc.password, got, tc.want)
+		}
+	}
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Default().Validate() = %v", err)
+	}
+	for _, tc := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"env", cfg.Env, Development},
+		{"server.addr", cfg.Server.Addr, ":8080"},
+		{"database.driver", cfg.Database.Driver, "postgres"},
+		{"auth.access_token_ttl", cfg.Auth.AccessTokenTTL.Duration, 15 * time.Minute},
+		{"auth.refresh_token_ttl", cfg.Auth.RefreshTokenTTL.Duration, 30 * 24 * time.Hour},
+		{"payments.hold_ttl", cfg.Payments.HoldTTL.Duration, 15 * time.Minute},
+		{"rate_limit.store", cfg.RateLimit.Store, "memory"},
+		{"venue.timezone", cfg.Venue.Timezone, "Asia/Bangkok"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("default %s = %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const yamlFile = `
+server:
+  addr: ":9000"
+database:
+  port: 6543
+auth:
+  access_token_ttl: 5m
+`
+	for _, tc := range []struct {
+		name  string
+		file  string // written to config.yaml, or config.toml when it starts with "["
+		env   map[string]string
+		check func(cfg *Config) error
+	}{
+		{"file over defaults", yamlFile, nil, func(cfg *Config) error {
+			return expect(cfg.Server.Addr, ":9000", cfg.Database.Port, 6543, cfg.Auth.AccessTokenTTL.Duration, 5*time.Minute, cfg.Database.Host, "localhost")
+		}},
+		{"env over file", yamlFile, map[string]string{"LISTEN_ADDR": ":9100", "DB_PORT": "7000", "ACCESS_TOKEN_MINUTES": "20"}, func(cfg *Config) error {
+			return expect(cfg.Server.Addr, ":9100", cfg.Database.Port, 7000, cfg.Auth.AccessTokenTTL.Duration, 20*time.Minute)
+		}},
+		{"PORT over file", yamlFile, map[string]string{"PORT": "9200"}, func(cfg *Config) error {
+			return expect(cfg.Server.Addr, ":9200")
+		}},
+		{"LISTEN_ADDR over PORT", "", map[string]string{"PORT": "9200", "LISTEN_ADDR": "127.0.0.1:9300"}, func(cfg *Config) error {
+			return expect(cfg.Server.Addr, "127.0.0.1:9300")
+		}},
+		{"empty env is ignored", yamlFile, map[string]string{"LISTEN_ADDR": ""}, func(cfg *Config) error {
+			return expect(cfg.Server.Addr, ":9000")
+		}},
+		{"toml file", "[server]\naddr = \":9400\"\n\n[venue]\ntimezone = \"UTC\"\n", nil, func(cfg *Config) error {
+			return expect(cfg.Server.Addr, ":9400", cfg.Venue.Timezone, "UTC")
+		}},
+		{"PromptPay ID turns on PromptPay", "", map[string]string{"PROMPTPAY_ID": "0812345678", "PAYMENT_WEBHOOK_SECRET": "s"}, func(cfg *Config) error {
+			return expect(cfg.Payments.Provider, "promptpay")
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			if tc.file != "" {
+				name := "config.yaml"
+				if strings.HasPrefix(tc.file, "[") {
+					name = "config.toml"
+				}
+				path := filepath.Join(t.TempDir(), name)
+				if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+			for _, name := range []string{"PORT", "LISTEN_ADDR", "DB_HOST", "DB_PORT", "ACCESS_TOKEN_MINUTES", "PROMPTPAY_ID", "PAYMENT_WEBHOOK_SECRET", "PAYMENT_PROVIDER"} {
+				t.Setenv(name, "")
+			}
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.check(cfg); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsBadInput(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		file string
+		env  map[string]string
+	}{
+		{"unknown file key", "server:\n  adr: \":9000\"\n", nil},
+		{"malformed env number", "", map[string]string{"DB_PORT": "five"}},
+		{"unknown venue zone", "", map[string]string{"VENUE_TIMEZONE": "Mars/Olympus_Mons"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Error("Load() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestValidateProduction(t *testing.T) {
+	production := func() *Config {
+		cfg := Default()
+		cfg.Env = Production
+		cfg.Server.CORSOrigins = []string{"https://courts.uni.th"}
+		cfg.Database.Password = "db-password"
+		cfg.Auth.JWTSecret = strings.Repeat("j", 32)
+		cfg.Auth.EmailVerifySecret = "email-secret"
+		cfg.Auth.MFAEncryptionKey = "mfa-key"
+		cfg.Admin.Password = "admin-password"
+		return cfg
+	}
+	if err := production().Validate(); err != nil {
+		t.Fatalf("complete production config: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(cfg *Config)
+		want   string // part of the error, empty for none
+	}{
+		{"development JWT secret", func(cfg *Config) { cfg.Auth.JWTSecret = devJWTSecret }, "jwt_secret"},
+		{"short JWT secret", func(cfg *Config) { cfg.Auth.JWTSecret = "short" }, "jwt_secret"},
+		{"signing key file instead of a secret", func(cfg *Config) { cfg.Auth.JWTSecret, cfg.Auth.SigningKeyFile = "", "/keys/jwt.pem" }, ""},
+		{"missing database password", func(cfg *Config) { cfg.Database.Password = "" }, "database.password"},
+		{"development database password", func(cfg *Config) { cfg.Database.Password = devDBPassword }, "database.password"},
+		{"sqlite needs no database password", func(cfg *Config) { cfg.Database.Driver, cfg.Database.Password = "sqlite", "" }, ""},
+		{"development admin password", func(cfg *Config) { cfg.Admin.Password = devAdminPassword }, "admin.password"},
+		{"missing email verification secret", func(cfg *Config) { cfg.Auth.EmailVerifySecret = "" }, "email_verify_secret"},
+		{"missing MFA key", func(cfg *Config) { cfg.Auth.MFAEncryptionKey = "" }, "mfa_encryption_key"},
+		{"any CORS origin", func(cfg *Config) { cfg.Server.CORSOrigins = []string{"*"} }, "CORS"},
+		{"fake payments", func(cfg *Config) { cfg.Payments.Provider = "fake" }, "fake payment provider"},
+		{"staging keeps development defaults", func(cfg *Config) { *cfg = *Default(); cfg.Env = Staging }, ""},
+	} {
+		cfg := production()
+		tc.change(cfg)
+		err := cfg.Validate()
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: Validate() = %v, want nil", tc.name, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%s: Validate() = %v, want an error about %s", tc.name, err, tc.want)
+		}
+	}
+}
+
+// expect takes got, want pairs and reports the first that differ
+func expect(pairs ...interface{}) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i] != pairs[i+1] {
+			return fmt.Errorf("value %d = %v, want %v", i/2, pairs[i], pairs[i+1])
+		}
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,23 +13,126 @@ type AdminResetRequest struct {
 	UserID int `json:"user_id" binding:"required"`
 }
 
+type AnnouncementRequest struct {
+	Title   string `json:"title" binding:"required"`
+	Message string `json:"message" binding:"required"`
+	UserIDs []int  `json:"user_ids"`
+}
+
+type CourtStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=Available Closed"`
+}
+
 // POST /api/admin/bookings/reset
-func HandleResetBookings(c *gin.Context) {
-	// Reset all bookings in database
-	err := ResetAllBookingsDB()
+// Cancels every upcoming booking one by one, so each is refunded like any staff cancellation;
+// past bookings, payments and the ledger are kept.
+func (h *Handler) HandleResetBookings(c *gin.Context) {
+	upcoming, err := h.Bookings.UpcomingBookings(0)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	adminID := c.MustGet("userID").(int)
+	cancelled := 0
+	for _, b := range upcoming {
+		refund, err := h.Bookings.CancelBooking(b.BookingID, adminID)
+		if err != nil {
+			// Cancelled or expired since it was listed
+			log.Printf("Reset skipped booking %d: %v", b.BookingID, err)
+			continue
+		}
+		cancelled++
+
+		message := fmt.Sprintf("Booking #%d starting %s was cancelled by an administrator", b.BookingID, b.StartTime.In(venueLocation()).Format("2006-01-02 15:04"))
+		if refund > 0 {
+			message += fmt.Sprintf("; %s THB was refunded to your wallet", refund)
+		}
+		go h.NotifyUser(b.UserID, Notification{
+			Event:   EventBookingCancelled,
+			Title:   "Booking cancelled by admin",
+			Message: message,
+		})
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "All court bookings have been reset successfully",
+		"message":   "All court bookings have been reset successfully",
+		"cancelled": cancelled,
+	})
+}
+
+// POST /api/admin/announcements
+func (h *Handler) HandleCreateAnnouncement(c *gin.Context) {
+	var req AnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	// Empty user_ids means everyone
+	recipients := req.UserIDs
+	if len(recipients) == 0 {
+		ids, err := h.GetAllUserIDsDB()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recipients = ids
+	}
+
+	n := Notification{Event: EventAnnouncement, Title: req.Title, Message: req.Message}
+	go func() {
+		for _, uid := range recipients {
+			h.NotifyUser(uid, n)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "announcement queued",
+		"recipients": len(recipients),
 	})
 }
 
-// Internal functions
+// PUT /api/admin/courts/:courtId/status
+func (h *Handler) HandleUpdateCourtStatus(c *gin.Context) {
+	courtID, err := strconv.Atoi(c.Param("courtId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid court id"})
+		return
+	}
+
+	var req CourtStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	court, err := h.Courts.GetCourt(courtID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Courts.SetCourtStatus(courtID, req.Status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Status == "Closed" && court.Status != "Closed" {
+		upcoming, err := h.Bookings.UpcomingBookings(courtID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, b := range upcoming {
+			go h.NotifyUser(b.UserID, Notification{
+				Event:   EventCourtClosed,
+				Title:   "Court closed",
+				Message: fmt.Sprintf("%s (%s) is closed; your booking #%d starting %s is affected", court.CourtName, court.SportType, b.BookingID, b.StartTime.In(venueLocation()).Format("2006-01-02 15:04")),
+			})
+		}
+	}
 
-func ResetAllBookings() {
-	Bookings = []Booking{}
-	NextBookingID = 1
+	court.Status = req.Status
+	c.JSON(http.StatusOK, gin.H{"message": "court status updated", "court": court})
 }
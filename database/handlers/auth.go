@@ -2,21 +2,31 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var errInvalidCredentials = fmt.Errorf("invalid username or password")
+
+// dummyPasswordHash is compared against when the username does not exist, so unknown
+// usernames take as long to reject as wrong passwords
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
 type RegisterRequest struct {
 	FirstName   string `json:"first_name" binding:"required"`
 	LastName    string `json:"last_name"`
 	UserName    string `json:"username" binding:"required"`
 	Password    string `json:"password" binding:"required,min=6"`
-	Email       string `json:"email"`
+	Email       string `json:"email" binding:"required,email"`
 	PhoneNumber string `json:"phone_number"`
-	StudentID   string `json:"student_id"`
+	StudentID   string `json:"student_id" binding:"max=20"`
+	// IDs of the current policy documents (GET /api/policies) the user agreed to
+	AcceptedPolicies []int `json:"accepted_policies"`
 }
 
 type LoginRequest struct {
@@ -24,81 +34,211 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // POST /api/auth/register
-func HandleRegister(c *gin.Context) {
+func (h *Handler) HandleRegister(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
 		return
 	}
 
-	// Use database-backed registration
-	user, err := RegisterUserDB(req)
+	if !EmailDomainAllowed(req.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "registration is limited to university email addresses"})
+		return
+	}
+
+	// Every current policy document has to be accepted to sign up
+	current, err := h.GetCurrentPoliciesDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	accepted := make(map[int]bool, len(req.AcceptedPolicies))
+	for _, id := range req.AcceptedPolicies {
+		accepted[id] = true
+	}
+	policyIDs := make([]int, 0, len(current))
+	for _, doc := range current {
+		if !accepted[doc.DocumentID] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":            errPolicyNotAccepted.Error(),
+				"consent_required": true,
+				"pending":          current,
+			})
+			return
+		}
+		policyIDs = append(policyIDs, doc.DocumentID)
+	}
+
+	user, err := h.RegisterUser(req, &PolicyConsent{DocumentIDs: policyIDs, IPAddress: c.ClientIP()})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// New accounts must verify their email before booking
+	if _, err := h.ReserveVerificationSendDB(user.UserID); err != nil {
+		log.Printf("Error recording verification email: %v", err)
+	}
+	go SendEmailVerification(user)
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "registered successfully",
+		"message": "registered successfully, check your email to verify your account",
 		"user":    FormatUserResponse(user),
 	})
 }
 
 // POST /api/auth/login
-func HandleLogin(c *gin.Context) {
+func (h *Handler) HandleLogin(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
 		return
 	}
 
-	// Use database-backed login
-	user, err := LoginUserDB(req)
+	// Brute-force protection: locked usernames/IPs are refused before the password is checked,
+	// and repeated failures slow down. Unknown usernames are tracked the same way.
+	ip := c.ClientIP()
+	throttle, err := h.CheckLoginThrottleDB(req.UserName, ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if throttle.LockedUntil != nil {
+		c.Header("Retry-After", strconv.Itoa(int(throttle.LockedUntil.Sub(GetCurrentTime()).Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": errTooManyAttempts.Error()})
+		return
+	}
+	time.Sleep(throttle.Delay)
+
+	user, err := h.AuthenticateUser(req)
+	if err == errInvalidCredentials {
+		if err := h.RecordLoginAttemptDB(req.UserName, ip, false); err != nil {
+			log.Printf("Error recording login attempt: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.RecordLoginAttemptDB(req.UserName, ip, true); err != nil {
+		log.Printf("Error recording login attempt: %v", err)
+	}
+
+	h.RespondLogin(c, user)
+}
+
+// POST /api/auth/refresh
+func (h *Handler) HandleRefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	tokens, err := h.RefreshSessionDB(req.RefreshToken)
+	if err == errInvalidRefreshToken || err == errRefreshTokenReused {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "login success",
-		"user":    FormatLoginResponse(user),
-	})
+	c.JSON(http.StatusOK, tokens)
 }
 
 // POST /api/auth/logout
-func HandleLogout(c *gin.Context) {
+func (h *Handler) HandleLogout(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	if err := h.RevokeSessionDB(userID, c.GetString("sessionID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "logout successful",
 	})
 }
 
+// POST /api/auth/logout-all
+func (h *Handler) HandleLogoutAll(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	if err := h.RevokeAllSessionsDB(userID, "logout all"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "logged out from all sessions",
+	})
+}
+
 // Internal functions
 
-func ValidateRegisterRequest(req RegisterRequest) error {
-	for _, u := range Users {
-		if u.UserName == req.UserName {
-			return fmt.Errorf("username already taken")
-		}
+// RespondLogin finishes a successful first factor: users with 2FA get a challenge for
+// POST /api/auth/mfa/verify, everyone else a session right away
+func (h *Handler) RespondLogin(c *gin.Context, user *User) {
+	enabled, err := h.IsMFAEnabledDB(user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	if req.Email != "" {
-		for _, u := range Users {
-			if u.Email == req.Email {
-				return fmt.Errorf("email already registered")
-			}
+
+	if enabled {
+		mfaToken, err := h.CreateMFAChallengeDB(user.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int(mfaChallengeTTL / time.Second),
+		})
+		return
 	}
-	return nil
+
+	tokens, err := h.CreateSessionDB(user, false, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"message": "login success",
+		"user":    FormatLoginResponse(user, tokens),
+	}
+	// Admins can only enroll until they have 2FA; every admin route refuses them
+	if user.Role == "Admin" {
+		response["mfa_setup_required"] = true
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-func CreateNewUser(req RegisterRequest) (User, error) {
+// RegisterUser creates a Member account from a sign-up request
+func (h *Handler) RegisterUser(req RegisterRequest, consent *PolicyConsent) (User, error) {
+	if IsReservedUserName(req.UserName) {
+		return User{}, errUsernameReserved
+	}
+
 	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, fmt.Errorf("cannot hash password")
 	}
 
 	user := User{
-		UserID:       NextUserID,
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
 		UserName:     req.UserName,
@@ -106,59 +246,96 @@ func CreateNewUser(req RegisterRequest) (User, error) {
 		Email:        req.Email,
 		PhoneNumber:  req.PhoneNumber,
 		StudentID:    req.StudentID,
-		Role:         "Member",
-		CreatedAt:    GetCurrentTime(),
+		Role:         RoleMember,
 	}
-	NextUserID++
-	Users = append(Users, user)
+	if err := h.Users.CreateUser(&user, consent); err != nil {
+		return User{}, err
+	}
+
+	log.Printf("✅ User %s registered successfully (ID: %d)", user.UserName, user.UserID)
 	return user, nil
 }
 
-func AuthenticateUser(req LoginRequest) (*User, error) {
-	var found *User
-	for i := range Users {
-		if Users[i].UserName == req.UserName {
-			found = &Users[i]
-			break
-		}
+// AuthenticateUser checks a username and password
+func (h *Handler) AuthenticateUser(req LoginRequest) (*User, error) {
+	user, err := h.Users.GetUserByUserName(req.UserName)
+	if err == errUserNotFound {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		return nil, errInvalidCredentials
 	}
-	if found == nil {
-		return nil, fmt.Errorf("invalid username or password")
+	if err != nil {
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(found.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("invalid username or password")
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, errInvalidCredentials
 	}
 
-	return found, nil
+	log.Printf("✅ User %s logged in successfully", req.UserName)
+	return user, nil
 }
 
-func FormatUserResponse(user User) gin.H {
-	return gin.H{
-		"user_id":    user.UserID,
-		"first_name": user.FirstName,
-		"last_name":  user.LastName,
-		"username":   user.UserName,
-		"email":      user.Email,
-		"phone":      user.PhoneNumber,
-		"student_id": user.StudentID,
-		"role":       user.Role,
-		"created_at": user.CreatedAt,
+// SeedAdmin creates the first administrator if it does not exist yet
+func (h *Handler) SeedAdmin(password string) error {
+	_, err := h.Users.GetUserByUserName("somchai_k")
+	if err == nil {
+		log.Println("✅ Admin user already exists")
+		return nil
+	}
+	if err != errUserNotFound {
+		return err
 	}
-}
 
-func FormatLoginResponse(user *User) gin.H {
-	token, err := GenerateToken(user.UserID, user.Role)
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		token = "DUMMY_TOKEN_FOR_USER_" + strconv.Itoa(user.UserID)
+		return fmt.Errorf("cannot hash password")
 	}
 
+	now := GetCurrentTime()
+	admin := User{
+		FirstName:       "Somchai",
+		LastName:        "Kaewman",
+		UserName:        "somchai_k",
+		PasswordHash:    string(hashed),
+		Email:           "somchai@uni.th",
+		PhoneNumber:     "088-1111-1111",
+		Role:            RoleAdmin,
+		EmailVerifiedAt: &now,
+	}
+	if err := h.Users.CreateUser(&admin, nil); err != nil {
+		log.Printf("Error seeding admin: %v", err)
+		return err
+	}
+
+	log.Println("✅ Admin user seeded successfully")
+	return nil
+}
+
+func FormatUserResponse(user User) gin.H {
+	return gin.H{
+		"user_id":        user.UserID,
+		"first_name":     user.FirstName,
+		"last_name":      user.LastName,
+		"username":       user.UserName,
+		"email":          user.Email,
+		"phone":          user.PhoneNumber,
+		"student_id":     user.StudentID,
+		"role":           user.Role,
+		"created_at":     user.CreatedAt,
+		"email_verified": user.EmailVerifiedAt != nil,
+	}
+}
+
+func FormatLoginResponse(user *User, tokens TokenPair) gin.H {
 	return gin.H{
-		"user_id":    user.UserID,
-		"first_name": user.FirstName,
-		"last_name":  user.LastName,
-		"username":   user.UserName,
-		"role":       user.Role,
-		"token":      token,
+		"user_id":        user.UserID,
+		"first_name":     user.FirstName,
+		"last_name":      user.LastName,
+		"username":       user.UserName,
+		"role":           user.Role,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"token":          tokens.AccessToken,
+		"refresh_token":  tokens.RefreshToken,
+		"expires_in":     tokens.ExpiresIn,
 	}
 }
@@ -8,42 +8,103 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxBookingDuration caps one booking; longer spans are refused before they are priced
+const maxBookingDuration = 12 * time.Hour
+
 type CreateBookingRequest struct {
 	CourtID     int    `json:"court_id" binding:"required"`
 	BookingDate string `json:"booking_date" binding:"required"`
 	StartTime   string `json:"start_time" binding:"required"`
 	EndTime     string `json:"end_time" binding:"required"`
+	// PaymentMethod is "promptpay" (default) or "wallet"
+	PaymentMethod string `json:"payment_method" binding:"omitempty,oneof=promptpay wallet"`
+	VoucherCode   string `json:"voucher_code"`
 }
 
 // POST /api/bookings
-func HandleCreateBooking(c *gin.Context) {
+func (h *Handler) HandleCreateBooking(c *gin.Context) {
 	var req CreateBookingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
 		return
 	}
 
+	start, end, err := ParseBookingTimes(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	userID := c.MustGet("userID").(int)
 
-	// Create booking in database
-	bookingID, err := CreateBookingDB(userID, req.CourtID, req.BookingDate, req.StartTime, req.EndTime)
+	quote, err := h.QuoteBookingDB(userID, req.CourtID, start, end)
+	if err == errCourtNotFound || err == errUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message":    "booking created",
-		"booking_id": bookingID,
+	var voucher *Voucher
+	if req.VoucherCode != "" {
+		voucher, err = h.ApplyVoucherDB(req.VoucherCode, userID, &quote)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Create booking in database
+	booking, payment, err := h.Bookings.CreateBooking(NewBooking{
+		UserID:        userID,
+		CourtID:       req.CourtID,
+		StartTime:     start,
+		EndTime:       end,
+		Quote:         quote,
+		PaymentMethod: req.PaymentMethod,
+		Voucher:       voucher,
 	})
+	if err != nil {
+		c.JSON(createBookingStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if booking.BookingStatus == StatusConfirmed {
+		go h.NotifyUser(userID, Notification{
+			Event:   EventBookingConfirmed,
+			Title:   "Booking confirmed",
+			Message: fmt.Sprintf("Booking #%d on %s (%s - %s) is confirmed", booking.BookingID, req.BookingDate, req.StartTime, req.EndTime),
+		})
+	}
+
+	response := gin.H{
+		"message":         "booking created",
+		"booking_id":      booking.BookingID,
+		"booking_status":  booking.BookingStatus,
+		"price":           quote.Total,
+		"currency":        quote.Currency,
+		"price_breakdown": quote.Lines,
+	}
+	if voucher != nil {
+		response["voucher_code"] = quote.VoucherCode
+		response["discount"] = quote.Discount
+	}
+	if payment != nil {
+		response["message"] = "booking held pending payment"
+		response["payment"] = payment
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 // GET /api/bookings/history
-func HandleGetBookingHistory(c *gin.Context) {
+func (h *Handler) HandleGetBookingHistory(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
 	
 	// Get bookings from database
-	bookings, err := GetUserBookingsDB(userID)
+	bookings, err := h.Bookings.UserBookings(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -53,7 +114,7 @@ func HandleGetBookingHistory(c *gin.Context) {
 }
 
 // DELETE /api/bookings/:bookingId
-func HandleDeleteBooking(c *gin.Context) {
+func (h *Handler) HandleDeleteBooking(c *gin.Context) {
 	idStr := c.Param("bookingId")
 	bid, err := ParseBookingID(idStr)
 	if err != nil {
@@ -61,18 +122,60 @@ func HandleDeleteBooking(c *gin.Context) {
 		return
 	}
 
-	// Delete booking from database
-	err = DeleteBookingDB(bid)
+	booking, err := h.Bookings.GetBooking(bid)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Booking ID %d cancelled successfully", bid)})
+	userID := c.MustGet("userID").(int)
+	if booking.UserID != userID && !HasPermission(c, PermBookingsCancelAny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	// Cancel booking in database
+	refund, err := h.Bookings.CancelBooking(bid, userID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := fmt.Sprintf("Booking #%d starting %s has been cancelled", bid, booking.StartTime.In(venueLocation()).Format("2006-01-02 15:04"))
+	if refund > 0 {
+		message += fmt.Sprintf("; %s THB was refunded to your wallet", refund)
+	}
+	go h.NotifyUser(booking.UserID, Notification{
+		Event:   EventBookingCancelled,
+		Title:   "Booking cancelled",
+		Message: message,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Booking ID %d cancelled successfully", bid),
+		"refund":  refund,
+	})
 }
 
 // Internal functions
 
+// createBookingStatus maps a CreateBooking error to its HTTP status
+func createBookingStatus(err error) int {
+	switch err {
+	case errCourtNotFound:
+		return http.StatusNotFound
+	case errCourtBooked:
+		return http.StatusConflict
+	case errInsufficientBalance:
+		return http.StatusPaymentRequired
+	case errVoucherNotValid, errVoucherExhausted, errVoucherLimitReached:
+		return http.StatusUnprocessableEntity
+	case errPaymentsNotConfigured, errPaidBookingsUnsupported:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
 func ParseBookingTimes(req CreateBookingRequest) (time.Time, time.Time, error) {
 	start, err := time.Parse(time.RFC3339, req.StartTime)
 	if err != nil {
@@ -85,6 +188,9 @@ func ParseBookingTimes(req CreateBookingRequest) (time.Time, time.Time, error) {
 	if !end.After(start) {
 		return time.Time{}, time.Time{}, fmt.Errorf("end_time must be after start_time")
 	}
+	if end.Sub(start) > maxBookingDuration {
+		return time.Time{}, time.Time{}, fmt.Errorf("bookings can be at most %d hours long", int(maxBookingDuration/time.Hour))
+	}
 	return start, end, nil
 }
 
@@ -96,55 +202,3 @@ func ParseBookingID(idStr string) (int, error) {
 	}
 	return bid, nil
 }
-
-func IsCourtBooked(courtID int, start, end time.Time) bool {
-	for _, b := range Bookings {
-		if b.CourtID == courtID && b.BookingStatus == "Confirmed" {
-			if start.Before(b.EndTime) && end.After(b.StartTime) {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func CreateBookingRecord(userID, courtID int, start, end time.Time) Booking {
-	booking := Booking{
-		BookingID:     NextBookingID,
-		CourtID:       courtID,
-		UserID:        userID,
-		StartTime:     start,
-		EndTime:       end,
-		BookingStatus: "Confirmed",
-		CreatedAt:     time.Now(),
-	}
-	NextBookingID++
-	Bookings = append(Bookings, booking)
-	return booking
-}
-
-func GetUserBookings(userID int) []Booking {
-	var result []Booking
-	for _, b := range Bookings {
-		if b.UserID == userID {
-			result = append(result, b)
-		}
-	}
-	return result
-}
-
-func FindUserBookingIndex(bookingID, userID int) (int, error) {
-	for i := range Bookings {
-		if Bookings[i].BookingID == bookingID {
-			if Bookings[i].UserID != userID {
-				return -1, fmt.Errorf("access denied")
-			}
-			return i, nil
-		}
-	}
-	return -1, fmt.Errorf("booking not found")
-}
-
-func DeleteBookingAt(index int) {
-	Bookings = append(Bookings[:index], Bookings[index+1:]...)
-}
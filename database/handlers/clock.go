@@ -0,0 +1,47 @@
+package handlers
+
+import "time"
+
+// Clock tells the handlers what time it is: bookings, payment holds, vouchers, policies, tokens,
+// sessions, login lockouts, 2FA and account deletion all go by it, and SQL gets its value as a
+// parameter rather than calling NOW(). Production uses the system clock; tests set their own
+// with SetClock to move time without sleeping. Rate limits, caches and tokens checked by other
+// services (IdP ID tokens, web push VAPID) stay on the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+var clock Clock = systemClock{}
+
+// SetClock sets the clock used by handlers (nil means the system clock)
+func SetClock(c Clock) {
+	if c == nil {
+		c = systemClock{}
+	}
+	clock = c
+}
+
+// GetCurrentTime returns the time according to the configured clock
+func GetCurrentTime() time.Time {
+	return clock.Now()
+}
+
+var venue = time.UTC
+
+// SetVenueLocation sets the time zone of the courts, which opening hours, price and voucher
+// bands and calendar days are local to (nil means UTC)
+func SetVenueLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	venue = loc
+}
+
+// venueLocation returns the time zone of the courts
+func venueLocation() *time.Location {
+	return venue
+}
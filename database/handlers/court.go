@@ -1,29 +1,42 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GET /api/sports
-func HandleGetSportTypes(c *gin.Context) {
-	sportTypes := GetSportTypes()
+func (h *Handler) HandleGetSportTypes(c *gin.Context) {
+	sportTypes, err := h.Courts.SportTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"data": sportTypes})
 }
 
 // GET /api/courts
-func HandleGetCourts(c *gin.Context) {
+func (h *Handler) HandleGetCourts(c *gin.Context) {
 	st := c.Query("sport_type")
-	filtered := GetCourtsList(st)
+	filtered, err := h.Courts.ListCourts(st)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"data": filtered})
 }
 
 // GET /api/courts/:sportType
-func HandleGetCourtsBySportTypeParam(c *gin.Context) {
+func (h *Handler) HandleGetCourtsBySportTypeParam(c *gin.Context) {
 	st := c.Param("sportType")
 
-	filtered := GetCourtsBySportType(st)
+	filtered, err := h.Courts.ListCourts(st)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	if len(filtered) == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "sport type or courts not found"})
@@ -35,64 +48,46 @@ func HandleGetCourtsBySportTypeParam(c *gin.Context) {
 
 // Internal functions
 
-func GetSportTypes() []string {
-	var sportTypes []string
-
-	rows, err := DB.Query("SELECT DISTINCT SportType FROM courts ORDER BY SportType")
+// SeedCourts adds the university's courts to an empty court store
+func (h *Handler) SeedCourts() error {
+	existing, err := h.Courts.ListCourts("")
 	if err != nil {
-		return sportTypes
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var st string
-		if err := rows.Scan(&st); err != nil {
-			continue
-		}
-		sportTypes = append(sportTypes, st)
+		return err
 	}
 
-	return sportTypes
-}
-
-func GetCourtsList(sportType string) []Court {
-	var query string
-	var args []interface{}
-
-	if sportType == "" {
-		query = "SELECT CourtID, CourtName, SportType, CourtNumber, Status FROM courts ORDER BY SportType, CourtName"
-	} else {
-		query = "SELECT CourtID, CourtName, SportType, CourtNumber, Status FROM courts WHERE SportType = $1 ORDER BY CourtName"
-		args = append(args, sportType)
+	if len(existing) > 0 {
+		log.Println("✅ Courts already seeded")
+		return nil
 	}
 
-	rows, err := DB.Query(query, args...)
-	if err != nil {
-		return []Court{}
+	courts := []struct {
+		name      string
+		sportType string
+	}{
+		{"โครงการ A - ตึก 1", "badminton"},
+		{"โครงการ A - ตึก 2", "badminton"},
+		{"โครงการ B - ตึก 1", "badminton"},
+		{"สนาม 1", "basketball"},
+		{"สนาม 2", "basketball"},
+		{"สนาม 3", "basketball"},
+		{"คอร์ต 1", "tennis"},
+		{"คอร์ต 2", "tennis"},
+		{"คอร์ต 3", "tennis"},
+		{"สนาม 1", "volleyball"},
+		{"สนาม 2", "volleyball"},
+		{"สนาม 3", "volleyball"},
 	}
-	defer rows.Close()
 
-	var courts []Court
-	for rows.Next() {
-		var c Court
-		if err := rows.Scan(&c.CourtID, &c.CourtName, &c.SportType, &c.CourtNumber, &c.Status); err != nil {
-			continue
+	// Courts are numbered from 1 within each sport
+	numbers := make(map[string]int)
+	for _, c := range courts {
+		numbers[c.sportType]++
+		court := Court{CourtName: c.name, SportType: c.sportType, CourtNumber: numbers[c.sportType], Status: "Available"}
+		if err := h.Courts.CreateCourt(&court); err != nil {
+			log.Printf("Error seeding court: %v", err)
 		}
-		courts = append(courts, c)
 	}
 
-	return courts
-}
-
-func GetCourtsBySportType(sportType string) []Court {
-	return GetCourtsList(sportType)
-}
-
-func CourtExists(id int) bool {
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM courts WHERE CourtID = $1", id).Scan(&count)
-	if err != nil || count == 0 {
-		return false
-	}
-	return true
+	log.Println("✅ Courts seeded successfully")
+	return nil
 }
@@ -1,57 +1,135 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
-
-	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
 )
 
 // Database-backed booking operations
 
-func CreateBookingDB(userID, courtID int, bookingDate, startTime, endTime string) (int, error) {
-	// Check if court exists
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM courts WHERE CourtID = $1", courtID).Scan(&count)
-	if err != nil || count == 0 {
-		return 0, fmt.Errorf("court not found")
+// activeBookingSQL matches bookings that occupy their slot: confirmed ones and payment holds
+// that have not expired at the time in query parameter nowParam
+func activeBookingSQL(nowParam int) string {
+	return fmt.Sprintf(`(BookingStatus = 'Confirmed' OR (BookingStatus = 'PendingPayment' AND HoldExpiresAt > $%d))`, nowParam)
+}
+
+// NewBooking is everything needed to create a booking
+type NewBooking struct {
+	UserID    int
+	CourtID   int
+	StartTime time.Time
+	EndTime   time.Time
+	Quote     PriceQuote
+	// PaymentMethod is PayByPromptPay (default) or PayByWallet; ignored for free bookings
+	PaymentMethod string
+	// Voucher, if set, has already been applied to Quote and is redeemed with the booking
+	Voucher *Voucher
+}
+
+// CreateBooking checks for conflicts and inserts the booking in one transaction.
+// Paid bookings start as PendingPayment and hold the slot until paymentHold runs out.
+func (s *PostgresStore) CreateBooking(nb NewBooking) (Booking, *Payment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Booking{}, nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the court row so concurrent bookings for the same court are serialised
+	var courtID int
+	err = tx.QueryRow("SELECT CourtID FROM courts WHERE CourtID = $1 FOR UPDATE", nb.CourtID).Scan(&courtID)
+	if err != nil {
+		return Booking{}, nil, errCourtNotFound
 	}
 
 	// Check for conflicts
-	err = DB.QueryRow(
+	var count int
+	err = tx.QueryRow(
 		`SELECT COUNT(*) FROM bookings 
 		 WHERE CourtID = $1 AND 
-		 ((StartTime < $3 AND EndTime > $2))`,
-		courtID, startTime, endTime,
+		 ((StartTime < $3 AND EndTime > $2)) AND `+activeBookingSQL(4),
+		nb.CourtID, nb.StartTime, nb.EndTime, GetCurrentTime(),
 	).Scan(&count)
 
 	if err != nil {
-		return 0, fmt.Errorf("database error: %v", err)
+		return Booking{}, nil, fmt.Errorf("database error: %v", err)
 	}
 	if count > 0 {
-		return 0, fmt.Errorf("court already booked for this time")
+		return Booking{}, nil, errCourtBooked
+	}
+
+	breakdown, err := json.Marshal(nb.Quote.Lines)
+	if err != nil {
+		return Booking{}, nil, fmt.Errorf("failed to encode price breakdown")
+	}
+
+	booking := Booking{
+		CourtID:       nb.CourtID,
+		UserID:        nb.UserID,
+		StartTime:     nb.StartTime,
+		EndTime:       nb.EndTime,
+		BookingStatus: StatusConfirmed,
+		Price:         nb.Quote.Total,
+		Breakdown:     nb.Quote.Lines,
+	}
+
+	var holdExpiresAt *time.Time
+	if booking.Price > 0 && nb.PaymentMethod != PayByWallet {
+		if paymentProvider == nil {
+			return Booking{}, nil, errPaymentsNotConfigured
+		}
+		expires := GetCurrentTime().Add(paymentHold)
+		holdExpiresAt = &expires
+		booking.BookingStatus = StatusPendingPayment
 	}
 
 	// Insert booking
-	var bookingID int
-	err = DB.QueryRow(
-		`INSERT INTO bookings (UserID, CourtID, StartTime, EndTime) 
-		 VALUES ($1, $2, $3, $4) RETURNING BookingID`,
-		userID, courtID, startTime, endTime,
-	).Scan(&bookingID)
+	err = tx.QueryRow(
+		`INSERT INTO bookings (UserID, CourtID, StartTime, EndTime, BookingStatus, Price, PriceBreakdown, HoldExpiresAt) 
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING BookingID, created_at`,
+		nb.UserID, nb.CourtID, nb.StartTime, nb.EndTime, booking.BookingStatus, booking.Price, breakdown, holdExpiresAt,
+	).Scan(&booking.BookingID, &booking.CreatedAt)
 
 	if err != nil {
 		log.Printf("Error creating booking: %v", err)
-		return 0, fmt.Errorf("failed to create booking")
+		return Booking{}, nil, fmt.Errorf("failed to create booking")
 	}
 
-	log.Printf("✅ Booking created (ID: %d, User: %d, Court: %d)", bookingID, userID, courtID)
-	return bookingID, nil
+	if nb.Voucher != nil {
+		if err := redeemVoucherTx(tx, nb.Voucher, nb.UserID, booking.BookingID, nb.Quote.Discount); err != nil {
+			return Booking{}, nil, err
+		}
+	}
+
+	if booking.Price > 0 && nb.PaymentMethod == PayByWallet {
+		if err := debitWalletTx(tx, nb.UserID, booking.Price, booking.BookingID); err != nil {
+			return Booking{}, nil, err
+		}
+	}
+
+	var payment *Payment
+	if holdExpiresAt != nil {
+		payment, err = createPaymentTx(tx, booking, *holdExpiresAt)
+		if err != nil {
+			return Booking{}, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Booking{}, nil, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Booking created (ID: %d, User: %d, Court: %d, Status: %s)", booking.BookingID, nb.UserID, nb.CourtID, booking.BookingStatus)
+	return booking, payment, nil
 }
 
-func GetUserBookingsDB(userID int) ([]Booking, error) {
-	rows, err := DB.Query(
-		`SELECT BookingID, UserID, CourtID, StartTime, EndTime 
+func (s *PostgresStore) UserBookings(userID int) ([]Booking, error) {
+	rows, err := s.db.Query(
+		`SELECT BookingID, UserID, CourtID, StartTime, EndTime, BookingStatus, Price 
 		 FROM bookings WHERE UserID = $1 ORDER BY StartTime DESC`,
 		userID,
 	)
@@ -63,7 +141,7 @@ func GetUserBookingsDB(userID int) ([]Booking, error) {
 	var bookings []Booking
 	for rows.Next() {
 		var b Booking
-		err := rows.Scan(&b.BookingID, &b.UserID, &b.CourtID, &b.StartTime, &b.EndTime)
+		err := rows.Scan(&b.BookingID, &b.UserID, &b.CourtID, &b.StartTime, &b.EndTime, &b.BookingStatus, &b.Price)
 		if err != nil {
 			log.Printf("Error scanning booking: %v", err)
 			continue
@@ -74,141 +152,138 @@ func GetUserBookingsDB(userID int) ([]Booking, error) {
 	return bookings, nil
 }
 
-func DeleteBookingDB(bookingID int) error {
-	result, err := DB.Exec("DELETE FROM bookings WHERE BookingID = $1", bookingID)
-	if err != nil {
-		log.Printf("Error deleting booking: %v", err)
-		return fmt.Errorf("failed to delete booking")
+func (s *PostgresStore) GetBooking(bookingID int) (*Booking, error) {
+	var b Booking
+	var breakdown []byte
+	err := s.db.QueryRow(
+		`SELECT BookingID, UserID, CourtID, StartTime, EndTime, BookingStatus, Price, COALESCE(PriceBreakdown, '[]'), created_at
+		 FROM bookings WHERE BookingID = $1`,
+		bookingID,
+	).Scan(&b.BookingID, &b.UserID, &b.CourtID, &b.StartTime, &b.EndTime, &b.BookingStatus, &b.Price, &breakdown, &b.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, errBookingNotFound
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("database error: %v", err)
+		return nil, fmt.Errorf("database error: %v", err)
 	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("booking not found")
+	if err := json.Unmarshal(breakdown, &b.Breakdown); err != nil {
+		log.Printf("Error decoding price breakdown: %v", err)
 	}
 
-	log.Printf("✅ Booking deleted (ID: %d)", bookingID)
-	return nil
+	return &b, nil
 }
 
-func GetAvailableSlotsDB(courtID int, bookingDate string) (map[string]int, error) {
-	slots := make(map[string]int)
-
-	// Initialize all hours with max 1 court available
-	for hour := 10; hour < 22; hour++ {
-		timeStr := fmt.Sprintf("%02d:00", hour)
-		slots[timeStr] = 1
-	}
-
-	// Get booked slots for this court on this date
-	rows, err := DB.Query(
-		`SELECT StartTime FROM bookings 
-		 WHERE CourtID = $1`,
-		courtID,
+func (s *PostgresStore) UpcomingBookings(courtID int) ([]Booking, error) {
+	rows, err := s.db.Query(
+		`SELECT BookingID, UserID, CourtID, StartTime, EndTime
+		 FROM bookings WHERE StartTime > $2 AND ($1 = 0 OR CourtID = $1) AND `+activeBookingSQL(2)+`
+		 ORDER BY StartTime`,
+		courtID, GetCurrentTime(),
 	)
 	if err != nil {
-		return slots, nil // Return default if error
+		return nil, fmt.Errorf("database error: %v", err)
 	}
 	defer rows.Close()
 
+	var bookings []Booking
 	for rows.Next() {
-		var startTime string
-		if err := rows.Scan(&startTime); err != nil {
+		var b Booking
+		if err := rows.Scan(&b.BookingID, &b.UserID, &b.CourtID, &b.StartTime, &b.EndTime); err != nil {
+			log.Printf("Error scanning booking: %v", err)
 			continue
 		}
-		slots[startTime] = 0
+		bookings = append(bookings, b)
 	}
 
-	return slots, nil
+	return bookings, nil
 }
 
-func ResetAllBookingsDB() error {
-	// Delete all bookings
-	_, err := DB.Exec("DELETE FROM bookings")
+// CancelBooking marks a booking Cancelled. Paid bookings cancelled by staff, or by their owner
+// at least refundCutoff before they start, are refunded: wallet payments go back to the
+// owner's wallet and the amount is returned; payments taken by the provider (PromptPay) never
+// reached the wallet, so they are flagged RefundRequired for an admin to return instead.
+func (s *PostgresStore) CancelBooking(bookingID, actorID int) (Money, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		log.Printf("Error resetting bookings: %v", err)
-		return fmt.Errorf("failed to reset bookings")
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var b Booking
+	err = tx.QueryRow(
+		`SELECT BookingID, UserID, StartTime, BookingStatus, Price
+		 FROM bookings WHERE BookingID = $1 FOR UPDATE`,
+		bookingID,
+	).Scan(&b.BookingID, &b.UserID, &b.StartTime, &b.BookingStatus, &b.Price)
+	if err == sql.ErrNoRows {
+		return 0, errBookingNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	if b.BookingStatus != StatusConfirmed && b.BookingStatus != StatusPendingPayment {
+		return 0, fmt.Errorf("booking is already %s", strings.ToLower(b.BookingStatus))
 	}
 
-	log.Println("✅ All bookings reset")
-	return nil
-}
-
-// Helper function to seed courts if empty
-func SeedCourtsDB() error {
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM courts").Scan(&count)
+	_, err = tx.Exec(
+		"UPDATE bookings SET BookingStatus = $2, HoldExpiresAt = NULL WHERE BookingID = $1",
+		bookingID, StatusCancelled,
+	)
 	if err != nil {
-		return err
+		log.Printf("Error cancelling booking: %v", err)
+		return 0, fmt.Errorf("failed to cancel booking")
 	}
 
-	if count > 0 {
-		log.Println("✅ Courts already seeded")
-		return nil
-	}
-
-	courts := []struct {
-		name      string
-		sportType string
-		details   string
-	}{
-		{"โครงการ A - ตึก 1", "badminton", "คอร์ต 1"},
-		{"โครงการ A - ตึก 2", "badminton", "คอร์ต 2"},
-		{"โครงการ B - ตึก 1", "badminton", "คอร์ต 3"},
-		{"สนาม 1", "basketball", "บาส 1"},
-		{"สนาม 2", "basketball", "บาส 2"},
-		{"สนาม 3", "basketball", "บาส 3"},
-		{"คอร์ต 1", "tennis", "เทนนิส 1"},
-		{"คอร์ต 2", "tennis", "เทนนิส 2"},
-		{"คอร์ต 3", "tennis", "เทนนิส 3"},
-		{"สนาม 1", "volleyball", "วอลเลย์บอล 1"},
-		{"สนาม 2", "volleyball", "วอลเลย์บอล 2"},
-		{"สนาม 3", "volleyball", "วอลเลย์บอล 3"},
-	}
-
-	for _, c := range courts {
-		_, err := DB.Exec(
-			"INSERT INTO courts (CourtName, SportType, CourtNumber, Status) VALUES ($1, $2, $3, $4)",
-			c.name, c.sportType, 1, "Available",
-		)
+	var refund Money
+	if b.BookingStatus == StatusPendingPayment {
+		if _, err := tx.Exec("UPDATE payments SET Status = $2 WHERE BookingID = $1 AND Status = $3", bookingID, PaymentExpired, PaymentPending); err != nil {
+			return 0, fmt.Errorf("database error: %v", err)
+		}
+	} else if b.Price > 0 && (actorID != b.UserID || b.StartTime.Sub(GetCurrentTime()) >= refundCutoff) {
+		result, err := tx.Exec("UPDATE payments SET Status = $2 WHERE BookingID = $1 AND Status = $3", bookingID, PaymentRefundRequired, PaymentPaid)
 		if err != nil {
-			log.Printf("Error seeding court: %v", err)
+			return 0, fmt.Errorf("database error: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("Booking %d was paid through the payment provider; refund required", bookingID)
+		} else {
+			if err := refundWalletTx(tx, b.UserID, b.Price, bookingID, actorID); err != nil {
+				return 0, err
+			}
+			refund = b.Price
 		}
 	}
 
-	log.Println("✅ Courts seeded successfully")
-	return nil
-}
-
-// Helper function to seed admin user
-func SeedAdminDB() error {
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE UserName = 'somchai_k'").Scan(&count)
-	if err != nil {
-		return err
-	}
-
-	if count > 0 {
-		log.Println("✅ Admin user already exists")
-		return nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
 	}
 
-	hashed, _ := bcrypt.GenerateFromPassword([]byte("012345"), bcrypt.DefaultCost)
+	log.Printf("✅ Booking cancelled (ID: %d, Refund: %s)", bookingID, refund)
+	return refund, nil
+}
 
-	_, err = DB.Exec(
-		`INSERT INTO users (FirstName, LastName, UserName, Email, PasswordHash, PhoneNumber, Role) 
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		"Somchai", "Kaewman", "somchai_k", "somchai@uni.th", string(hashed), "088-1111-1111", "Admin",
+func (s *PostgresStore) ActiveBookings(sportType string, from, to time.Time) ([]Booking, error) {
+	rows, err := s.db.Query(
+		`SELECT b.BookingID, b.UserID, b.CourtID, b.StartTime, b.EndTime, b.BookingStatus FROM bookings b
+		 JOIN courts c ON c.CourtID = b.CourtID
+		 WHERE c.SportType = $1 AND b.StartTime < $3 AND b.EndTime > $2 AND `+activeBookingSQL(4),
+		sportType, from, to, GetCurrentTime(),
 	)
-
 	if err != nil {
-		log.Printf("Error seeding admin: %v", err)
-		return err
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	var bookings []Booking
+	for rows.Next() {
+		var b Booking
+		if err := rows.Scan(&b.BookingID, &b.UserID, &b.CourtID, &b.StartTime, &b.EndTime, &b.BookingStatus); err != nil {
+			log.Printf("Error scanning booking: %v", err)
+			continue
+		}
+		bookings = append(bookings, b)
 	}
 
-	log.Println("✅ Admin user seeded successfully")
-	return nil
+	return bookings, nil
 }
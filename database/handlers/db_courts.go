@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Database-backed court operations
+
+func (s *PostgresStore) SportTypes() ([]string, error) {
+	rows, err := s.db.Query("SELECT DISTINCT SportType FROM courts ORDER BY SportType")
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	var sportTypes []string
+	for rows.Next() {
+		var st string
+		if err := rows.Scan(&st); err != nil {
+			continue
+		}
+		sportTypes = append(sportTypes, st)
+	}
+
+	return sportTypes, nil
+}
+
+func (s *PostgresStore) ListCourts(sportType string) ([]Court, error) {
+	var query string
+	var args []interface{}
+
+	if sportType == "" {
+		query = "SELECT CourtID, CourtName, SportType, CourtNumber, Status FROM courts ORDER BY SportType, CourtName"
+	} else {
+		query = "SELECT CourtID, CourtName, SportType, CourtNumber, Status FROM courts WHERE SportType = $1 ORDER BY CourtName"
+		args = append(args, sportType)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	var courts []Court
+	for rows.Next() {
+		var c Court
+		if err := rows.Scan(&c.CourtID, &c.CourtName, &c.SportType, &c.CourtNumber, &c.Status); err != nil {
+			continue
+		}
+		courts = append(courts, c)
+	}
+
+	return courts, nil
+}
+
+func (s *PostgresStore) GetCourt(id int) (*Court, error) {
+	var c Court
+	err := s.db.QueryRow(
+		"SELECT CourtID, CourtName, SportType, CourtNumber, Status FROM courts WHERE CourtID = $1",
+		id,
+	).Scan(&c.CourtID, &c.CourtName, &c.SportType, &c.CourtNumber, &c.Status)
+
+	if err == sql.ErrNoRows {
+		return nil, errCourtNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	return &c, nil
+}
+
+func (s *PostgresStore) CreateCourt(c *Court) error {
+	err := s.db.QueryRow(
+		"INSERT INTO courts (CourtName, SportType, CourtNumber, Status) VALUES ($1, $2, $3, $4) RETURNING CourtID",
+		c.CourtName, c.SportType, c.CourtNumber, c.Status,
+	).Scan(&c.CourtID)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) SetCourtStatus(id int, status string) error {
+	result, err := s.db.Exec("UPDATE courts SET Status = $2 WHERE CourtID = $1", id, status)
+	if err != nil {
+		log.Printf("Error updating court status: %v", err)
+		return fmt.Errorf("failed to update court status")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rowsAffected == 0 {
+		return errCourtNotFound
+	}
+
+	log.Printf("✅ Court %d status set to %s", id, status)
+	return nil
+}
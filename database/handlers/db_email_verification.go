@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Database-backed email verification
+
+// VerifyEmailDB checks the token against the user's current email and marks it verified
+func (h *Handler) VerifyEmailDB(token string) error {
+	userID, expires, signature, err := parseEmailVerificationToken(token)
+	if err != nil {
+		return err
+	}
+
+	var email string
+	var verifiedAt *time.Time
+	err = h.DB.QueryRow("SELECT COALESCE(Email, ''), EmailVerifiedAt FROM users WHERE UserID = $1", userID).Scan(&email, &verifiedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("invalid verification link")
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	expected := emailVerificationSignature(userID, expires, email)
+	if email == "" || !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid verification link")
+	}
+	if verifiedAt != nil {
+		return nil
+	}
+
+	if _, err := h.DB.Exec("UPDATE users SET EmailVerifiedAt = $2 WHERE UserID = $1", userID, GetCurrentTime()); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Email verified (User: %d)", userID)
+	return nil
+}
+
+func (h *Handler) IsEmailVerifiedDB(userID int) (bool, error) {
+	var verified bool
+	err := h.DB.QueryRow("SELECT EmailVerifiedAt IS NOT NULL FROM users WHERE UserID = $1", userID).Scan(&verified)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return false, fmt.Errorf("database error: %v", err)
+	}
+	return verified, nil
+}
+
+// ReserveVerificationSendDB records a verification email for the user unless the resend
+// limits are hit, in which case it returns how long to wait
+func (h *Handler) ReserveVerificationSendDB(userID int) (time.Duration, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise concurrent resends of the same user
+	if _, err := tx.Exec("SELECT 1 FROM users WHERE UserID = $1 FOR UPDATE", userID); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	now := GetCurrentTime()
+	var sentToday int
+	var last, oldest *time.Time
+	err = tx.QueryRow(
+		`SELECT COUNT(*), MAX(created_at), MIN(created_at) FROM email_verification_sends
+		 WHERE UserID = $1 AND created_at > $2`,
+		userID, now.Add(-24*time.Hour),
+	).Scan(&sentToday, &last, &oldest)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	if last != nil {
+		if wait := last.Add(verificationResendCooldown).Sub(now); wait > 0 {
+			return wait, nil
+		}
+	}
+	if sentToday >= verificationDailyLimit && oldest != nil {
+		return oldest.Add(24 * time.Hour).Sub(now), nil
+	}
+
+	if _, err := tx.Exec("INSERT INTO email_verification_sends (UserID, created_at) VALUES ($1, $2)", userID, now); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM email_verification_sends WHERE UserID = $1 AND created_at < $2", userID, now.Add(-24*time.Hour)); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Database-backed login attempt tracking and lockouts. Lockout rows double as the audit trail.
+
+// userFailuresSQL and ipFailuresSQL count failures since the window start, the subject's last
+// lockout or unlock, and (for usernames) the last successful login
+const userFailuresSQL = `SELECT COUNT(*) FROM login_attempts
+	WHERE UserName = $1 AND NOT Success AND created_at > GREATEST(
+		$2,
+		(SELECT MAX(created_at) FROM login_attempts WHERE UserName = $1 AND Success),
+		(SELECT MAX(GREATEST(created_at, UnlockedAt)) FROM login_lockouts WHERE Scope = 'user' AND Subject = $1)
+	)`
+
+const ipFailuresSQL = `SELECT COUNT(*) FROM login_attempts
+	WHERE IPAddress = $1 AND NOT Success AND created_at > GREATEST(
+		$2,
+		(SELECT MAX(GREATEST(created_at, UnlockedAt)) FROM login_lockouts WHERE Scope = 'ip' AND Subject = $1)
+	)`
+
+func countFailures(q queryer, query, subject string) (int, error) {
+	var n int
+	if err := q.QueryRow(query, subject, GetCurrentTime().Add(-loginWindow)).Scan(&n); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	return n, nil
+}
+
+// CheckLoginThrottleDB reports an active lockout of the username or IP and how long to
+// delay this attempt
+func (h *Handler) CheckLoginThrottleDB(username, ip string) (LoginThrottle, error) {
+	var t LoginThrottle
+	subject := loginSubject(username)
+
+	err := h.DB.QueryRow(
+		`SELECT MAX(LockedUntil) FROM login_lockouts
+		 WHERE UnlockedAt IS NULL AND LockedUntil > $3
+		 AND ((Scope = 'user' AND Subject = $1) OR (Scope = 'ip' AND Subject = $2))`,
+		subject, ip, GetCurrentTime(),
+	).Scan(&t.LockedUntil)
+	if err != nil {
+		return t, fmt.Errorf("database error: %v", err)
+	}
+	if t.LockedUntil != nil {
+		return t, nil
+	}
+
+	failures, err := countFailures(h.DB, userFailuresSQL, subject)
+	if err != nil {
+		return t, err
+	}
+	t.Delay = loginDelay(failures)
+	return t, nil
+}
+
+// RecordLoginAttemptDB stores the attempt and locks the username and/or IP once
+// their failure thresholds are reached
+func (h *Handler) RecordLoginAttemptDB(username, ip string, success bool) error {
+	subject := loginSubject(username)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise attempts for the same username so a threshold only locks once
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('login:' || $1))", subject); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO login_attempts (UserName, IPAddress, Success, created_at) VALUES ($1, $2, $3, $4)",
+		subject, ip, success, GetCurrentTime(),
+	); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if !success {
+		userFailures, err := countFailures(tx, userFailuresSQL, subject)
+		if err != nil {
+			return err
+		}
+		if userFailures >= userFailureThreshold {
+			if err := lockTx(tx, LockoutUser, subject, userFailures); err != nil {
+				return err
+			}
+		}
+
+		ipFailures, err := countFailures(tx, ipFailuresSQL, ip)
+		if err != nil {
+			return err
+		}
+		if ipFailures >= ipFailureThreshold {
+			if err := lockTx(tx, LockoutIP, ip, ipFailures); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
+
+func lockTx(tx *sql.Tx, scope, subject string, failures int) error {
+	now := GetCurrentTime()
+	var previous int
+	err := tx.QueryRow(
+		"SELECT COUNT(*) FROM login_lockouts WHERE Scope = $1 AND Subject = $2 AND Failures > 0 AND created_at > $3",
+		scope, subject, now.Add(-24*time.Hour),
+	).Scan(&previous)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	until := now.Add(lockoutDuration(previous))
+	_, err = tx.Exec(
+		"INSERT INTO login_lockouts (Scope, Subject, Failures, LockedUntil, created_at) VALUES ($1, $2, $3, $4, $5)",
+		scope, subject, failures, until, now,
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("Warning: login locked (%s %q) after %d failures until %s", scope, subject, failures, until.Format(time.RFC3339))
+	return nil
+}
+
+func (h *Handler) GetLockoutsDB(activeOnly bool, limit, offset int) ([]LoginLockout, error) {
+	query := `SELECT LockoutID, Scope, Subject, Failures, LockedUntil, UnlockedAt, UnlockedBy, created_at FROM login_lockouts`
+	if activeOnly {
+		query += ` WHERE UnlockedAt IS NULL AND LockedUntil > $3`
+	}
+	query += ` ORDER BY LockoutID DESC LIMIT $1 OFFSET $2`
+
+	args := []interface{}{limit, offset}
+	if activeOnly {
+		args = append(args, GetCurrentTime())
+	}
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	lockouts := []LoginLockout{}
+	for rows.Next() {
+		var l LoginLockout
+		if err := rows.Scan(&l.LockoutID, &l.Scope, &l.Subject, &l.Failures, &l.LockedUntil, &l.UnlockedAt, &l.UnlockedBy, &l.CreatedAt); err != nil {
+			log.Printf("Error scanning lockout: %v", err)
+			continue
+		}
+		lockouts = append(lockouts, l)
+	}
+
+	return lockouts, nil
+}
+
+func (h *Handler) UnlockLockoutDB(lockoutID, adminID int) error {
+	result, err := h.DB.Exec(
+		"UPDATE login_lockouts SET UnlockedAt = $3, UnlockedBy = $2 WHERE LockoutID = $1 AND UnlockedAt IS NULL",
+		lockoutID, adminID, GetCurrentTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("lockout not found or already lifted")
+	}
+
+	log.Printf("✅ Lockout %d lifted by admin %d", lockoutID, adminID)
+	return nil
+}
+
+// UnlockUsernameDB lifts every lockout of the username and resets its failure count
+func (h *Handler) UnlockUsernameDB(subject string, adminID int) (int, error) {
+	now := GetCurrentTime()
+	result, err := h.DB.Exec(
+		`UPDATE login_lockouts SET UnlockedAt = $3, UnlockedBy = $2
+		 WHERE Scope = 'user' AND Subject = $1 AND UnlockedAt IS NULL`,
+		subject, adminID, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	rows, _ := result.RowsAffected()
+
+	// Record a lifted zero-length lockout so failures before the unlock no longer count
+	if rows == 0 {
+		_, err = h.DB.Exec(
+			`INSERT INTO login_lockouts (Scope, Subject, Failures, LockedUntil, UnlockedAt, UnlockedBy, created_at)
+			 VALUES ('user', $1, 0, $3, $3, $2, $3)`,
+			subject, adminID, now,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("database error: %v", err)
+		}
+	}
+
+	log.Printf("✅ Login for %q unlocked by admin %d", subject, adminID)
+	return int(rows), nil
+}
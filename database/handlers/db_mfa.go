@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Database-backed two-factor authentication: TOTP secrets, recovery codes and the pending
+// second step of a login
+
+func (h *Handler) IsMFAEnabledDB(userID int) (bool, error) {
+	var enabled bool
+	err := h.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM user_mfa WHERE UserID = $1 AND Enabled)", userID).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("database error: %v", err)
+	}
+	return enabled, nil
+}
+
+func (h *Handler) GetMFAStatusDB(userID int) (MFAStatus, error) {
+	var status MFAStatus
+	err := h.DB.QueryRow(
+		`SELECT COALESCE(m.Enabled, FALSE), m.EnabledAt,
+		        (SELECT COUNT(*) FROM mfa_recovery_codes WHERE UserID = $1 AND UsedAt IS NULL)
+		 FROM (SELECT 1) one LEFT JOIN user_mfa m ON m.UserID = $1`,
+		userID,
+	).Scan(&status.Enabled, &status.EnabledAt, &status.RecoveryCodesLeft)
+	if err != nil {
+		return status, fmt.Errorf("database error: %v", err)
+	}
+	return status, nil
+}
+
+// BeginMFASetupDB stores a new, not yet confirmed TOTP secret for the user
+func (h *Handler) BeginMFASetupDB(userID int) ([]byte, error) {
+	if enabled, err := h.IsMFAEnabledDB(userID); err != nil {
+		return nil, err
+	} else if enabled {
+		return nil, errMFAAlreadyEnabled
+	}
+
+	secret := make([]byte, 20)
+	rand.Read(secret)
+	sealed, err := encryptMFASecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt secret: %v", err)
+	}
+
+	_, err = h.DB.Exec(
+		`INSERT INTO user_mfa (UserID, Secret, Enabled) VALUES ($1, $2, FALSE)
+		 ON CONFLICT (UserID) DO UPDATE SET Secret = EXCLUDED.Secret, LastUsedStep = 0
+		 WHERE NOT user_mfa.Enabled`,
+		userID, sealed,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return secret, nil
+}
+
+// EnableMFADB confirms the pending secret with a code from the app and returns new
+// recovery codes. Every existing session is revoked.
+func (h *Handler) EnableMFADB(userID int, code string) ([]string, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var sealed string
+	var enabled bool
+	var lastStep int64
+	err = tx.QueryRow("SELECT Secret, Enabled, LastUsedStep FROM user_mfa WHERE UserID = $1 FOR UPDATE", userID).Scan(&sealed, &enabled, &lastStep)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("start two-factor setup first")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if enabled {
+		return nil, errMFAAlreadyEnabled
+	}
+
+	secret, err := decryptMFASecret(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt secret: %v", err)
+	}
+	now := GetCurrentTime()
+	step, ok := matchTOTP(secret, normalizeMFACode(code), lastStep, now)
+	if !ok {
+		return nil, errInvalidMFACode
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE user_mfa SET Enabled = TRUE, EnabledAt = $3, LastUsedStep = $2 WHERE UserID = $1",
+		userID, step, now,
+	); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	codes, err := replaceRecoveryCodesTx(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := revokeAllSessionsTx(tx, userID, "mfa enabled"); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Two-factor authentication enabled (User: %d)", userID)
+	return codes, nil
+}
+
+// DisableMFADB turns 2FA off after checking a TOTP or recovery code
+func (h *Handler) DisableMFADB(userID int, code string) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkMFACodeTx(tx, userID, code); err != nil {
+		return err
+	}
+	if err := deleteMFATx(tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Two-factor authentication disabled (User: %d)", userID)
+	return nil
+}
+
+func (h *Handler) RegenerateRecoveryCodesDB(userID int, code string) ([]string, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkMFACodeTx(tx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodesTx(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return codes, nil
+}
+
+// ResetMFADB removes a user's 2FA (admin action) and logs the user out everywhere
+func (h *Handler) ResetMFADB(userID, adminID int) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM user_mfa WHERE UserID = $1)", userID).Scan(&exists); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if !exists {
+		return errMFANotEnabled
+	}
+
+	if err := deleteMFATx(tx, userID); err != nil {
+		return err
+	}
+	if err := revokeAllSessionsTx(tx, userID, "mfa reset"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("Warning: two-factor authentication of user %d reset by admin %d", userID, adminID)
+	return nil
+}
+
+// CreateMFAChallengeDB starts the second login step and returns its token
+func (h *Handler) CreateMFAChallengeDB(userID int) (string, error) {
+	now := GetCurrentTime()
+	if _, err := h.DB.Exec("DELETE FROM mfa_challenges WHERE ExpiresAt < $1", now); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	token, hash := newOpaqueToken()
+	_, err := h.DB.Exec(
+		"INSERT INTO mfa_challenges (ChallengeHash, UserID, ExpiresAt) VALUES ($1, $2, $3)",
+		hash, userID, now.Add(mfaChallengeTTL),
+	)
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	return token, nil
+}
+
+// VerifyMFAChallengeDB completes the second login step. A challenge allows a few wrong
+// codes before it is discarded and the user has to sign in again.
+func (h *Handler) VerifyMFAChallengeDB(token, code string) (*User, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	hash := hashOpaqueToken(token)
+	var userID, attempts int
+	err = tx.QueryRow(
+		"SELECT UserID, Attempts FROM mfa_challenges WHERE ChallengeHash = $1 AND ExpiresAt > $2 FOR UPDATE",
+		hash, GetCurrentTime(),
+	).Scan(&userID, &attempts)
+	if err == sql.ErrNoRows {
+		return nil, errInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	checkErr := checkMFACodeTx(tx, userID, code)
+	if checkErr != nil && checkErr != errInvalidMFACode {
+		return nil, checkErr
+	}
+
+	if checkErr == errInvalidMFACode && attempts+1 < mfaChallengeTries {
+		_, err = tx.Exec("UPDATE mfa_challenges SET Attempts = Attempts + 1 WHERE ChallengeHash = $1", hash)
+	} else {
+		_, err = tx.Exec("DELETE FROM mfa_challenges WHERE ChallengeHash = $1", hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if checkErr != nil {
+		return nil, checkErr
+	}
+
+	return h.Users.GetUser(userID)
+}
+
+// checkMFACodeTx accepts a current TOTP code or spends an unused recovery code
+func checkMFACodeTx(tx *sql.Tx, userID int, code string) error {
+	var sealed string
+	var lastStep int64
+	err := tx.QueryRow(
+		"SELECT Secret, LastUsedStep FROM user_mfa WHERE UserID = $1 AND Enabled FOR UPDATE",
+		userID,
+	).Scan(&sealed, &lastStep)
+	if err == sql.ErrNoRows {
+		return errMFANotEnabled
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	code = normalizeMFACode(code)
+	if isTOTPCode(code) {
+		secret, err := decryptMFASecret(sealed)
+		if err != nil {
+			return fmt.Errorf("cannot decrypt secret: %v", err)
+		}
+		step, ok := matchTOTP(secret, code, lastStep, GetCurrentTime())
+		if !ok {
+			return errInvalidMFACode
+		}
+		if _, err := tx.Exec("UPDATE user_mfa SET LastUsedStep = $2 WHERE UserID = $1", userID, step); err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		return nil
+	}
+
+	result, err := tx.Exec(
+		"UPDATE mfa_recovery_codes SET UsedAt = $3 WHERE UserID = $1 AND CodeHash = $2 AND UsedAt IS NULL",
+		userID, hashOpaqueToken(code), GetCurrentTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errInvalidMFACode
+	}
+
+	log.Printf("✅ Recovery code used (User: %d)", userID)
+	return nil
+}
+
+func replaceRecoveryCodesTx(tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE UserID = $1", userID); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	codes, hashes := newRecoveryCodes()
+	for _, h := range hashes {
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (UserID, CodeHash) VALUES ($1, $2)", userID, h); err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+	}
+	return codes, nil
+}
+
+func deleteMFATx(tx *sql.Tx, userID int) error {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE UserID = $1", userID); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM mfa_challenges WHERE UserID = $1", userID); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM user_mfa WHERE UserID = $1", userID); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
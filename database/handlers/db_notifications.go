@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Database-backed notification operations
+
+func (h *Handler) GetRecipientDB(userID int) (Recipient, error) {
+	var to Recipient
+	err := h.DB.QueryRow(
+		`SELECT UserID, FirstName, COALESCE(Email, ''), COALESCE(LineUserID, '')
+		 FROM users WHERE UserID = $1`,
+		userID,
+	).Scan(&to.UserID, &to.Name, &to.Email, &to.LineUserID)
+
+	if err == sql.ErrNoRows {
+		return Recipient{}, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return Recipient{}, fmt.Errorf("database error: %v", err)
+	}
+
+	return to, nil
+}
+
+func (h *Handler) SetLineUserIDDB(userID int, lineUserID string) error {
+	_, err := h.DB.Exec(
+		"UPDATE users SET LineUserID = NULLIF($2, '') WHERE UserID = $1",
+		userID, lineUserID,
+	)
+	if err != nil {
+		log.Printf("Error updating LINE user id: %v", err)
+		return fmt.Errorf("failed to update LINE user id")
+	}
+	return nil
+}
+
+// GetNotificationPreferencesDB returns the defaults overlaid with the user's saved choices
+func (h *Handler) GetNotificationPreferencesDB(userID int) (NotificationPreferences, error) {
+	prefs := DefaultNotificationPreferences()
+
+	rows, err := h.DB.Query(
+		"SELECT EventType, Channel, Enabled FROM notification_preferences WHERE UserID = $1",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event, channel string
+		var enabled bool
+		if err := rows.Scan(&event, &channel, &enabled); err != nil {
+			log.Printf("Error scanning notification preference: %v", err)
+			continue
+		}
+		if _, ok := prefs[event]; !ok {
+			continue
+		}
+		prefs[event][channel] = enabled
+	}
+
+	return prefs, nil
+}
+
+func (h *Handler) SaveNotificationPreferencesDB(userID int, prefs NotificationPreferences) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	for event, channels := range prefs {
+		for channel, enabled := range channels {
+			_, err := tx.Exec(
+				`INSERT INTO notification_preferences (UserID, EventType, Channel, Enabled)
+				 VALUES ($1, $2, $3, $4)
+				 ON CONFLICT (UserID, EventType, Channel) DO UPDATE SET Enabled = EXCLUDED.Enabled`,
+				userID, event, channel, enabled,
+			)
+			if err != nil {
+				log.Printf("Error saving notification preference: %v", err)
+				return fmt.Errorf("failed to save notification preferences")
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (h *Handler) CreateNotificationDB(userID int, n Notification) (int, error) {
+	var notificationID int
+	err := h.DB.QueryRow(
+		`INSERT INTO notifications (UserID, EventType, Title, Message)
+		 VALUES ($1, $2, $3, $4) RETURNING NotificationID`,
+		userID, n.Event, n.Title, n.Message,
+	).Scan(&notificationID)
+
+	if err != nil {
+		log.Printf("Error creating notification: %v", err)
+		return 0, fmt.Errorf("failed to create notification")
+	}
+
+	return notificationID, nil
+}
+
+func (h *Handler) GetPushSubscriptionsDB(userID int) ([]PushSubscription, error) {
+	rows, err := h.DB.Query(
+		"SELECT Endpoint, P256dh, Auth FROM push_subscriptions WHERE UserID = $1",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	var subs []PushSubscription
+	for rows.Next() {
+		var s PushSubscription
+		if err := rows.Scan(&s.Endpoint, &s.P256dh, &s.Auth); err != nil {
+			log.Printf("Error scanning push subscription: %v", err)
+			continue
+		}
+		subs = append(subs, s)
+	}
+
+	return subs, nil
+}
+
+var errPushSubscriptionTaken = fmt.Errorf("push endpoint is registered to another account")
+
+// SavePushSubscriptionDB adds a subscription or refreshes the keys of one the user already has.
+// An endpoint saved by another user is not taken over.
+func (h *Handler) SavePushSubscriptionDB(userID int, sub PushSubscription) error {
+	result, err := h.DB.Exec(
+		`INSERT INTO push_subscriptions (UserID, Endpoint, P256dh, Auth)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (Endpoint) DO UPDATE
+		 SET P256dh = EXCLUDED.P256dh, Auth = EXCLUDED.Auth
+		 WHERE push_subscriptions.UserID = EXCLUDED.UserID`,
+		userID, sub.Endpoint, sub.P256dh, sub.Auth,
+	)
+	if err != nil {
+		log.Printf("Error saving push subscription: %v", err)
+		return fmt.Errorf("failed to save push subscription")
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errPushSubscriptionTaken
+	}
+	return nil
+}
+
+func (h *Handler) DeletePushSubscriptionDB(userID int, endpoint string) error {
+	result, err := h.DB.Exec(
+		"DELETE FROM push_subscriptions WHERE UserID = $1 AND Endpoint = $2",
+		userID, endpoint,
+	)
+	if err != nil {
+		log.Printf("Error deleting push subscription: %v", err)
+		return fmt.Errorf("failed to delete push subscription")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("push subscription not found")
+	}
+	return nil
+}
+
+func (h *Handler) GetNotificationsDB(userID int, unreadOnly bool, limit, offset int) ([]Notification, int, error) {
+	filter := ""
+	if unreadOnly {
+		filter = " AND ReadAt IS NULL"
+	}
+
+	var total int
+	err := h.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE UserID = $1"+filter, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("database error: %v", err)
+	}
+
+	rows, err := h.DB.Query(
+		`SELECT NotificationID, UserID, EventType, Title, Message, ReadAt, created_at
+		 FROM notifications WHERE UserID = $1`+filter+`
+		 ORDER BY created_at DESC, NotificationID DESC LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		err := rows.Scan(&n.NotificationID, &n.UserID, &n.Event, &n.Title, &n.Message, &n.ReadAt, &n.CreatedAt)
+		if err != nil {
+			log.Printf("Error scanning notification: %v", err)
+			continue
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, total, nil
+}
+
+func (h *Handler) CountUnreadNotificationsDB(userID int) (int, error) {
+	var count int
+	err := h.DB.QueryRow(
+		"SELECT COUNT(*) FROM notifications WHERE UserID = $1 AND ReadAt IS NULL",
+		userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	return count, nil
+}
+
+func (h *Handler) MarkNotificationReadDB(userID, notificationID int) error {
+	result, err := h.DB.Exec(
+		`UPDATE notifications SET ReadAt = COALESCE(ReadAt, $3)
+		 WHERE NotificationID = $1 AND UserID = $2`,
+		notificationID, userID, GetCurrentTime(),
+	)
+	if err != nil {
+		log.Printf("Error marking notification read: %v", err)
+		return fmt.Errorf("failed to update notification")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("notification not found")
+	}
+	return nil
+}
+
+func (h *Handler) MarkAllNotificationsReadDB(userID int) (int64, error) {
+	result, err := h.DB.Exec(
+		"UPDATE notifications SET ReadAt = $2 WHERE UserID = $1 AND ReadAt IS NULL",
+		userID, GetCurrentTime(),
+	)
+	if err != nil {
+		log.Printf("Error marking notifications read: %v", err)
+		return 0, fmt.Errorf("failed to update notifications")
+	}
+	return result.RowsAffected()
+}
+
+func (h *Handler) DeleteNotificationDB(userID, notificationID int) error {
+	result, err := h.DB.Exec(
+		"DELETE FROM notifications WHERE NotificationID = $1 AND UserID = $2",
+		notificationID, userID,
+	)
+	if err != nil {
+		log.Printf("Error deleting notification: %v", err)
+		return fmt.Errorf("failed to delete notification")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("notification not found")
+	}
+	return nil
+}
+
+func (h *Handler) GetAllUserIDsDB() ([]int, error) {
+	rows, err := h.DB.Query("SELECT UserID FROM users ORDER BY UserID")
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
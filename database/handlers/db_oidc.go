@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Database-backed SSO logins and the links between IdP identities and local users
+
+var errInvalidOIDCState = fmt.Errorf("invalid or expired sign-in request, please start again")
+
+// CreateOIDCLoginDB stores a started login; only the hash of the state is kept
+func (h *Handler) CreateOIDCLoginDB(state, nonce, verifier string) error {
+	now := GetCurrentTime()
+	if _, err := h.DB.Exec("DELETE FROM oidc_logins WHERE ExpiresAt < $1", now); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	_, err := h.DB.Exec(
+		"INSERT INTO oidc_logins (StateHash, Nonce, CodeVerifier, ExpiresAt) VALUES ($1, $2, $3, $4)",
+		hashOpaqueToken(state), nonce, verifier, now.Add(oidcLoginTTL),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCLoginDB removes the login of state and returns its nonce and PKCE verifier
+func (h *Handler) ConsumeOIDCLoginDB(state string) (string, string, error) {
+	var nonce, verifier string
+	err := h.DB.QueryRow(
+		"DELETE FROM oidc_logins WHERE StateHash = $1 AND ExpiresAt > $2 RETURNING Nonce, CodeVerifier",
+		hashOpaqueToken(state), GetCurrentTime(),
+	).Scan(&nonce, &verifier)
+	if err == sql.ErrNoRows {
+		return "", "", errInvalidOIDCState
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("database error: %v", err)
+	}
+	return nonce, verifier, nil
+}
+
+// LoginOIDCUserDB returns the local user of the identity. Unknown identities are linked to an
+// existing account only when both sides have verified the same email, otherwise they get a
+// new account.
+func (h *Handler) LoginOIDCUserDB(id OIDCIdentity) (*User, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := GetCurrentTime()
+	var userID int
+	err = tx.QueryRow(
+		"UPDATE user_identities SET Email = $3, LastLoginAt = $4 WHERE Issuer = $1 AND Subject = $2 RETURNING UserID",
+		id.Issuer, id.Subject, id.Email, now,
+	).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	if err == sql.ErrNoRows {
+		userID, err = linkOIDCIdentityTx(tx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The university has confirmed the address
+	if id.EmailVerified && id.Email != "" {
+		if _, err := tx.Exec(
+			"UPDATE users SET EmailVerifiedAt = $3 WHERE UserID = $1 AND EmailVerifiedAt IS NULL AND LOWER(Email) = $2",
+			userID, id.Email, now,
+		); err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ SSO login (User: %d, Subject: %s)", userID, id.Subject)
+	return h.Users.GetUser(userID)
+}
+
+func linkOIDCIdentityTx(tx *sql.Tx, id OIDCIdentity) (int, error) {
+	var userID int
+	err := sql.ErrNoRows
+
+	// Only an email verified by the IdP may claim an existing account, otherwise anyone could
+	// take over an account by setting its address at the IdP, and only an account that proved
+	// it owns the address, otherwise anyone could register it first and wait. Student IDs are
+	// typed in by users and never link accounts.
+	if id.Email != "" && id.EmailVerified {
+		err = tx.QueryRow(
+			"SELECT UserID FROM users WHERE LOWER(Email) = $1 AND EmailVerifiedAt IS NOT NULL",
+			id.Email,
+		).Scan(&userID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	if err == sql.ErrNoRows {
+		userID, err = createOIDCUserTx(tx, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO user_identities (Issuer, Subject, UserID, Email, LastLoginAt) VALUES ($1, $2, $3, $4, $5)",
+		id.Issuer, id.Subject, userID, id.Email, GetCurrentTime(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Linked SSO identity %s to user %d", id.Subject, userID)
+	return userID, nil
+}
+
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// createOIDCUserTx creates an account for a first-time SSO user. It has no usable password
+// ("!" never matches a bcrypt hash); the user can set one through the password reset flow.
+func createOIDCUserTx(tx *sql.Tx, id OIDCIdentity) (int, error) {
+	if id.Email == "" {
+		return 0, fmt.Errorf("the identity provider did not share an email address")
+	}
+	if !EmailDomainAllowed(id.Email) {
+		return 0, fmt.Errorf("registration is limited to university email addresses")
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(Email) = $1)", id.Email).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	if exists {
+		return 0, fmt.Errorf("an account with this email already exists; verify its email address, then sign in with single sign-on again")
+	}
+
+	base := id.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(id.Email, "@", 2)[0]
+	}
+	base = usernameUnsafe.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = "user"
+	}
+	if IsReservedUserName(base) {
+		base = "user-" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	username := base
+	for n := 2; ; n++ {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE UserName = $1)", username).Scan(&taken); err != nil {
+			return 0, fmt.Errorf("database error: %v", err)
+		}
+		if !taken {
+			break
+		}
+		username = base + strconv.Itoa(n)
+	}
+
+	firstName := id.GivenName
+	if firstName == "" {
+		firstName = username
+	}
+	// A student ID already typed into another account is left for an admin to sort out
+	var studentID *string
+	if id.StudentID != "" {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE StudentID = $1)", id.StudentID).Scan(&taken); err != nil {
+			return 0, fmt.Errorf("database error: %v", err)
+		}
+		if taken {
+			log.Printf("Student ID of SSO user %s is used by another account; not copied", id.Subject)
+		} else {
+			studentID = &id.StudentID
+		}
+	}
+	var verifiedAt *time.Time
+	if id.EmailVerified {
+		now := GetCurrentTime()
+		verifiedAt = &now
+	}
+
+	var userID int
+	err := tx.QueryRow(
+		`INSERT INTO users (FirstName, LastName, UserName, Email, PasswordHash, StudentID, Role, EmailVerifiedAt)
+		 VALUES ($1, $2, $3, $4, '!', $5, 'Member', $6) RETURNING UserID`,
+		firstName, id.FamilyName, username, id.Email, studentID, verifiedAt,
+	).Scan(&userID)
+	if err != nil {
+		log.Printf("Error inserting SSO user: %v", err)
+		return 0, fmt.Errorf("failed to create user")
+	}
+
+	log.Printf("✅ User %s created from SSO (ID: %d)", username, userID)
+	return userID, nil
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Database-backed password resets. Only the SHA-256 of a reset token is stored.
+
+var errInvalidResetToken = fmt.Errorf("invalid or expired reset token")
+
+// CreatePasswordResetDB returns a fresh reset token for the account with email, replacing
+// any outstanding one. user is nil when no account matches.
+func (h *Handler) CreatePasswordResetDB(email, ip string) (*User, string, error) {
+	var user User
+	err := h.DB.QueryRow(
+		"SELECT UserID, FirstName, UserName, Email FROM users WHERE LOWER(Email) = LOWER($1)",
+		email,
+	).Scan(&user.UserID, &user.FirstName, &user.UserName, &user.Email)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("database error: %v", err)
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, "", fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := GetCurrentTime()
+	if _, err := tx.Exec("UPDATE password_resets SET UsedAt = $2 WHERE UserID = $1 AND UsedAt IS NULL", user.UserID, now); err != nil {
+		return nil, "", fmt.Errorf("database error: %v", err)
+	}
+
+	token, hash := newOpaqueToken()
+	_, err = tx.Exec(
+		"INSERT INTO password_resets (UserID, TokenHash, ExpiresAt, RequestIP) VALUES ($1, $2, $3, $4)",
+		user.UserID, hash, now.Add(passwordResetTTL), ip,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Password reset requested (User: %d)", user.UserID)
+	return &user, token, nil
+}
+
+// ResetPasswordDB spends the token, sets the new password and revokes every session
+func (h *Handler) ResetPasswordDB(token, newPassword string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("cannot hash password")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := GetCurrentTime()
+	var resetID, userID int
+	err = tx.QueryRow(
+		`SELECT ResetID, UserID FROM password_resets
+		 WHERE TokenHash = $1 AND UsedAt IS NULL AND ExpiresAt > $2
+		 FOR UPDATE`,
+		hashOpaqueToken(token), now,
+	).Scan(&resetID, &userID)
+	if err == sql.ErrNoRows {
+		return errInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if _, err := tx.Exec("UPDATE password_resets SET UsedAt = $2 WHERE ResetID = $1", resetID, now); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE users SET PasswordHash = $2 WHERE UserID = $1", userID, string(hashed)); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if err := revokeAllSessionsTx(tx, userID, "password reset"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Password reset completed (User: %d)", userID)
+	return nil
+}
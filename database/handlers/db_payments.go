@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Database-backed payment operations
+
+func createPaymentTx(tx *sql.Tx, booking Booking, expiresAt time.Time) (*Payment, error) {
+	charge, err := paymentProvider.CreateCharge(newPaymentReference(), booking.Price)
+	if err != nil {
+		log.Printf("Error creating charge: %v", err)
+		return nil, fmt.Errorf("failed to create payment")
+	}
+
+	p := &Payment{
+		BookingID: booking.BookingID,
+		Provider:  paymentProvider.Name(),
+		Reference: charge.Reference,
+		Amount:    booking.Price,
+		Status:    PaymentPending,
+		QRPayload: charge.QRPayload,
+		ExpiresAt: expiresAt,
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO payments (BookingID, Provider, Reference, Amount, Status, QRPayload, ExpiresAt)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING PaymentID`,
+		p.BookingID, p.Provider, p.Reference, p.Amount, p.Status, p.QRPayload, p.ExpiresAt,
+	).Scan(&p.PaymentID)
+	if err != nil {
+		log.Printf("Error creating payment: %v", err)
+		return nil, fmt.Errorf("failed to create payment")
+	}
+
+	return p, nil
+}
+
+const paymentColumns = "PaymentID, BookingID, Provider, Reference, Amount, Status, QRPayload, ExpiresAt, PaidAt"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner) (*Payment, error) {
+	var p Payment
+	err := row.Scan(&p.PaymentID, &p.BookingID, &p.Provider, &p.Reference, &p.Amount, &p.Status, &p.QRPayload, &p.ExpiresAt, &p.PaidAt)
+	if err == sql.ErrNoRows {
+		return nil, errPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return &p, nil
+}
+
+func (h *Handler) GetPaymentByBookingDB(bookingID int) (*Payment, error) {
+	return scanPayment(h.DB.QueryRow(
+		"SELECT "+paymentColumns+" FROM payments WHERE BookingID = $1 ORDER BY PaymentID DESC LIMIT 1",
+		bookingID,
+	))
+}
+
+// ConfirmPaymentDB records a successful payment and confirms its booking. A payment that
+// arrives after the hold expired still confirms the booking if nobody has taken the slot;
+// otherwise it is flagged RefundRequired for an admin. Bookings that are no longer waiting
+// for payment (cancelled by the user, for one) keep their status and the payment is flagged.
+func (h *Handler) ConfirmPaymentDB(reference string, amount Money) (*Payment, *Booking, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	p, err := scanPayment(tx.QueryRow(
+		"SELECT "+paymentColumns+" FROM payments WHERE Reference = $1 FOR UPDATE",
+		reference,
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+	if amount != p.Amount {
+		return nil, nil, fmt.Errorf("amount mismatch: expected %s", p.Amount)
+	}
+
+	var b Booking
+	var holdExpiresAt sql.NullTime
+	err = tx.QueryRow(
+		`SELECT BookingID, UserID, CourtID, StartTime, EndTime, BookingStatus, Price, HoldExpiresAt
+		 FROM bookings WHERE BookingID = $1 FOR UPDATE`,
+		p.BookingID,
+	).Scan(&b.BookingID, &b.UserID, &b.CourtID, &b.StartTime, &b.EndTime, &b.BookingStatus, &b.Price, &holdExpiresAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("booking not found")
+	}
+
+	// Provider retries are fine
+	if p.Status == PaymentPaid || p.Status == PaymentRefundRequired || p.Status == PaymentRefunded {
+		return p, &b, nil
+	}
+
+	awaiting := b.BookingStatus == StatusPendingPayment || b.BookingStatus == StatusExpired
+	confirm := b.BookingStatus == StatusPendingPayment && holdExpiresAt.Valid && holdExpiresAt.Time.After(GetCurrentTime())
+	if !confirm && awaiting {
+		if _, err := tx.Exec("SELECT CourtID FROM courts WHERE CourtID = $1 FOR UPDATE", b.CourtID); err != nil {
+			return nil, nil, fmt.Errorf("database error: %v", err)
+		}
+		var count int
+		err = tx.QueryRow(
+			`SELECT COUNT(*) FROM bookings
+			 WHERE CourtID = $1 AND BookingID <> $4 AND StartTime < $3 AND EndTime > $2 AND `+activeBookingSQL(5),
+			b.CourtID, b.StartTime, b.EndTime, b.BookingID, GetCurrentTime(),
+		).Scan(&count)
+		if err != nil {
+			return nil, nil, fmt.Errorf("database error: %v", err)
+		}
+		confirm = count == 0
+	}
+
+	now := GetCurrentTime()
+	p.PaidAt = &now
+	switch {
+	case confirm:
+		b.BookingStatus = StatusConfirmed
+		p.Status = PaymentPaid
+	case awaiting:
+		b.BookingStatus = StatusExpired
+		p.Status = PaymentRefundRequired
+		log.Printf("Payment %s arrived after booking %d lost its slot; refund required", reference, b.BookingID)
+	default:
+		p.Status = PaymentRefundRequired
+		log.Printf("Payment %s arrived for booking %d, which is %s; refund required", reference, b.BookingID, b.BookingStatus)
+	}
+
+	if awaiting {
+		if _, err := tx.Exec("UPDATE bookings SET BookingStatus = $2, HoldExpiresAt = NULL WHERE BookingID = $1", b.BookingID, b.BookingStatus); err != nil {
+			return nil, nil, fmt.Errorf("database error: %v", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE payments SET Status = $2, PaidAt = $3 WHERE PaymentID = $1", p.PaymentID, p.Status, p.PaidAt); err != nil {
+		return nil, nil, fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Payment %s processed (Booking: %d, Status: %s)", reference, b.BookingID, b.BookingStatus)
+	return p, &b, nil
+}
+
+// GetPaymentsDB lists payments, newest first, optionally only those with status
+func (h *Handler) GetPaymentsDB(status string, limit, offset int) ([]PaymentListing, error) {
+	query := `SELECT p.PaymentID, p.BookingID, p.Provider, p.Reference, p.Amount, p.Status, p.QRPayload, p.ExpiresAt,
+		p.PaidAt, b.UserID, u.UserName, b.BookingStatus, p.RefundedAt, p.RefundedBy
+		FROM payments p JOIN bookings b ON b.BookingID = p.BookingID JOIN users u ON u.UserID = b.UserID`
+	args := []interface{}{limit, offset}
+	if status != "" {
+		query += ` WHERE p.Status = $3`
+		args = append(args, status)
+	}
+	query += ` ORDER BY p.PaymentID DESC LIMIT $1 OFFSET $2`
+
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	payments := []PaymentListing{}
+	for rows.Next() {
+		var p PaymentListing
+		if err := rows.Scan(&p.PaymentID, &p.BookingID, &p.Provider, &p.Reference, &p.Amount, &p.Status, &p.QRPayload, &p.ExpiresAt,
+			&p.PaidAt, &p.UserID, &p.UserName, &p.BookingStatus, &p.RefundedAt, &p.RefundedBy); err != nil {
+			log.Printf("Error scanning payment: %v", err)
+			continue
+		}
+		payments = append(payments, p)
+	}
+	return payments, nil
+}
+
+// MarkPaymentRefundedDB records that an admin paid a RefundRequired payment back
+func (h *Handler) MarkPaymentRefundedDB(paymentID, adminID int) (*Payment, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	p, err := scanPayment(tx.QueryRow("SELECT "+paymentColumns+" FROM payments WHERE PaymentID = $1 FOR UPDATE", paymentID))
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != PaymentRefundRequired {
+		return nil, errRefundNotRequired
+	}
+
+	p.Status = PaymentRefunded
+	if _, err := tx.Exec(
+		"UPDATE payments SET Status = $2, RefundedAt = $3, RefundedBy = $4 WHERE PaymentID = $1",
+		paymentID, p.Status, GetCurrentTime(), adminID,
+	); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Payment %s refunded (Booking: %d, Admin: %d)", p.Reference, p.BookingID, adminID)
+	return p, nil
+}
+
+// ExpirePendingBookingsDB marks unpaid holds past their deadline as Expired
+func (h *Handler) ExpirePendingBookingsDB() (int64, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := GetCurrentTime()
+	result, err := tx.Exec(
+		`UPDATE payments SET Status = 'Expired'
+		 WHERE Status = 'Pending' AND BookingID IN (
+			SELECT BookingID FROM bookings WHERE BookingStatus = 'PendingPayment' AND HoldExpiresAt <= $1
+		 )`,
+		now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec(
+		"UPDATE bookings SET BookingStatus = 'Expired' WHERE BookingStatus = 'PendingPayment' AND HoldExpiresAt <= $1",
+		now,
+	); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	return result.RowsAffected()
+}
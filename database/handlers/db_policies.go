@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Database-backed policy documents and their acceptance by users
+
+// currentPoliciesSQL selects the latest published document of each kind
+const currentPoliciesSQL = `SELECT DocumentID, Kind, Version, Title, Body, PublishedAt, created_at
+	FROM policy_documents d WHERE PublishedAt IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM policy_documents n WHERE n.Kind = d.Kind AND n.PublishedAt IS NOT NULL
+		AND (n.PublishedAt > d.PublishedAt OR (n.PublishedAt = d.PublishedAt AND n.DocumentID > d.DocumentID))
+	)
+	ORDER BY Kind`
+
+func scanPolicies(rows *sql.Rows) []PolicyDocument {
+	docs := []PolicyDocument{}
+	for rows.Next() {
+		var d PolicyDocument
+		if err := rows.Scan(&d.DocumentID, &d.Kind, &d.Version, &d.Title, &d.Body, &d.PublishedAt, &d.CreatedAt); err != nil {
+			log.Printf("Error scanning policy document: %v", err)
+			continue
+		}
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+func (h *Handler) GetCurrentPoliciesDB() ([]PolicyDocument, error) {
+	rows, err := h.DB.Query(currentPoliciesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+	return scanPolicies(rows), nil
+}
+
+// GetPendingPoliciesDB returns the current documents the user has not accepted yet
+func (h *Handler) GetPendingPoliciesDB(userID int) ([]PolicyDocument, error) {
+	rows, err := h.DB.Query(
+		`SELECT * FROM (`+currentPoliciesSQL+`) cur
+		 WHERE NOT EXISTS (SELECT 1 FROM policy_acceptances a WHERE a.UserID = $1 AND a.DocumentID = cur.DocumentID)
+		 ORDER BY Kind`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+	return scanPolicies(rows), nil
+}
+
+func (h *Handler) GetPolicyAcceptancesDB(userID int) ([]PolicyAcceptance, error) {
+	rows, err := h.DB.Query(
+		`SELECT a.DocumentID, d.Kind, d.Version, COALESCE(a.IPAddress, ''), a.AcceptedAt
+		 FROM policy_acceptances a JOIN policy_documents d ON d.DocumentID = a.DocumentID
+		 WHERE a.UserID = $1 ORDER BY a.AcceptedAt DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	acceptances := []PolicyAcceptance{}
+	for rows.Next() {
+		var a PolicyAcceptance
+		if err := rows.Scan(&a.DocumentID, &a.Kind, &a.Version, &a.IPAddress, &a.AcceptedAt); err != nil {
+			log.Printf("Error scanning policy acceptance: %v", err)
+			continue
+		}
+		acceptances = append(acceptances, a)
+	}
+	return acceptances, nil
+}
+
+// AcceptPoliciesDB records acceptance of published documents; accepting twice is a no-op
+func (h *Handler) AcceptPoliciesDB(userID int, ids []int, ip string) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := acceptPoliciesTx(tx, userID, ids, ip); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Policies %v accepted (User: %d)", ids, userID)
+	return nil
+}
+
+// acceptPoliciesTx records the user's acceptance of published documents ids
+func acceptPoliciesTx(tx *sql.Tx, userID int, ids []int, ip string) error {
+	for _, id := range ids {
+		var published bool
+		err := tx.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM policy_documents WHERE DocumentID = $1 AND PublishedAt IS NOT NULL)",
+			id,
+		).Scan(&published)
+		if err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		if !published {
+			return fmt.Errorf("unknown or unpublished policy document")
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO policy_acceptances (UserID, DocumentID, IPAddress, AcceptedAt) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (UserID, DocumentID) DO NOTHING`,
+			userID, id, ip, GetCurrentTime(),
+		)
+		if err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (h *Handler) GetPoliciesDB() ([]PolicyDocument, error) {
+	rows, err := h.DB.Query(
+		`SELECT d.DocumentID, d.Kind, d.Version, d.Title, d.Body, d.PublishedAt, d.created_at,
+		        (SELECT COUNT(*) FROM policy_acceptances a WHERE a.DocumentID = d.DocumentID)
+		 FROM policy_documents d ORDER BY d.Kind, d.DocumentID DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	docs := []PolicyDocument{}
+	for rows.Next() {
+		var d PolicyDocument
+		if err := rows.Scan(&d.DocumentID, &d.Kind, &d.Version, &d.Title, &d.Body, &d.PublishedAt, &d.CreatedAt, &d.Acceptances); err != nil {
+			log.Printf("Error scanning policy document: %v", err)
+			continue
+		}
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+func (h *Handler) CreatePolicyDB(doc PolicyDocument, adminID int) (PolicyDocument, error) {
+	err := h.DB.QueryRow(
+		`INSERT INTO policy_documents (Kind, Version, Title, Body, CreatedBy) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING DocumentID, created_at`,
+		doc.Kind, strings.TrimSpace(doc.Version), doc.Title, doc.Body, adminID,
+	).Scan(&doc.DocumentID, &doc.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return doc, fmt.Errorf("%s version %s already exists", doc.Kind, doc.Version)
+		}
+		return doc, fmt.Errorf("database error: %v", err)
+	}
+	doc.PublishedAt = nil
+	return doc, nil
+}
+
+func (h *Handler) UpdatePolicyDB(doc PolicyDocument) error {
+	result, err := h.DB.Exec(
+		`UPDATE policy_documents SET Kind = $2, Version = $3, Title = $4, Body = $5
+		 WHERE DocumentID = $1 AND PublishedAt IS NULL`,
+		doc.DocumentID, doc.Kind, strings.TrimSpace(doc.Version), doc.Title, doc.Body,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s version %s already exists", doc.Kind, doc.Version)
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("draft not found (published documents cannot be changed)")
+	}
+	return nil
+}
+
+func (h *Handler) PublishPolicyDB(documentID, adminID int) error {
+	result, err := h.DB.Exec(
+		"UPDATE policy_documents SET PublishedAt = $3, PublishedBy = $2 WHERE DocumentID = $1 AND PublishedAt IS NULL",
+		documentID, adminID, GetCurrentTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("draft not found or already published")
+	}
+
+	log.Printf("✅ Policy document %d published by admin %d", documentID, adminID)
+	return nil
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Database-backed pricing operations
+
+func (h *Handler) GetPricingConfigDB() (PricingConfig, error) {
+	cfg := PricingConfig{Rates: []PriceRate{}, Bands: []PriceBand{}, Tiers: map[string]int{}}
+
+	rows, err := h.DB.Query("SELECT RateID, SportType, CourtID, HourlyRate FROM price_rates ORDER BY SportType, CourtID NULLS FIRST")
+	if err != nil {
+		return cfg, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r PriceRate
+		var courtID sql.NullInt64
+		if err := rows.Scan(&r.RateID, &r.SportType, &courtID, &r.HourlyRate); err != nil {
+			log.Printf("Error scanning price rate: %v", err)
+			continue
+		}
+		if courtID.Valid {
+			id := int(courtID.Int64)
+			r.CourtID = &id
+		}
+		cfg.Rates = append(cfg.Rates, r)
+	}
+
+	bandRows, err := h.DB.Query("SELECT BandID, Name, DayType, StartHour, EndHour, RatePercent FROM price_bands ORDER BY BandID")
+	if err != nil {
+		return cfg, fmt.Errorf("database error: %v", err)
+	}
+	defer bandRows.Close()
+
+	for bandRows.Next() {
+		var b PriceBand
+		if err := bandRows.Scan(&b.BandID, &b.Name, &b.DayType, &b.StartHour, &b.EndHour, &b.RatePercent); err != nil {
+			log.Printf("Error scanning price band: %v", err)
+			continue
+		}
+		cfg.Bands = append(cfg.Bands, b)
+	}
+
+	tierRows, err := h.DB.Query("SELECT Tier, RatePercent FROM price_tiers")
+	if err != nil {
+		return cfg, fmt.Errorf("database error: %v", err)
+	}
+	defer tierRows.Close()
+
+	for tierRows.Next() {
+		var tier string
+		var pct int
+		if err := tierRows.Scan(&tier, &pct); err != nil {
+			log.Printf("Error scanning price tier: %v", err)
+			continue
+		}
+		cfg.Tiers[tier] = pct
+	}
+
+	return cfg, nil
+}
+
+func (h *Handler) UpsertPriceRateDB(r PriceRate) (PriceRate, error) {
+	if r.CourtID != nil {
+		court, err := h.Courts.GetCourt(*r.CourtID)
+		if err != nil {
+			return PriceRate{}, err
+		}
+		if court.SportType != r.SportType {
+			return PriceRate{}, fmt.Errorf("court %d is not a %s court", *r.CourtID, r.SportType)
+		}
+	}
+
+	// COALESCE lets the unique index treat the sport-wide rate (NULL court) as one row
+	err := h.DB.QueryRow(
+		`INSERT INTO price_rates (SportType, CourtID, HourlyRate) VALUES ($1, $2, $3)
+		 ON CONFLICT (SportType, COALESCE(CourtID, 0)) DO UPDATE SET HourlyRate = EXCLUDED.HourlyRate
+		 RETURNING RateID`,
+		r.SportType, r.CourtID, r.HourlyRate,
+	).Scan(&r.RateID)
+	if err != nil {
+		log.Printf("Error saving price rate: %v", err)
+		return PriceRate{}, fmt.Errorf("failed to save rate")
+	}
+
+	log.Printf("✅ Price rate saved (ID: %d, %s, %s/h)", r.RateID, r.SportType, r.HourlyRate)
+	return r, nil
+}
+
+func (h *Handler) DeletePriceRateDB(rateID int) error {
+	return h.deleteByID("price_rates", "RateID", rateID, "rate")
+}
+
+func (h *Handler) CreatePriceBandDB(b PriceBand) (PriceBand, error) {
+	err := h.DB.QueryRow(
+		`INSERT INTO price_bands (Name, DayType, StartHour, EndHour, RatePercent)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING BandID`,
+		b.Name, b.DayType, b.StartHour, b.EndHour, b.RatePercent,
+	).Scan(&b.BandID)
+	if err != nil {
+		log.Printf("Error creating price band: %v", err)
+		return PriceBand{}, fmt.Errorf("failed to create band")
+	}
+	return b, nil
+}
+
+func (h *Handler) DeletePriceBandDB(bandID int) error {
+	return h.deleteByID("price_bands", "BandID", bandID, "band")
+}
+
+func (h *Handler) SetPricingTiersDB(tiers map[string]int) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	for tier, pct := range tiers {
+		_, err := tx.Exec(
+			`INSERT INTO price_tiers (Tier, RatePercent) VALUES ($1, $2)
+			 ON CONFLICT (Tier) DO UPDATE SET RatePercent = EXCLUDED.RatePercent`,
+			tier, pct,
+		)
+		if err != nil {
+			log.Printf("Error saving price tier: %v", err)
+			return fmt.Errorf("failed to save pricing tiers")
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (h *Handler) GetUserPricingTierDB(userID int) (string, error) {
+	var tier string
+	err := h.DB.QueryRow("SELECT PricingTier FROM users WHERE UserID = $1", userID).Scan(&tier)
+	if err == sql.ErrNoRows {
+		return "", errUserNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	return tier, nil
+}
+
+func (h *Handler) SetUserPricingTierDB(userID int, tier string) error {
+	result, err := h.DB.Exec("UPDATE users SET PricingTier = $2 WHERE UserID = $1", userID, tier)
+	if err != nil {
+		log.Printf("Error updating pricing tier: %v", err)
+		return fmt.Errorf("failed to update pricing tier")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// deleteByID deletes one row from a small admin-managed table
+func (h *Handler) deleteByID(table, idColumn string, id int, what string) error {
+	result, err := h.DB.Exec("DELETE FROM "+table+" WHERE "+idColumn+" = $1", id)
+	if err != nil {
+		log.Printf("Error deleting %s: %v", what, err)
+		return fmt.Errorf("failed to delete %s", what)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s not found", what)
+	}
+	return nil
+}
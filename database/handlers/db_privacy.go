@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Database-backed personal data export and account deletion
+
+// userExportSections lists what goes into a data export. Every query takes the user id
+// as $1 and aliases its columns to the keys used in the export.
+var userExportSections = []struct {
+	Name  string
+	Query string
+}{
+	{"profile", `SELECT UserID AS user_id, FirstName AS first_name, LastName AS last_name, UserName AS username,
+		Email AS email, EmailVerifiedAt AS email_verified_at, PhoneNumber AS phone_number, StudentID AS student_id,
+		Role AS role, PricingTier AS pricing_tier, LineUserID AS line_user_id, ProfilePicture AS profile_picture,
+		created_at, updated_at
+		FROM users WHERE UserID = $1`},
+	{"bookings", `SELECT b.BookingID AS booking_id, c.CourtName AS court, c.SportType AS sport_type,
+		b.StartTime AS start_time, b.EndTime AS end_time, b.BookingStatus AS status, b.Price AS price_satang,
+		b.PriceBreakdown AS price_breakdown, b.created_at
+		FROM bookings b LEFT JOIN courts c ON c.CourtID = b.CourtID WHERE b.UserID = $1 ORDER BY b.BookingID`},
+	{"payments", `SELECT p.PaymentID AS payment_id, p.BookingID AS booking_id, p.Provider AS provider,
+		p.Reference AS reference, p.Amount AS amount_satang, p.Status AS status, p.PaidAt AS paid_at, p.created_at
+		FROM payments p JOIN bookings b ON b.BookingID = p.BookingID WHERE b.UserID = $1 ORDER BY p.PaymentID`},
+	{"voucher_redemptions", `SELECT v.Code AS code, r.BookingID AS booking_id, r.Discount AS discount_satang, r.created_at
+		FROM voucher_redemptions r JOIN vouchers v ON v.VoucherID = r.VoucherID WHERE r.UserID = $1 ORDER BY r.RedemptionID`},
+	{"wallet", `SELECT t.TxID AS transaction_id, t.Kind AS kind, t.BookingID AS booking_id, t.Description AS description,
+		e.Amount AS amount_satang, t.created_at
+		FROM ledger_entries e JOIN ledger_transactions t ON t.TxID = e.TxID
+		JOIN ledger_accounts a ON a.AccountID = e.AccountID WHERE a.UserID = $1 ORDER BY t.TxID`},
+	{"notifications", `SELECT NotificationID AS notification_id, EventType AS event_type, Title AS title,
+		Message AS message, ReadAt AS read_at, created_at
+		FROM notifications WHERE UserID = $1 ORDER BY NotificationID`},
+	{"notification_preferences", `SELECT EventType AS event_type, Channel AS channel, Enabled AS enabled
+		FROM notification_preferences WHERE UserID = $1 ORDER BY EventType, Channel`},
+	{"push_subscriptions", `SELECT Endpoint AS endpoint, created_at FROM push_subscriptions WHERE UserID = $1`},
+	{"sso_identities", `SELECT Issuer AS issuer, Subject AS subject, Email AS email, LastLoginAt AS last_login_at, created_at
+		FROM user_identities WHERE UserID = $1`},
+	{"two_factor", `SELECT Enabled AS enabled, EnabledAt AS enabled_at FROM user_mfa WHERE UserID = $1`},
+	{"sessions", `SELECT IPAddress AS ip_address, UserAgent AS user_agent, MFA AS mfa, LastUsedAt AS last_used_at,
+		RevokedAt AS revoked_at, RevokeReason AS revoke_reason, created_at
+		FROM auth_sessions WHERE UserID = $1 ORDER BY created_at`},
+	{"login_attempts", `SELECT IPAddress AS ip_address, Success AS success, created_at
+		FROM login_attempts WHERE UserName = (SELECT LOWER(UserName) FROM users WHERE UserID = $1) ORDER BY AttemptID`},
+	{"password_resets", `SELECT RequestIP AS request_ip, UsedAt AS used_at, created_at
+		FROM password_resets WHERE UserID = $1 ORDER BY ResetID`},
+	{"email_verification_sends", `SELECT created_at FROM email_verification_sends WHERE UserID = $1 ORDER BY created_at`},
+	{"account_deletion_requests", `SELECT RequestedAt AS requested_at, ScheduledFor AS scheduled_for,
+		CancelledAt AS cancelled_at, Reason AS reason
+		FROM account_deletions WHERE UserID = $1 ORDER BY RequestID`},
+	{"policy_acceptances", `SELECT d.Kind AS kind, d.Version AS version, a.IPAddress AS ip_address, a.AcceptedAt AS accepted_at
+		FROM policy_acceptances a JOIN policy_documents d ON d.DocumentID = a.DocumentID
+		WHERE a.UserID = $1 ORDER BY a.AcceptedAt`},
+}
+
+// ExportUserDataDB collects every export section of the user
+func (h *Handler) ExportUserDataDB(userID int) (map[string]interface{}, error) {
+	export := map[string]interface{}{
+		"exported_at": GetCurrentTime(),
+		"user_id":     userID,
+	}
+
+	for _, section := range userExportSections {
+		rows, err := h.exportRows(section.Query, userID)
+		if err != nil {
+			return nil, fmt.Errorf("database error (%s): %v", section.Name, err)
+		}
+		if section.Name == "profile" || section.Name == "two_factor" {
+			if len(rows) > 0 {
+				export[section.Name] = rows[0]
+			} else {
+				export[section.Name] = nil
+			}
+			continue
+		}
+		export[section.Name] = rows
+	}
+
+	return export, nil
+}
+
+// exportRows returns the result as one map per row, keyed by column name
+func (h *Handler) exportRows(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := map[string]interface{}{}
+		for i, col := range columns {
+			// JSONB and text come back as bytes
+			if b, ok := values[i].([]byte); ok {
+				if json.Valid(b) && (len(b) > 0 && (b[0] == '[' || b[0] == '{')) {
+					values[i] = json.RawMessage(b)
+				} else {
+					values[i] = string(b)
+				}
+			}
+			row[col] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// RequestAccountDeletionDB schedules the user's account for anonymisation after the grace period
+func (h *Handler) RequestAccountDeletionDB(userID int, password, reason string) (*AccountDeletion, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var passwordHash string
+	err = tx.QueryRow("SELECT PasswordHash FROM users WHERE UserID = $1 AND DeletedAt IS NULL FOR UPDATE", userID).Scan(&passwordHash)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	// SSO-only accounts have no usable password ("!")
+	if passwordHash != "!" {
+		if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)); err != nil {
+			return nil, errInvalidCredentials
+		}
+	}
+
+	if blocked, err := deletionBlocked(tx, userID); err != nil {
+		return nil, err
+	} else if blocked {
+		return nil, errDeletionBlocked
+	}
+
+	var pending bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM account_deletions WHERE UserID = $1 AND CancelledAt IS NULL AND CompletedAt IS NULL)",
+		userID,
+	).Scan(&pending); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if pending {
+		return nil, fmt.Errorf("account deletion already requested")
+	}
+
+	now := GetCurrentTime()
+	d := AccountDeletion{UserID: userID, Reason: reason, Status: "pending"}
+	err = tx.QueryRow(
+		`INSERT INTO account_deletions (UserID, Reason, RequestedAt, ScheduledFor) VALUES ($1, $2, $3, $4)
+		 RETURNING RequestID, RequestedAt, ScheduledFor`,
+		userID, reason, now, now.Add(accountDeletionGrace),
+	).Scan(&d.RequestID, &d.RequestedAt, &d.ScheduledFor)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Account deletion requested (User: %d, Scheduled: %s)", userID, d.ScheduledFor.Format(time.RFC3339))
+	if user, err := h.Users.GetUser(userID); err == nil {
+		d.UserName = user.UserName
+		go SendAccountDeletionNotice(*user, d.ScheduledFor)
+	}
+	return &d, nil
+}
+
+// deletionBlocked reports upcoming bookings or money left in the wallet
+func deletionBlocked(q queryer, userID int) (bool, error) {
+	var blocked bool
+	err := q.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM bookings WHERE UserID = $1 AND EndTime > $2 AND `+activeBookingSQL(2)+`)
+		     OR EXISTS (SELECT 1 FROM ledger_accounts WHERE UserID = $1 AND Balance <> 0)`,
+		userID, GetCurrentTime(),
+	).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("database error: %v", err)
+	}
+	return blocked, nil
+}
+
+const accountDeletionColumns = `d.RequestID, d.UserID, u.UserName, COALESCE(d.Reason, ''), d.RequestedAt, d.ScheduledFor,
+	d.CancelledAt, d.CompletedAt`
+
+func scanAccountDeletion(row rowScanner) (AccountDeletion, error) {
+	var d AccountDeletion
+	err := row.Scan(&d.RequestID, &d.UserID, &d.UserName, &d.Reason, &d.RequestedAt, &d.ScheduledFor, &d.CancelledAt, &d.CompletedAt)
+	switch {
+	case d.CompletedAt != nil:
+		d.Status = "completed"
+	case d.CancelledAt != nil:
+		d.Status = "cancelled"
+	default:
+		d.Status = "pending"
+	}
+	return d, err
+}
+
+// GetPendingDeletionDB returns the user's pending request, or nil
+func (h *Handler) GetPendingDeletionDB(userID int) (*AccountDeletion, error) {
+	d, err := scanAccountDeletion(h.DB.QueryRow(
+		`SELECT `+accountDeletionColumns+` FROM account_deletions d JOIN users u ON u.UserID = d.UserID
+		 WHERE d.UserID = $1 AND d.CancelledAt IS NULL AND d.CompletedAt IS NULL`,
+		userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return &d, nil
+}
+
+func (h *Handler) CancelAccountDeletionDB(userID int) error {
+	result, err := h.DB.Exec(
+		"UPDATE account_deletions SET CancelledAt = $2 WHERE UserID = $1 AND CancelledAt IS NULL AND CompletedAt IS NULL",
+		userID, GetCurrentTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("no pending deletion request")
+	}
+
+	log.Printf("✅ Account deletion cancelled (User: %d)", userID)
+	return nil
+}
+
+func (h *Handler) GetAccountDeletionsDB(status string, limit, offset int) ([]AccountDeletion, error) {
+	query := `SELECT ` + accountDeletionColumns + ` FROM account_deletions d JOIN users u ON u.UserID = d.UserID`
+	switch status {
+	case "pending":
+		query += ` WHERE d.CancelledAt IS NULL AND d.CompletedAt IS NULL`
+	case "completed":
+		query += ` WHERE d.CompletedAt IS NOT NULL`
+	case "cancelled":
+		query += ` WHERE d.CancelledAt IS NOT NULL`
+	}
+	query += ` ORDER BY d.RequestID DESC LIMIT $1 OFFSET $2`
+
+	rows, err := h.DB.Query(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	deletions := []AccountDeletion{}
+	for rows.Next() {
+		d, err := scanAccountDeletion(rows)
+		if err != nil {
+			log.Printf("Error scanning account deletion: %v", err)
+			continue
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, nil
+}
+
+// ProcessDueDeletionsDB anonymises every account whose grace period is over. Accounts that
+// picked up a booking or wallet balance in the meantime are retried on the next run.
+func (h *Handler) ProcessDueDeletionsDB() (int, error) {
+	rows, err := h.DB.Query(
+		"SELECT RequestID, UserID FROM account_deletions WHERE CancelledAt IS NULL AND CompletedAt IS NULL AND ScheduledFor <= $1",
+		GetCurrentTime(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	type due struct{ requestID, userID int }
+	var pending []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.requestID, &d.userID); err == nil {
+			pending = append(pending, d)
+		}
+	}
+	rows.Close()
+
+	deleted := 0
+	for _, d := range pending {
+		picture, err := h.anonymizeUserDB(d.requestID, d.userID)
+		if err == errDeletionBlocked {
+			log.Printf("Warning: deletion of user %d postponed: upcoming bookings or wallet balance", d.userID)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error anonymising user %d: %v", d.userID, err)
+			continue
+		}
+		removeProfilePicture(picture)
+		deleted++
+	}
+	return deleted, nil
+}
+
+// anonymizeUserDB wipes the personal data of the user and returns the old picture URL
+func (h *Handler) anonymizeUserDB(requestID, userID int) (string, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var username, picture string
+	err = tx.QueryRow(
+		"SELECT UserName, COALESCE(ProfilePicture, '') FROM users WHERE UserID = $1 FOR UPDATE",
+		userID,
+	).Scan(&username, &picture)
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	if blocked, err := deletionBlocked(tx, userID); err != nil {
+		return "", err
+	} else if blocked {
+		return "", errDeletionBlocked
+	}
+
+	// Keep the row (bookings and the ledger reference it) but nothing that identifies the person
+	_, err = tx.Exec(
+		`UPDATE users SET FirstName = 'Deleted', LastName = 'User', UserName = '`+deletedUserNamePrefix+`' || UserID,
+		        Email = NULL, EmailVerifiedAt = NULL, PhoneNumber = NULL, StudentID = NULL, LineUserID = NULL,
+		        ProfilePicture = NULL, PasswordHash = '!', DeletedAt = $2
+		 WHERE UserID = $1`,
+		userID, GetCurrentTime(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	if err := revokeAllSessionsTx(tx, userID, "account deleted"); err != nil {
+		return "", err
+	}
+
+	for _, stmt := range []string{
+		"DELETE FROM auth_sessions WHERE UserID = $1",
+		"DELETE FROM notifications WHERE UserID = $1",
+		"DELETE FROM notification_preferences WHERE UserID = $1",
+		"DELETE FROM push_subscriptions WHERE UserID = $1",
+		"DELETE FROM password_resets WHERE UserID = $1",
+		"DELETE FROM email_verification_sends WHERE UserID = $1",
+		"DELETE FROM user_identities WHERE UserID = $1",
+		"DELETE FROM mfa_recovery_codes WHERE UserID = $1",
+		"DELETE FROM mfa_challenges WHERE UserID = $1",
+		"DELETE FROM user_mfa WHERE UserID = $1",
+	} {
+		if _, err := tx.Exec(stmt, userID); err != nil {
+			return "", fmt.Errorf("database error: %v", err)
+		}
+	}
+	// Which versions were accepted is kept as a legal record, without the IP address
+	if _, err := tx.Exec("UPDATE policy_acceptances SET IPAddress = NULL WHERE UserID = $1", userID); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE account_deletions SET Reason = NULL, CompletedAt = $2 WHERE RequestID = $1", requestID, GetCurrentTime()); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	// Login history is keyed by username
+	subject := loginSubject(username)
+	if _, err := tx.Exec("DELETE FROM login_attempts WHERE UserName = $1", subject); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM login_lockouts WHERE Scope = 'user' AND Subject = $1", subject); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ User %d anonymised (deletion request %d)", userID, requestID)
+	return picture, nil
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Database-backed self-service profile updates. updated_at is set by the users trigger.
+
+// UpdateProfileDB applies the present fields and reports whether the email changed, in
+// which case the address is marked unverified
+func (h *Handler) UpdateProfileDB(userID int, req UpdateProfileRequest) (*User, bool, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var currentEmail string
+	err = tx.QueryRow("SELECT COALESCE(Email, '') FROM users WHERE UserID = $1 FOR UPDATE", userID).Scan(&currentEmail)
+	if err == sql.ErrNoRows {
+		return nil, false, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("database error: %v", err)
+	}
+
+	sets := []string{}
+	args := []interface{}{userID}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.FirstName != nil {
+		set("FirstName", strings.TrimSpace(*req.FirstName))
+	}
+	if req.LastName != nil {
+		set("LastName", strings.TrimSpace(*req.LastName))
+	}
+	if req.PhoneNumber != nil {
+		set("PhoneNumber", strings.TrimSpace(*req.PhoneNumber))
+	}
+
+	emailChanged := req.Email != nil && !strings.EqualFold(*req.Email, currentEmail)
+	if emailChanged {
+		var taken bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(Email) = $1 AND UserID <> $2)", *req.Email, userID).Scan(&taken)
+		if err != nil {
+			return nil, false, fmt.Errorf("database error: %v", err)
+		}
+		if taken {
+			return nil, false, fmt.Errorf("email already registered")
+		}
+		set("Email", *req.Email)
+		sets = append(sets, "EmailVerifiedAt = NULL")
+	}
+
+	if len(sets) > 0 {
+		if _, err := tx.Exec("UPDATE users SET "+strings.Join(sets, ", ")+" WHERE UserID = $1", args...); err != nil {
+			log.Printf("Error updating profile: %v", err)
+			return nil, false, fmt.Errorf("failed to update profile")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("database error: %v", err)
+	}
+
+	if emailChanged {
+		log.Printf("✅ Email changed, verification required (User: %d)", userID)
+	}
+
+	user, err := h.Users.GetUser(userID)
+	return user, emailChanged, err
+}
+
+// ChangePasswordDB sets a new password after checking the current one and revokes every session
+func (h *Handler) ChangePasswordDB(userID int, currentPassword, newPassword string) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var passwordHash string
+	err = tx.QueryRow("SELECT PasswordHash FROM users WHERE UserID = $1 FOR UPDATE", userID).Scan(&passwordHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(currentPassword)); err != nil {
+		return errInvalidCredentials
+	}
+	if currentPassword == newPassword {
+		return fmt.Errorf("new password must be different from the current one")
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("cannot hash password")
+	}
+	if _, err := tx.Exec("UPDATE users SET PasswordHash = $2 WHERE UserID = $1", userID, string(hashed)); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if err := revokeAllSessionsTx(tx, userID, "password changed"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Password changed (User: %d)", userID)
+	return nil
+}
+
+// SetProfilePictureDB stores the picture URL ("" removes it) and returns the previous one
+func (h *Handler) SetProfilePictureDB(userID int, url string) (string, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRow("SELECT COALESCE(ProfilePicture, '') FROM users WHERE UserID = $1 FOR UPDATE", userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	if _, err := tx.Exec("UPDATE users SET ProfilePicture = NULLIF($2, '') WHERE UserID = $1", userID, url); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	return previous, nil
+}
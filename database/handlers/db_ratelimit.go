@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PostgresRateLimitStore shares buckets between instances. The refill and take happen in a
+// single upsert, so concurrent requests for the same key are serialised by the row lock.
+type PostgresRateLimitStore struct {
+	DB *sql.DB
+}
+
+const takeTokenSQL = `INSERT INTO rate_limit_buckets AS b (BucketKey, Tokens, Allowed, UpdatedAt)
+	VALUES ($1, $2::float8 - 1, TRUE, NOW())
+	ON CONFLICT (BucketKey) DO UPDATE SET
+		Tokens = CASE
+			WHEN LEAST($2::float8, b.Tokens + EXTRACT(EPOCH FROM NOW() - b.UpdatedAt) * $3::float8) >= 1
+			THEN LEAST($2::float8, b.Tokens + EXTRACT(EPOCH FROM NOW() - b.UpdatedAt) * $3::float8) - 1
+			ELSE LEAST($2::float8, b.Tokens + EXTRACT(EPOCH FROM NOW() - b.UpdatedAt) * $3::float8)
+		END,
+		Allowed = LEAST($2::float8, b.Tokens + EXTRACT(EPOCH FROM NOW() - b.UpdatedAt) * $3::float8) >= 1,
+		UpdatedAt = NOW()
+	RETURNING Tokens, Allowed`
+
+// NewPostgresRateLimitStore returns a store on db and starts pruning idle buckets
+func NewPostgresRateLimitStore(db *sql.DB) *PostgresRateLimitStore {
+	s := &PostgresRateLimitStore{DB: db}
+	go func() {
+		for {
+			time.Sleep(10 * time.Minute)
+			if _, err := db.Exec("DELETE FROM rate_limit_buckets WHERE UpdatedAt < NOW() - INTERVAL '1 hour'"); err != nil {
+				log.Printf("Error pruning rate limit buckets: %v", err)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *PostgresRateLimitStore) Take(key string, limit RateLimit) (RateLimitResult, error) {
+	rate := float64(limit.PerMinute) / 60
+
+	var tokens float64
+	var result RateLimitResult
+	err := s.DB.QueryRow(takeTokenSQL, key, float64(limit.Burst), rate).Scan(&tokens, &result.Allowed)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("database error: %v", err)
+	}
+
+	result.Remaining = int(tokens)
+	if tokens < 1 {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Database-backed role to permission mapping
+
+func (h *Handler) GetRolePermissionsDB() (map[string][]string, error) {
+	rows, err := h.DB.Query("SELECT Role, Permission FROM role_permissions ORDER BY Role, Permission")
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	mapping := map[string][]string{}
+	for rows.Next() {
+		var role, perm string
+		if err := rows.Scan(&role, &perm); err != nil {
+			log.Printf("Error scanning role permission: %v", err)
+			continue
+		}
+		mapping[role] = append(mapping[role], perm)
+	}
+	return mapping, nil
+}
+
+// SetRolePermissionsDB replaces the permissions of role
+func (h *Handler) SetRolePermissionsDB(role string, perms []string, adminID int) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE Role = $1", role); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	for _, perm := range perms {
+		_, err = tx.Exec(
+			`INSERT INTO role_permissions (Role, Permission, GrantedBy) VALUES ($1, $2, $3)
+			 ON CONFLICT (Role, Permission) DO NOTHING`,
+			role, perm, adminID,
+		)
+		if err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Permissions of %s set to %v by admin %d", role, perms, adminID)
+	return nil
+}
+
+// SetUserRoleDB changes the role of a user and logs them out everywhere
+func (h *Handler) SetUserRoleDB(userID int, role string) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow("SELECT Role FROM users WHERE UserID = $1 AND DeletedAt IS NULL FOR UPDATE", userID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if current == role {
+		return nil
+	}
+
+	if _, err := tx.Exec("UPDATE users SET Role = $2 WHERE UserID = $1", userID, role); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if err := revokeAllSessionsTx(tx, userID, "role changed"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ User %d role changed from %s to %s", userID, current, role)
+	return nil
+}
+
+// SeedRolePermissionsDB gives the staff roles their default permissions on a fresh database
+func (h *Handler) SeedRolePermissionsDB() error {
+	var count int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM role_permissions").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for role, perms := range defaultRolePermissions {
+		for _, perm := range perms {
+			if _, err := h.DB.Exec("INSERT INTO role_permissions (Role, Permission) VALUES ($1, $2) ON CONFLICT DO NOTHING", role, perm); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Println("✅ Role permissions seeded successfully")
+	return nil
+}
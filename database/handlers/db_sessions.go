@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Database-backed login sessions. Each login starts a session; its refresh tokens rotate on
+// every use and presenting an already-used one revokes the whole session (token theft).
+
+var (
+	errInvalidRefreshToken = fmt.Errorf("invalid or expired refresh token")
+	errRefreshTokenReused  = fmt.Errorf("refresh token reuse detected, session revoked")
+	errTokenRevoked        = fmt.Errorf("token has been revoked")
+)
+
+// newOpaqueToken returns a random token and the SHA-256 hex digest that is stored instead of it
+func newOpaqueToken() (string, string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	return token, hashOpaqueToken(token)
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueRefreshTokenTx stores a new refresh token for the session and returns the raw value
+func issueRefreshTokenTx(tx *sql.Tx, sessionID string) (string, error) {
+	token, hash := newOpaqueToken()
+	_, err := tx.Exec(
+		"INSERT INTO refresh_tokens (SessionID, TokenHash, ExpiresAt) VALUES ($1, $2, $3)",
+		sessionID, hash, GetCurrentTime().Add(refreshTokenTTL),
+	)
+	if err != nil {
+		log.Printf("Error issuing refresh token: %v", err)
+		return "", fmt.Errorf("failed to issue refresh token")
+	}
+	return token, nil
+}
+
+func (h *Handler) newTokenPair(userID int, role, sessionID string, version int, mfa bool, refreshToken string) (TokenPair, error) {
+	perms, err := h.PermissionsForRole(role)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	access, err := GenerateToken(userID, role, sessionID, version, mfa, perms)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("failed to sign token")
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(accessTokenTTL / time.Second),
+	}, nil
+}
+
+// CreateSessionDB starts a login session for user and returns its first token pair.
+// mfa records that the user passed a second factor.
+func (h *Handler) CreateSessionDB(user *User, mfa bool, ip, userAgent string) (TokenPair, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow("SELECT TokenVersion FROM users WHERE UserID = $1", user.UserID).Scan(&version); err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+
+	sid := make([]byte, 16)
+	rand.Read(sid)
+	sessionID := hex.EncodeToString(sid)
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	_, err = tx.Exec(
+		"INSERT INTO auth_sessions (SessionID, UserID, TokenVersion, IPAddress, UserAgent, MFA) VALUES ($1, $2, $3, $4, $5, $6)",
+		sessionID, user.UserID, version, ip, userAgent, mfa,
+	)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		return TokenPair{}, fmt.Errorf("failed to create session")
+	}
+
+	refresh, err := issueRefreshTokenTx(tx, sessionID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+
+	return h.newTokenPair(user.UserID, user.Role, sessionID, version, mfa, refresh)
+}
+
+// RefreshSessionDB exchanges a refresh token for a new pair; the old refresh token is spent
+func (h *Handler) RefreshSessionDB(refreshToken string) (TokenPair, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenID, userID     int
+		sessionID, role     string
+		sessionVer, userVer int
+		expiresAt           time.Time
+		usedAt, revokedAt   *time.Time
+		mfa                 bool
+	)
+	err = tx.QueryRow(
+		`SELECT r.TokenID, r.ExpiresAt, r.UsedAt, s.SessionID, s.TokenVersion, s.RevokedAt, s.MFA,
+		        u.UserID, u.Role, u.TokenVersion
+		 FROM refresh_tokens r
+		 JOIN auth_sessions s ON s.SessionID = r.SessionID
+		 JOIN users u ON u.UserID = s.UserID
+		 WHERE r.TokenHash = $1
+		 FOR UPDATE OF r, s`,
+		hashOpaqueToken(refreshToken),
+	).Scan(&tokenID, &expiresAt, &usedAt, &sessionID, &sessionVer, &revokedAt, &mfa, &userID, &role, &userVer)
+	if err == sql.ErrNoRows {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+
+	if revokedAt != nil || sessionVer != userVer {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	if usedAt != nil {
+		// Someone is replaying a rotated token: kill the session for both parties
+		if err := revokeSessionTx(tx, sessionID, "refresh token reuse"); err != nil {
+			return TokenPair{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return TokenPair{}, fmt.Errorf("database error: %v", err)
+		}
+		log.Printf("Warning: refresh token reuse detected (User: %d, Session: %s); session revoked", userID, sessionID)
+		return TokenPair{}, errRefreshTokenReused
+	}
+
+	now := GetCurrentTime()
+	if now.After(expiresAt) {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET UsedAt = $2 WHERE TokenID = $1", tokenID, now); err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE auth_sessions SET LastUsedAt = $2 WHERE SessionID = $1", sessionID, now); err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+
+	refresh, err := issueRefreshTokenTx(tx, sessionID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TokenPair{}, fmt.Errorf("database error: %v", err)
+	}
+
+	return h.newTokenPair(userID, role, sessionID, userVer, mfa, refresh)
+}
+
+func revokeSessionTx(tx *sql.Tx, sessionID, reason string) error {
+	_, err := tx.Exec(
+		"UPDATE auth_sessions SET RevokedAt = $3, RevokeReason = $2 WHERE SessionID = $1 AND RevokedAt IS NULL",
+		sessionID, reason, GetCurrentTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
+
+// RevokeSessionDB ends one session of the user (logout)
+func (h *Handler) RevokeSessionDB(userID int, sessionID string) error {
+	_, err := h.DB.Exec(
+		"UPDATE auth_sessions SET RevokedAt = $3, RevokeReason = 'logout' WHERE SessionID = $1 AND UserID = $2 AND RevokedAt IS NULL",
+		sessionID, userID, GetCurrentTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Session revoked (User: %d)", userID)
+	return nil
+}
+
+// RevokeAllSessionsDB bumps the user's token version, which invalidates every access and
+// refresh token issued so far. Used for "log out everywhere" and after password changes.
+func (h *Handler) RevokeAllSessionsDB(userID int, reason string) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := revokeAllSessionsTx(tx, userID, reason); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ All sessions revoked (User: %d, Reason: %s)", userID, reason)
+	return nil
+}
+
+func revokeAllSessionsTx(tx *sql.Tx, userID int, reason string) error {
+	if _, err := tx.Exec("UPDATE users SET TokenVersion = TokenVersion + 1 WHERE UserID = $1", userID); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	_, err := tx.Exec(
+		"UPDATE auth_sessions SET RevokedAt = $3, RevokeReason = $2 WHERE UserID = $1 AND RevokedAt IS NULL",
+		userID, reason, GetCurrentTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	return nil
+}
+
+// CheckSessionDB rejects access tokens whose session was revoked or whose version is stale
+func (h *Handler) CheckSessionDB(claims *Claims) error {
+	var version int
+	var active bool
+	err := h.DB.QueryRow(
+		`SELECT u.TokenVersion, s.SessionID IS NOT NULL AND s.RevokedAt IS NULL
+		 FROM users u LEFT JOIN auth_sessions s ON s.SessionID = $2 AND s.UserID = u.UserID
+		 WHERE u.UserID = $1`,
+		claims.UserID, claims.SessionID,
+	).Scan(&version, &active)
+	if err == sql.ErrNoRows {
+		return errTokenRevoked
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if version != claims.TokenVersion || !active {
+		return errTokenRevoked
+	}
+	return nil
+}
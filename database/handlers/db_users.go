@@ -4,87 +4,107 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-var DB *sql.DB
+// PostgresStore keeps users, courts and bookings in Postgres, or in SQLite through the
+// sqlitedb driver, which accepts the same queries
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStores returns stores backed by db
+func NewPostgresStores(db *sql.DB) Stores {
+	s := &PostgresStore{db: db}
+	return Stores{Users: s, Courts: s, Bookings: s, DB: db}
+}
 
-// SetDB sets the database connection for handlers
-func SetDB(database *sql.DB) {
-	DB = database
+// NewSQLiteStores returns stores backed by a database opened with sqlitedb.Open
+func NewSQLiteStores(db *sql.DB) Stores {
+	return NewPostgresStores(db)
 }
 
 // Database-backed user operations
 
-func RegisterUserDB(req RegisterRequest) (User, error) {
+func (s *PostgresStore) CreateUser(u *User, consent *PolicyConsent) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
 	// Check if username exists
 	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE UserName = $1", req.UserName).Scan(&count)
+	err = tx.QueryRow("SELECT COUNT(*) FROM users WHERE UserName = $1", u.UserName).Scan(&count)
 	if err != nil {
-		return User{}, fmt.Errorf("database error: %v", err)
+		return fmt.Errorf("database error: %v", err)
 	}
 	if count > 0 {
-		return User{}, fmt.Errorf("username already taken")
+		return errUsernameTaken
 	}
 
 	// Check if email exists
-	if req.Email != "" {
-		err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE Email = $1", req.Email).Scan(&count)
+	if u.Email != "" {
+		err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE Email = $1", u.Email).Scan(&count)
 		if err != nil {
-			return User{}, fmt.Errorf("database error: %v", err)
+			return fmt.Errorf("database error: %v", err)
 		}
 		if count > 0 {
-			return User{}, fmt.Errorf("email already registered")
+			return errEmailTaken
 		}
 	}
 
-	// Hash password
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return User{}, fmt.Errorf("cannot hash password")
+	// Check if student ID exists
+	if u.StudentID != "" {
+		err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE StudentID = $1", u.StudentID).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		if count > 0 {
+			return errStudentIDTaken
+		}
 	}
 
 	// Insert into database
-	var userID int
-	err = DB.QueryRow(
-		"INSERT INTO users (FirstName, LastName, UserName, Email, PasswordHash, PhoneNumber, Role) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING UserID",
-		req.FirstName,
-		req.LastName,
-		req.UserName,
-		req.Email,
-		string(hashed),
-		req.PhoneNumber,
-		"Member",
-	).Scan(&userID)
+	err = tx.QueryRow(
+		`INSERT INTO users (FirstName, LastName, UserName, Email, PasswordHash, PhoneNumber, StudentID, Role, EmailVerifiedAt)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9) RETURNING UserID, created_at`,
+		u.FirstName,
+		u.LastName,
+		u.UserName,
+		u.Email,
+		u.PasswordHash,
+		u.PhoneNumber,
+		u.StudentID,
+		u.Role,
+		u.EmailVerifiedAt,
+	).Scan(&u.UserID, &u.CreatedAt)
 
 	if err != nil {
 		log.Printf("Error inserting user: %v", err)
-		return User{}, fmt.Errorf("failed to create user")
+		return fmt.Errorf("failed to create user")
 	}
 
-	user := User{
-		UserID:       userID,
-		FirstName:    req.FirstName,
-		LastName:     req.LastName,
-		UserName:     req.UserName,
-		PasswordHash: string(hashed),
-		Email:        req.Email,
-		PhoneNumber:  req.PhoneNumber,
-		Role:         "Member",
+	// An account must not exist without the consent it was created with
+	if consent != nil && len(consent.DocumentIDs) > 0 {
+		if err := acceptPoliciesTx(tx, u.UserID, consent.DocumentIDs, consent.IPAddress); err != nil {
+			return err
+		}
 	}
 
-	log.Printf("✅ User %s registered successfully (ID: %d)", req.UserName, userID)
-	return user, nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	return nil
 }
 
-func LoginUserDB(req LoginRequest) (*User, error) {
+func (s *PostgresStore) GetUser(userID int) (*User, error) {
 	var user User
-	var passwordHash string
-
-	err := DB.QueryRow(
-		"SELECT UserID, FirstName, LastName, UserName, Email, PhoneNumber, Role, PasswordHash FROM users WHERE UserName = $1",
-		req.UserName,
+	err := s.db.QueryRow(
+		`SELECT UserID, FirstName, COALESCE(LastName, ''), UserName, COALESCE(Email, ''), COALESCE(PhoneNumber, ''),
+		        COALESCE(StudentID, ''), Role, EmailVerifiedAt, COALESCE(ProfilePicture, ''), created_at, updated_at
+		 FROM users WHERE UserID = $1`,
+		userID,
 	).Scan(
 		&user.UserID,
 		&user.FirstName,
@@ -92,33 +112,32 @@ func LoginUserDB(req LoginRequest) (*User, error) {
 		&user.UserName,
 		&user.Email,
 		&user.PhoneNumber,
+		&user.StudentID,
 		&user.Role,
-		&passwordHash,
+		&user.EmailVerifiedAt,
+		&user.ProfilePicture,
+		&user.CreatedAt,
+		&user.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("invalid username or password")
+		return nil, errUserNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("database error: %v", err)
 	}
 
-	// Verify password
-	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password))
-	if err != nil {
-		return nil, fmt.Errorf("invalid username or password")
-	}
-
-	user.PasswordHash = passwordHash
-	log.Printf("✅ User %s logged in successfully", req.UserName)
 	return &user, nil
 }
 
-func GetUserDB(userID int) (*User, error) {
+func (s *PostgresStore) GetUserByUserName(username string) (*User, error) {
 	var user User
-	err := DB.QueryRow(
-		"SELECT UserID, FirstName, LastName, UserName, Email, PhoneNumber, Role FROM users WHERE UserID = $1",
-		userID,
+	err := s.db.QueryRow(
+		`SELECT UserID, FirstName, COALESCE(LastName, ''), UserName, COALESCE(Email, ''), COALESCE(PhoneNumber, ''),
+		        COALESCE(StudentID, ''), Role, EmailVerifiedAt, COALESCE(ProfilePicture, ''), created_at, updated_at,
+		        PasswordHash
+		 FROM users WHERE UserName = $1`,
+		username,
 	).Scan(
 		&user.UserID,
 		&user.FirstName,
@@ -126,11 +145,17 @@ func GetUserDB(userID int) (*User, error) {
 		&user.UserName,
 		&user.Email,
 		&user.PhoneNumber,
+		&user.StudentID,
 		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.ProfilePicture,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.PasswordHash,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+		return nil, errUserNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("database error: %v", err)
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Database-backed vouchers. Redemptions of cancelled or expired bookings do not count
+// towards the limits, so cancelling a booking gives the code back.
+
+const voucherColumns = `VoucherID, Code, Description, DiscountType, PercentOff, AmountOff, MaxDiscount,
+	ValidFrom, ValidUntil, GlobalLimit, PerUserLimit, SportType, CourtID, StartHour, EndHour, Active, created_at`
+
+const activeRedemptionSQL = `BookingID IN (SELECT BookingID FROM bookings WHERE BookingStatus NOT IN ('Cancelled', 'Expired'))`
+
+var (
+	errVoucherNotValid     = fmt.Errorf("voucher is not valid")
+	errVoucherExhausted    = fmt.Errorf("voucher has been fully redeemed")
+	errVoucherLimitReached = fmt.Errorf("voucher usage limit reached")
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func scanVoucher(row rowScanner) (*Voucher, error) {
+	var v Voucher
+	err := row.Scan(&v.VoucherID, &v.Code, &v.Description, &v.DiscountType, &v.PercentOff, &v.AmountOff, &v.MaxDiscount,
+		&v.ValidFrom, &v.ValidUntil, &v.GlobalLimit, &v.PerUserLimit, &v.SportType, &v.CourtID, &v.StartHour, &v.EndHour, &v.Active, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (h *Handler) CreateVoucherDB(v Voucher) (*Voucher, error) {
+	created, err := scanVoucher(h.DB.QueryRow(
+		`INSERT INTO vouchers (Code, Description, DiscountType, PercentOff, AmountOff, MaxDiscount,
+		 ValidFrom, ValidUntil, GlobalLimit, PerUserLimit, SportType, CourtID, StartHour, EndHour, Active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		 RETURNING `+voucherColumns,
+		v.Code, v.Description, v.DiscountType, v.PercentOff, v.AmountOff, v.MaxDiscount,
+		v.ValidFrom, v.ValidUntil, v.GlobalLimit, v.PerUserLimit, v.SportType, v.CourtID, v.StartHour, v.EndHour, v.Active,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("voucher code already exists")
+		}
+		log.Printf("Error creating voucher: %v", err)
+		return nil, fmt.Errorf("failed to create voucher")
+	}
+
+	log.Printf("✅ Voucher created (ID: %d, Code: %s)", created.VoucherID, created.Code)
+	return created, nil
+}
+
+func (h *Handler) GetVouchersDB() ([]Voucher, error) {
+	rows, err := h.DB.Query("SELECT " + voucherColumns + " FROM vouchers ORDER BY VoucherID DESC")
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	vouchers := []Voucher{}
+	for rows.Next() {
+		v, err := scanVoucher(rows)
+		if err != nil {
+			log.Printf("Error scanning voucher: %v", err)
+			continue
+		}
+		vouchers = append(vouchers, *v)
+	}
+
+	return vouchers, nil
+}
+
+func (h *Handler) GetVoucherDB(id int) (*Voucher, error) {
+	v, err := scanVoucher(h.DB.QueryRow("SELECT "+voucherColumns+" FROM vouchers WHERE VoucherID = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("voucher not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return v, nil
+}
+
+func (h *Handler) GetVoucherByCodeDB(code string) (*Voucher, error) {
+	v, err := scanVoucher(h.DB.QueryRow("SELECT "+voucherColumns+" FROM vouchers WHERE Code = $1", code))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("voucher not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return v, nil
+}
+
+func (h *Handler) UpdateVoucherDB(v Voucher) error {
+	result, err := h.DB.Exec(
+		`UPDATE vouchers SET Code = $2, Description = $3, DiscountType = $4, PercentOff = $5, AmountOff = $6,
+		 MaxDiscount = $7, ValidFrom = $8, ValidUntil = $9, GlobalLimit = $10, PerUserLimit = $11,
+		 SportType = $12, CourtID = $13, StartHour = $14, EndHour = $15, Active = $16
+		 WHERE VoucherID = $1`,
+		v.VoucherID, v.Code, v.Description, v.DiscountType, v.PercentOff, v.AmountOff,
+		v.MaxDiscount, v.ValidFrom, v.ValidUntil, v.GlobalLimit, v.PerUserLimit,
+		v.SportType, v.CourtID, v.StartHour, v.EndHour, v.Active,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("voucher code already exists")
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("voucher not found")
+	}
+	return nil
+}
+
+// DeleteVoucherDB removes a voucher that was never redeemed; redeemed ones must be deactivated instead
+func (h *Handler) DeleteVoucherDB(id int) error {
+	var redeemed bool
+	if err := h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM voucher_redemptions WHERE VoucherID = $1)", id).Scan(&redeemed); err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if redeemed {
+		return fmt.Errorf("voucher has redemptions, deactivate it instead")
+	}
+	return h.deleteByID("vouchers", "VoucherID", id, "voucher")
+}
+
+// checkVoucherUsage enforces the global and per-user limits
+func checkVoucherUsage(q queryer, v *Voucher, userID int) error {
+	var total, mine int
+	err := q.QueryRow(
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE UserID = $2)
+		 FROM voucher_redemptions WHERE VoucherID = $1 AND `+activeRedemptionSQL,
+		v.VoucherID, userID,
+	).Scan(&total, &mine)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if v.GlobalLimit != nil && total >= *v.GlobalLimit {
+		return errVoucherExhausted
+	}
+	if v.PerUserLimit != nil && mine >= *v.PerUserLimit {
+		return errVoucherLimitReached
+	}
+	return nil
+}
+
+// redeemVoucherTx records the redemption for a new booking. The voucher row is locked so
+// concurrent bookings cannot exceed the limits.
+func redeemVoucherTx(tx *sql.Tx, v *Voucher, userID, bookingID int, discount Money) error {
+	var active bool
+	err := tx.QueryRow("SELECT Active FROM vouchers WHERE VoucherID = $1 FOR UPDATE", v.VoucherID).Scan(&active)
+	if err != nil || !active {
+		return errVoucherNotValid
+	}
+
+	if err := checkVoucherUsage(tx, v, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO voucher_redemptions (VoucherID, UserID, BookingID, Discount) VALUES ($1, $2, $3, $4)",
+		v.VoucherID, userID, bookingID, discount,
+	)
+	if err != nil {
+		log.Printf("Error redeeming voucher: %v", err)
+		return fmt.Errorf("failed to redeem voucher")
+	}
+	return nil
+}
+
+func (h *Handler) GetVoucherRedemptionsDB(voucherID int) ([]VoucherRedemption, error) {
+	rows, err := h.DB.Query(
+		`SELECT r.RedemptionID, r.VoucherID, r.UserID, r.BookingID, r.Discount, b.BookingStatus, r.created_at
+		 FROM voucher_redemptions r JOIN bookings b ON b.BookingID = r.BookingID
+		 WHERE r.VoucherID = $1 ORDER BY r.RedemptionID DESC`,
+		voucherID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	redemptions := []VoucherRedemption{}
+	for rows.Next() {
+		var r VoucherRedemption
+		if err := rows.Scan(&r.RedemptionID, &r.VoucherID, &r.UserID, &r.BookingID, &r.Discount, &r.Status, &r.CreatedAt); err != nil {
+			log.Printf("Error scanning voucher redemption: %v", err)
+			continue
+		}
+		redemptions = append(redemptions, r)
+	}
+
+	return redemptions, nil
+}
+
+func (h *Handler) GetVoucherReportDB() ([]VoucherReport, error) {
+	rows, err := h.DB.Query(
+		`SELECT v.VoucherID, v.Code, COUNT(r.RedemptionID), COUNT(DISTINCT r.UserID),
+		 COALESCE(SUM(r.Discount), 0), v.GlobalLimit
+		 FROM vouchers v
+		 LEFT JOIN voucher_redemptions r ON r.VoucherID = v.VoucherID AND r.` + activeRedemptionSQL + `
+		 GROUP BY v.VoucherID ORDER BY v.VoucherID DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	report := []VoucherReport{}
+	for rows.Next() {
+		var r VoucherReport
+		if err := rows.Scan(&r.VoucherID, &r.Code, &r.Redemptions, &r.UniqueUsers, &r.TotalDiscount, &r.GlobalLimit); err != nil {
+			log.Printf("Error scanning voucher report: %v", err)
+			continue
+		}
+		report = append(report, r)
+	}
+
+	return report, nil
+}
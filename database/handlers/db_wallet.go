@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Database-backed wallet ledger. Every movement is a ledger transaction whose entries sum to
+// zero; accounts keep a cached Balance that is updated in the same database transaction.
+
+// System ledger accounts
+const (
+	AccountTopUps  = "system:topups"  // money paid in at the counter
+	AccountRevenue = "system:revenue" // court booking income
+)
+
+// Ledger transaction kinds
+const (
+	LedgerTopUp   = "topup"
+	LedgerBooking = "booking"
+	LedgerRefund  = "refund"
+)
+
+var errInsufficientBalance = fmt.Errorf("insufficient wallet balance")
+
+type ledgerEntry struct {
+	AccountID int
+	Amount    Money
+}
+
+// walletAccountTx returns the user's wallet account, creating it on first use, locked for update
+func walletAccountTx(tx *sql.Tx, userID int) (int, Money, error) {
+	_, err := tx.Exec(
+		`INSERT INTO ledger_accounts (Code, UserID) VALUES ($1, $2) ON CONFLICT (Code) DO NOTHING`,
+		fmt.Sprintf("wallet:%d", userID), userID,
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("database error: %v", err)
+	}
+
+	var accountID int
+	var balance Money
+	err = tx.QueryRow(
+		"SELECT AccountID, Balance FROM ledger_accounts WHERE UserID = $1 FOR UPDATE",
+		userID,
+	).Scan(&accountID, &balance)
+	if err != nil {
+		return 0, 0, fmt.Errorf("database error: %v", err)
+	}
+	return accountID, balance, nil
+}
+
+func systemAccountTx(tx *sql.Tx, code string) (int, error) {
+	_, err := tx.Exec(`INSERT INTO ledger_accounts (Code) VALUES ($1) ON CONFLICT (Code) DO NOTHING`, code)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	var accountID int
+	err = tx.QueryRow("SELECT AccountID FROM ledger_accounts WHERE Code = $1", code).Scan(&accountID)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	return accountID, nil
+}
+
+// postLedgerTx appends one balanced transaction and moves the cached balances
+func postLedgerTx(tx *sql.Tx, kind string, bookingID *int, description string, createdBy int, entries []ledgerEntry) (int, error) {
+	var sum Money
+	for _, e := range entries {
+		sum += e.Amount
+	}
+	if sum != 0 {
+		return 0, fmt.Errorf("unbalanced ledger transaction")
+	}
+
+	var txID int
+	err := tx.QueryRow(
+		`INSERT INTO ledger_transactions (Kind, BookingID, Description, CreatedBy)
+		 VALUES ($1, $2, $3, $4) RETURNING TxID`,
+		kind, bookingID, description, createdBy,
+	).Scan(&txID)
+	if err != nil {
+		log.Printf("Error creating ledger transaction: %v", err)
+		return 0, fmt.Errorf("failed to record transaction")
+	}
+
+	for _, e := range entries {
+		if _, err := tx.Exec(
+			"INSERT INTO ledger_entries (TxID, AccountID, Amount) VALUES ($1, $2, $3)",
+			txID, e.AccountID, e.Amount,
+		); err != nil {
+			log.Printf("Error creating ledger entry: %v", err)
+			return 0, fmt.Errorf("failed to record transaction")
+		}
+		if _, err := tx.Exec(
+			"UPDATE ledger_accounts SET Balance = Balance + $2 WHERE AccountID = $1",
+			e.AccountID, e.Amount,
+		); err != nil {
+			return 0, fmt.Errorf("database error: %v", err)
+		}
+	}
+
+	return txID, nil
+}
+
+// debitWalletTx moves amount from the user's wallet to revenue, failing if the balance is short.
+// The wallet row stays locked until tx ends, so concurrent debits cannot overspend.
+func debitWalletTx(tx *sql.Tx, userID int, amount Money, bookingID int) error {
+	walletID, balance, err := walletAccountTx(tx, userID)
+	if err != nil {
+		return err
+	}
+	if balance < amount {
+		return errInsufficientBalance
+	}
+
+	revenueID, err := systemAccountTx(tx, AccountRevenue)
+	if err != nil {
+		return err
+	}
+
+	_, err = postLedgerTx(tx, LedgerBooking, &bookingID, fmt.Sprintf("Booking #%d", bookingID), userID, []ledgerEntry{
+		{AccountID: walletID, Amount: -amount},
+		{AccountID: revenueID, Amount: amount},
+	})
+	return err
+}
+
+// refundWalletTx moves amount from revenue back to the user's wallet
+func refundWalletTx(tx *sql.Tx, userID int, amount Money, bookingID int, actorID int) error {
+	walletID, _, err := walletAccountTx(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	revenueID, err := systemAccountTx(tx, AccountRevenue)
+	if err != nil {
+		return err
+	}
+
+	_, err = postLedgerTx(tx, LedgerRefund, &bookingID, fmt.Sprintf("Refund for booking #%d", bookingID), actorID, []ledgerEntry{
+		{AccountID: revenueID, Amount: -amount},
+		{AccountID: walletID, Amount: amount},
+	})
+	return err
+}
+
+func (h *Handler) TopUpWalletDB(userID int, amount Money, note string, adminID int) (Money, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE UserID = $1)", userID).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("user not found")
+	}
+
+	walletID, balance, err := walletAccountTx(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	topUpsID, err := systemAccountTx(tx, AccountTopUps)
+	if err != nil {
+		return 0, err
+	}
+
+	if note == "" {
+		note = "Wallet top-up"
+	}
+	_, err = postLedgerTx(tx, LedgerTopUp, nil, note, adminID, []ledgerEntry{
+		{AccountID: topUpsID, Amount: -amount},
+		{AccountID: walletID, Amount: amount},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("✅ Wallet topped up (User: %d, Amount: %s)", userID, amount)
+	return balance + amount, nil
+}
+
+func (h *Handler) GetWalletBalanceDB(userID int) (Money, error) {
+	var balance Money
+	err := h.DB.QueryRow("SELECT Balance FROM ledger_accounts WHERE UserID = $1", userID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	return balance, nil
+}
+
+func (h *Handler) GetWalletTransactionsDB(userID int, limit, offset int) ([]WalletTransaction, int, error) {
+	var total int
+	err := h.DB.QueryRow(
+		`SELECT COUNT(*) FROM ledger_entries e
+		 JOIN ledger_accounts a ON a.AccountID = e.AccountID
+		 WHERE a.UserID = $1`,
+		userID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("database error: %v", err)
+	}
+
+	rows, err := h.DB.Query(
+		`SELECT t.TxID, t.Kind, e.Amount, t.BookingID, t.Description, t.created_at
+		 FROM ledger_entries e
+		 JOIN ledger_accounts a ON a.AccountID = e.AccountID
+		 JOIN ledger_transactions t ON t.TxID = e.TxID
+		 WHERE a.UserID = $1
+		 ORDER BY t.TxID DESC LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	txs := []WalletTransaction{}
+	for rows.Next() {
+		var t WalletTransaction
+		if err := rows.Scan(&t.TxID, &t.Kind, &t.Amount, &t.BookingID, &t.Description, &t.CreatedAt); err != nil {
+			log.Printf("Error scanning wallet transaction: %v", err)
+			continue
+		}
+		txs = append(txs, t)
+	}
+
+	return txs, total, nil
+}
+
+// ReconcileLedgerDB reports unbalanced transactions and accounts whose cached balance drifted
+func (h *Handler) ReconcileLedgerDB() (LedgerReconciliation, error) {
+	r := LedgerReconciliation{UnbalancedTransactions: []int{}, MismatchedAccounts: []int{}}
+
+	rows, err := h.DB.Query("SELECT TxID FROM ledger_entries GROUP BY TxID HAVING SUM(Amount) <> 0")
+	if err != nil {
+		return r, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			r.UnbalancedTransactions = append(r.UnbalancedTransactions, id)
+		}
+	}
+
+	accRows, err := h.DB.Query(
+		`SELECT a.AccountID FROM ledger_accounts a
+		 LEFT JOIN ledger_entries e ON e.AccountID = a.AccountID
+		 GROUP BY a.AccountID, a.Balance
+		 HAVING a.Balance <> COALESCE(SUM(e.Amount), 0)`,
+	)
+	if err != nil {
+		return r, fmt.Errorf("database error: %v", err)
+	}
+	defer accRows.Close()
+	for accRows.Next() {
+		var id int
+		if err := accRows.Scan(&id); err == nil {
+			r.MismatchedAccounts = append(r.MismatchedAccounts, id)
+		}
+	}
+
+	if err := h.DB.QueryRow("SELECT COALESCE(SUM(Amount), 0) FROM ledger_entries").Scan(&r.LedgerTotal); err != nil {
+		return r, fmt.Errorf("database error: %v", err)
+	}
+	if err := h.DB.QueryRow("SELECT COALESCE(SUM(Balance), 0) FROM ledger_accounts WHERE UserID IS NOT NULL").Scan(&r.WalletTotal); err != nil {
+		return r, fmt.Errorf("database error: %v", err)
+	}
+
+	r.Balanced = len(r.UnbalancedTransactions) == 0 && len(r.MismatchedAccounts) == 0 && r.LedgerTotal == 0
+	return r, nil
+}
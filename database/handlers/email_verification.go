@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Email verification links carry a signed, stateless token: "<userID>.<expiry>.<hmac>".
+// The HMAC also covers the email address, so a link stops working if the email changes.
+
+var (
+	emailVerifySecret = []byte("your-verification-secret-change-in-production")
+	emailVerifyTTL    = 48 * time.Hour
+
+	// Resend limits: at most one mail per cooldown and a handful per day
+	verificationResendCooldown = 2 * time.Minute
+	verificationDailyLimit     = 5
+
+	// allowedEmailDomains restricts registration when non-empty, e.g. ["uni.th"]
+	allowedEmailDomains []string
+)
+
+var errEmailNotVerified = fmt.Errorf("email address not verified")
+
+// SetEmailVerification sets the link signing secret and the registration domain allowlist
+func SetEmailVerification(secret string, domains []string) {
+	if secret != "" {
+		emailVerifySecret = []byte(secret)
+	}
+	allowedEmailDomains = nil
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d != "" {
+			allowedEmailDomains = append(allowedEmailDomains, d)
+		}
+	}
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// POST /api/auth/email/verify
+func (h *Handler) HandleVerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if err := h.VerifyEmailDB(req.Token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// POST /api/auth/email/resend
+func (h *Handler) HandleResendVerification(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	user, err := h.Users.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "email already verified"})
+		return
+	}
+
+	retryAfter, err := h.ReserveVerificationSendDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.5)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "verification email sent recently, try again later"})
+		return
+	}
+
+	go SendEmailVerification(*user)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
+
+// RequireVerifiedEmail ตรวจสอบว่า user ยืนยันอีเมลแล้ว (use after AuthMiddleware)
+func (h *Handler) RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verified, err := h.IsEmailVerifiedDB(c.MustGet("userID").(int))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": errEmailNotVerified.Error()})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Internal functions
+
+// EmailDomainAllowed reports whether email may register under the domain allowlist.
+// Subdomains of an allowed domain are accepted too (student.uni.th for uni.th).
+func EmailDomainAllowed(email string) bool {
+	if len(allowedEmailDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range allowedEmailDomains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func emailVerificationSignature(userID int, expires int64, email string) string {
+	mac := hmac.New(sha256.New, emailVerifySecret)
+	fmt.Fprintf(mac, "%d.%d.%s", userID, expires, strings.ToLower(email))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewEmailVerificationToken signs a verification token for the user's current email
+func NewEmailVerificationToken(user User) string {
+	expires := GetCurrentTime().Add(emailVerifyTTL).Unix()
+	return fmt.Sprintf("%d.%d.%s", user.UserID, expires, emailVerificationSignature(user.UserID, expires, user.Email))
+}
+
+// parseEmailVerificationToken returns the user id and the signature to check once the email is known
+func parseEmailVerificationToken(token string) (int, int64, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, 0, "", fmt.Errorf("invalid verification link")
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("invalid verification link")
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("invalid verification link")
+	}
+	if GetCurrentTime().Unix() > expires {
+		return 0, 0, "", fmt.Errorf("verification link has expired")
+	}
+	return userID, expires, parts[2], nil
+}
+
+// SendEmailVerification mails the verification link to the user
+func SendEmailVerification(user User) {
+	if user.Email == "" {
+		return
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", appBaseURL, url.QueryEscape(NewEmailVerificationToken(user)))
+	body := fmt.Sprintf(
+		"Hi %s,\n\nPlease confirm your email address to start booking courts:\n\n%s\n\n"+
+			"The link is valid for %d hours.",
+		user.FirstName, link, int(emailVerifyTTL/time.Hour),
+	)
+
+	if err := accountMailer.SendMail(user.Email, "Confirm your email address", body); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.UserID, err)
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultNotificationPageSize = 20
+	maxNotificationPageSize     = 100
+)
+
+// GET /api/notifications
+func (h *Handler) HandleGetNotifications(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	page, pageSize, err := ParsePagination(c, defaultNotificationPageSize, maxNotificationPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	notifications, total, err := h.GetNotificationsDB(userID, unreadOnly, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	unread, err := h.CountUnreadNotificationsDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         notifications,
+		"page":         page,
+		"page_size":    pageSize,
+		"total":        total,
+		"unread_count": unread,
+	})
+}
+
+// GET /api/notifications/unread-count
+func (h *Handler) HandleGetUnreadNotificationCount(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	unread, err := h.CountUnreadNotificationsDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+}
+
+// POST /api/notifications/:notificationId/read
+func (h *Handler) HandleMarkNotificationRead(c *gin.Context) {
+	nid, err := strconv.Atoi(c.Param("notificationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+		return
+	}
+
+	userID := c.MustGet("userID").(int)
+
+	if err := h.MarkNotificationReadDB(userID, nid); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notification marked as read"})
+}
+
+// POST /api/notifications/read-all
+func (h *Handler) HandleMarkAllNotificationsRead(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	updated, err := h.MarkAllNotificationsReadDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "all notifications marked as read",
+		"updated": updated,
+	})
+}
+
+// DELETE /api/notifications/:notificationId
+func (h *Handler) HandleDeleteNotification(c *gin.Context) {
+	nid, err := strconv.Atoi(c.Param("notificationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+		return
+	}
+
+	userID := c.MustGet("userID").(int)
+
+	if err := h.DeleteNotificationDB(userID, nid); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notification deleted"})
+}
+
+// Internal functions
+
+// ParsePagination reads ?page= and ?page_size= with defaults and an upper bound
+func ParsePagination(c *gin.Context, defaultSize, maxSize int) (int, int, error) {
+	page := 1
+	pageSize := defaultSize
+
+	if p := c.Query("page"); p != "" {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 1 {
+			return 0, 0, fmt.Errorf("invalid page")
+		}
+		page = v
+	}
+	if ps := c.Query("page_size"); ps != "" {
+		v, err := strconv.Atoi(ps)
+		if err != nil || v < 1 {
+			return 0, 0, fmt.Errorf("invalid page_size")
+		}
+		pageSize = v
+	}
+	if pageSize > maxSize {
+		pageSize = maxSize
+	}
+
+	return page, pageSize, nil
+}
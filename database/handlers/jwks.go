@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Asymmetric signing keys. One key signs new tokens; every loaded key (including retired
+// ones kept around during rotation) verifies tokens and is published at /.well-known/jwks.json.
+
+type jwtKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey // nil for verification-only keys
+	Public  crypto.PublicKey
+}
+
+var (
+	jwtKeysMu     sync.RWMutex
+	jwtSigningKey *jwtKey
+	jwtVerifyKeys = map[string]*jwtKey{}
+)
+
+// JWK is one entry of a JSON Web Key Set (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// LoadSigningKeyFile loads a PEM private key (RSA or Ed25519) that signs new tokens.
+// kid may be empty, in which case it is derived from the public key.
+func LoadSigningKeyFile(path, kid string) error {
+	key, err := loadJWTKeyFile(path, kid)
+	if err != nil {
+		return err
+	}
+	if key.Private == nil {
+		return fmt.Errorf("%s: signing key must be a private key", path)
+	}
+
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	jwtSigningKey = key
+	jwtVerifyKeys[key.ID] = key
+
+	log.Printf("✅ JWT signing key loaded (kid: %s, alg: %s)", key.ID, key.Method.Alg())
+	return nil
+}
+
+// LoadVerificationKeyFile loads a PEM public (or private) key that is only used to verify
+// tokens, e.g. the previous signing key while its tokens are still valid.
+func LoadVerificationKeyFile(path, kid string) error {
+	key, err := loadJWTKeyFile(path, kid)
+	if err != nil {
+		return err
+	}
+	key.Private = nil
+
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	jwtVerifyKeys[key.ID] = key
+
+	log.Printf("✅ JWT verification key loaded (kid: %s, alg: %s)", key.ID, key.Method.Alg())
+	return nil
+}
+
+// GET /.well-known/jwks.json
+func HandleJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": PublicJWKs()})
+}
+
+// Internal functions
+
+func loadJWTKeyFile(path, kid string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key file: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	key := &jwtKey{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key.Private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key.Private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key.Public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if key.Private != nil {
+		signer, ok := key.Private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported private key", path)
+		}
+		key.Public = signer.Public()
+	}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("%s: RSA keys must be at least 2048 bits", path)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", path)
+	}
+
+	key.ID = kid
+	if key.ID == "" {
+		der, err := x509.MarshalPKIXPublicKey(key.Public)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		sum := sha256.Sum256(der)
+		key.ID = base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+
+	return key, nil
+}
+
+func currentSigningKey() *jwtKey {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	return jwtSigningKey
+}
+
+// verificationKey returns the key for kid; configured is false when only the HS256 secret is in use
+func verificationKey(kid string) (key *jwtKey, configured bool) {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	return jwtVerifyKeys[kid], len(jwtVerifyKeys) > 0
+}
+
+// PublicJWKs returns every verification key in JWK form, sorted by kid
+func PublicJWKs() []JWK {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+
+	keys := []JWK{}
+	for _, k := range jwtVerifyKeys {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+		switch pub := k.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		keys = append(keys, jwk)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
@@ -1,45 +1,147 @@
 package handlers
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// jwtSecret signs HS256 tokens when no signing key file is configured (development only)
 var jwtSecret = []byte("your-secret-key-change-in-production")
 
+// SetJWTSecret sets the HS256 secret
+func SetJWTSecret(secret string) {
+	if secret != "" {
+		jwtSecret = []byte(secret)
+	}
+}
+
+// Issuer and audience put into every token and required when verifying
+var (
+	jwtIssuer   = "court-booking"
+	jwtAudience = "court-booking"
+)
+
+// SetTokenIssuer sets the iss and aud claims
+func SetTokenIssuer(issuer, audience string) {
+	if issuer != "" {
+		jwtIssuer = issuer
+	}
+	if audience != "" {
+		jwtAudience = audience
+	}
+}
+
+// Token lifetimes; access tokens are short-lived and renewed with a refresh token
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// SetTokenLifetimes sets how long access and refresh tokens are valid
+func SetTokenLifetimes(access, refresh time.Duration) {
+	if access > 0 {
+		accessTokenTTL = access
+	}
+	if refresh > 0 {
+		refreshTokenTTL = refresh
+	}
+}
+
 type Claims struct {
 	UserID int    `json:"user_id"`
 	Role   string `json:"role"`
+	// SessionID ties the token to a login session so it can be revoked
+	SessionID string `json:"sid"`
+	// TokenVersion must match users.TokenVersion; bumping it revokes every token of the user
+	TokenVersion int `json:"ver"`
+	// MFA is set when the session was started with a second factor
+	MFA bool `json:"mfa,omitempty"`
+	// Permissions of the role when the token was issued
+	Permissions []string `json:"perms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenPair is what login and refresh hand back to the client
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
 // GenerateToken สร้าง JWT token
-func GenerateToken(userID int, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+func GenerateToken(userID int, role string, sessionID string, version int, mfa bool, permissions []string) (string, error) {
+	now := GetCurrentTime()
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:       userID,
+		Role:         role,
+		SessionID:    sessionID,
+		TokenVersion: version,
+		MFA:          mfa,
+		Permissions:  permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			Issuer:    jwtIssuer,
+			Subject:   fmt.Sprint(userID),
+			Audience:  jwt.ClaimStrings{jwtAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
-	return tokenString, err
+	key := currentSigningKey()
+	if key == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(jwtSecret)
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
 }
 
 // VerifyToken ตรวจสอบ JWT token
 func VerifyToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, jwtKeyFunc,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA", "HS256"}),
+		jwt.WithIssuer(jwtIssuer),
+		jwt.WithAudience(jwtAudience),
+		jwt.WithLeeway(30*time.Second),
+		jwt.WithTimeFunc(GetCurrentTime),
+	)
 
 	if err != nil || !token.Valid {
 		return nil, err
 	}
 
+	// The library only checks exp/nbf when present; we require them
+	if claims.ExpiresAt == nil || claims.NotBefore == nil {
+		return nil, fmt.Errorf("token has no exp or nbf claim")
+	}
+
 	return claims, nil
 }
+
+// jwtKeyFunc picks the verification key by kid. HS256 is only accepted while no
+// asymmetric keys are configured, so a leaked default secret cannot forge tokens.
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, configured := verificationKey(kid)
+
+	if !configured {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return jwtSecret, nil
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("signing method does not match key")
+	}
+	return key.Public, nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer sends plain-text email. Used by the email notification channel and account emails.
+type Mailer interface {
+	SendMail(to, subject, body string) error
+}
+
+// SMTPMailer sends mail through an SMTP relay
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) SendMail(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer writes mail to the log instead of sending it (development only)
+type LogMailer struct{}
+
+func (LogMailer) SendMail(to, subject, body string) error {
+	log.Printf("📧 Mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+var (
+	accountMailer Mailer = LogMailer{}
+	appBaseURL           = "http://localhost:3000"
+)
+
+// SetAccountMailer sets the mailer for account emails (password reset, verification)
+// and the frontend URL that links in those emails point to
+func SetAccountMailer(m Mailer, baseURL string) {
+	if m != nil {
+		accountMailer = m
+	}
+	if baseURL != "" {
+		appBaseURL = strings.TrimRight(baseURL, "/")
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps users, courts and bookings in process memory. It is meant for tests and
+// local experiments: nothing survives a restart, and bookings are free only because payments,
+// vouchers and wallets live in the database.
+type MemoryStore struct {
+	mu            sync.Mutex
+	users         []User
+	courts        []Court
+	bookings      []Booking
+	nextUserID    int
+	nextCourtID   int
+	nextBookingID int
+}
+
+var (
+	errPaidBookingsUnsupported = fmt.Errorf("paid bookings need a database-backed store")
+	errPoliciesUnsupported     = fmt.Errorf("policy acceptance needs a database-backed store")
+)
+
+// NewMemoryStores returns empty in-memory stores
+func NewMemoryStores() Stores {
+	s := &MemoryStore{nextUserID: 1, nextCourtID: 1, nextBookingID: 1}
+	return Stores{Users: s, Courts: s, Bookings: s}
+}
+
+func (s *MemoryStore) CreateUser(u *User, consent *PolicyConsent) error {
+	if consent != nil && len(consent.DocumentIDs) > 0 {
+		return errPoliciesUnsupported
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		switch {
+		case existing.UserName == u.UserName:
+			return errUsernameTaken
+		case u.Email != "" && existing.Email == u.Email:
+			return errEmailTaken
+		case u.StudentID != "" && existing.StudentID == u.StudentID:
+			return errStudentIDTaken
+		}
+	}
+
+	u.UserID = s.nextUserID
+	u.CreatedAt = GetCurrentTime()
+	s.nextUserID++
+	s.users = append(s.users, *u)
+	return nil
+}
+
+func (s *MemoryStore) GetUser(userID int) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.UserID == userID {
+			u.PasswordHash = ""
+			return &u, nil
+		}
+	}
+	return nil, errUserNotFound
+}
+
+func (s *MemoryStore) GetUserByUserName(username string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.UserName == username {
+			return &u, nil
+		}
+	}
+	return nil, errUserNotFound
+}
+
+func (s *MemoryStore) SportTypes() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var sportTypes []string
+	for _, c := range s.courts {
+		if !seen[c.SportType] {
+			seen[c.SportType] = true
+			sportTypes = append(sportTypes, c.SportType)
+		}
+	}
+	sort.Strings(sportTypes)
+	return sportTypes, nil
+}
+
+func (s *MemoryStore) ListCourts(sportType string) ([]Court, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var courts []Court
+	for _, c := range s.courts {
+		if sportType == "" || c.SportType == sportType {
+			courts = append(courts, c)
+		}
+	}
+	sort.SliceStable(courts, func(i, j int) bool {
+		if courts[i].SportType != courts[j].SportType {
+			return courts[i].SportType < courts[j].SportType
+		}
+		return courts[i].CourtName < courts[j].CourtName
+	})
+	return courts, nil
+}
+
+func (s *MemoryStore) GetCourt(courtID int) (*Court, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.courtIndex(courtID); i >= 0 {
+		c := s.courts[i]
+		return &c, nil
+	}
+	return nil, errCourtNotFound
+}
+
+func (s *MemoryStore) CreateCourt(c *Court) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.courts {
+		if existing.SportType == c.SportType && existing.CourtNumber == c.CourtNumber {
+			return fmt.Errorf("court %d of %s already exists", c.CourtNumber, c.SportType)
+		}
+	}
+
+	c.CourtID = s.nextCourtID
+	s.nextCourtID++
+	s.courts = append(s.courts, *c)
+	return nil
+}
+
+func (s *MemoryStore) SetCourtStatus(courtID int, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.courtIndex(courtID)
+	if i < 0 {
+		return errCourtNotFound
+	}
+	s.courts[i].Status = status
+	return nil
+}
+
+func (s *MemoryStore) CreateBooking(nb NewBooking) (Booking, *Payment, error) {
+	if nb.Quote.Total > 0 || nb.Voucher != nil {
+		return Booking{}, nil, errPaidBookingsUnsupported
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.courtIndex(nb.CourtID) < 0 {
+		return Booking{}, nil, errCourtNotFound
+	}
+	for _, b := range s.bookings {
+		if b.CourtID == nb.CourtID && b.BookingStatus == StatusConfirmed &&
+			b.StartTime.Before(nb.EndTime) && b.EndTime.After(nb.StartTime) {
+			return Booking{}, nil, errCourtBooked
+		}
+	}
+
+	booking := Booking{
+		BookingID:     s.nextBookingID,
+		CourtID:       nb.CourtID,
+		UserID:        nb.UserID,
+		StartTime:     nb.StartTime,
+		EndTime:       nb.EndTime,
+		BookingStatus: StatusConfirmed,
+		Price:         nb.Quote.Total,
+		Breakdown:     nb.Quote.Lines,
+		CreatedAt:     GetCurrentTime(),
+	}
+	s.nextBookingID++
+	s.bookings = append(s.bookings, booking)
+	return booking, nil, nil
+}
+
+func (s *MemoryStore) GetBooking(bookingID int) (*Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.bookingIndex(bookingID); i >= 0 {
+		b := s.bookings[i]
+		return &b, nil
+	}
+	return nil, errBookingNotFound
+}
+
+func (s *MemoryStore) UserBookings(userID int) ([]Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bookings []Booking
+	for _, b := range s.bookings {
+		if b.UserID == userID {
+			b.Breakdown, b.CreatedAt = nil, time.Time{}
+			bookings = append(bookings, b)
+		}
+	}
+	sort.SliceStable(bookings, func(i, j int) bool { return bookings[i].StartTime.After(bookings[j].StartTime) })
+	return bookings, nil
+}
+
+func (s *MemoryStore) UpcomingBookings(courtID int) ([]Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := GetCurrentTime()
+	var bookings []Booking
+	for _, b := range s.bookings {
+		if b.BookingStatus == StatusConfirmed && b.StartTime.After(now) && (courtID == 0 || b.CourtID == courtID) {
+			bookings = append(bookings, Booking{
+				BookingID: b.BookingID,
+				UserID:    b.UserID,
+				CourtID:   b.CourtID,
+				StartTime: b.StartTime,
+				EndTime:   b.EndTime,
+			})
+		}
+	}
+	sort.SliceStable(bookings, func(i, j int) bool { return bookings[i].StartTime.Before(bookings[j].StartTime) })
+	return bookings, nil
+}
+
+func (s *MemoryStore) ActiveBookings(sportType string, from, to time.Time) ([]Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sportOf := make(map[int]string, len(s.courts))
+	for _, c := range s.courts {
+		sportOf[c.CourtID] = c.SportType
+	}
+
+	var bookings []Booking
+	for _, b := range s.bookings {
+		if sportOf[b.CourtID] == sportType && b.BookingStatus == StatusConfirmed &&
+			b.StartTime.Before(to) && b.EndTime.After(from) {
+			bookings = append(bookings, Booking{
+				BookingID:     b.BookingID,
+				UserID:        b.UserID,
+				CourtID:       b.CourtID,
+				StartTime:     b.StartTime,
+				EndTime:       b.EndTime,
+				BookingStatus: b.BookingStatus,
+			})
+		}
+	}
+	return bookings, nil
+}
+
+func (s *MemoryStore) CancelBooking(bookingID, actorID int) (Money, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.bookingIndex(bookingID)
+	if i < 0 {
+		return 0, errBookingNotFound
+	}
+	if status := s.bookings[i].BookingStatus; status != StatusConfirmed {
+		return 0, fmt.Errorf("booking is already %s", strings.ToLower(status))
+	}
+	s.bookings[i].BookingStatus = StatusCancelled
+	return 0, nil
+}
+
+// Internal functions
+
+func (s *MemoryStore) courtIndex(courtID int) int {
+	for i := range s.courts {
+		if s.courts[i].CourtID == courtID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) bookingIndex(bookingID int) int {
+	for i := range s.bookings {
+		if s.bookings[i].BookingID == bookingID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Two-factor authentication with TOTP (RFC 6238: SHA-1, 6 digits, 30 second steps), as
+// supported by Google Authenticator, Microsoft Authenticator and friends. Optional for
+// members, mandatory for admins: AdminMiddleware only accepts tokens of MFA sessions.
+
+var (
+	mfaIssuer          = "Court Booking" // shown in the authenticator app
+	mfaEncryptionKey   = randomMFAEncryptionKey()
+	mfaChallengeTTL    = 5 * time.Minute
+	mfaChallengeTries  = 5
+	recoveryCodeCount  = 10
+	totpPeriod         = int64(30)
+	totpSkew           = int64(1) // accept codes one step early or late
+	totpDigits         = 6
+	recoveryCodeLength = 10
+)
+
+var (
+	errMFARequired         = fmt.Errorf("admin accounts must sign in with two-factor authentication")
+	errMFANotEnabled       = fmt.Errorf("two-factor authentication is not enabled")
+	errMFAAlreadyEnabled   = fmt.Errorf("two-factor authentication is already enabled")
+	errInvalidMFACode      = fmt.Errorf("invalid authentication code")
+	errInvalidMFAChallenge = fmt.Errorf("invalid or expired two-factor login, please sign in again")
+)
+
+// SetMFAEncryptionKey sets the key that encrypts TOTP secrets at rest. Without one, a random
+// key lives as long as the process, so enrollments do not survive a restart.
+func SetMFAEncryptionKey(secret string) {
+	if secret != "" {
+		mfaEncryptionKey = sha256.Sum256([]byte(secret))
+	}
+}
+
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	Required          bool       `json:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// POST /api/auth/mfa/verify - second login step, with a TOTP or recovery code
+func (h *Handler) HandleVerifyMFA(c *gin.Context) {
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	user, err := h.VerifyMFAChallengeDB(req.MFAToken, req.Code)
+	if err == errInvalidMFAChallenge || err == errInvalidMFACode {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.CreateSessionDB(user, true, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "login success",
+		"user":    FormatLoginResponse(user, tokens),
+	})
+}
+
+// GET /api/auth/mfa
+func (h *Handler) HandleGetMFAStatus(c *gin.Context) {
+	status, err := h.GetMFAStatusDB(c.MustGet("userID").(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status.Required = c.GetString("role") == "Admin"
+
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// POST /api/auth/mfa/setup - starts enrollment; the secret is confirmed with /enable
+func (h *Handler) HandleSetupMFA(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	user, err := h.Users.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := h.BeginMFASetupDB(userID)
+	if err == errMFAAlreadyEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "scan the QR code with an authenticator app, then confirm with a code",
+		"secret":           encoded,
+		"provisioning_uri": totpProvisioningURI(user.UserName, encoded),
+	})
+}
+
+// POST /api/auth/mfa/enable
+func (h *Handler) HandleEnableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	userID := c.MustGet("userID").(int)
+
+	codes, err := h.EnableMFADB(userID, req.Code)
+	if err == errInvalidMFACode || err == errMFAAlreadyEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Enabling revoked the old sessions; continue in a fresh MFA session
+	user, err := h.Users.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tokens, err := h.CreateSessionDB(user, true, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "two-factor authentication enabled, store the recovery codes somewhere safe",
+		"recovery_codes": codes,
+		"user":           FormatLoginResponse(user, tokens),
+	})
+}
+
+// POST /api/auth/mfa/disable
+func (h *Handler) HandleDisableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if c.GetString("role") == "Admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is mandatory for admin accounts"})
+		return
+	}
+
+	if err := h.DisableMFADB(c.MustGet("userID").(int), req.Code); err == errInvalidMFACode || err == errMFANotEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// POST /api/auth/mfa/recovery-codes - replaces the recovery codes
+func (h *Handler) HandleRegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	codes, err := h.RegenerateRecoveryCodesDB(c.MustGet("userID").(int), req.Code)
+	if err == errInvalidMFACode || err == errMFANotEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "recovery codes regenerated", "recovery_codes": codes})
+}
+
+// POST /api/admin/users/:id/mfa/reset - for users who lost their authenticator and codes
+func (h *Handler) HandleResetUserMFA(c *gin.Context) {
+	uid, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.ResetMFADB(uid, c.MustGet("userID").(int)); err == errMFANotEnabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication reset"})
+}
+
+// Internal functions
+
+func randomMFAEncryptionKey() [32]byte {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(fmt.Sprintf("cannot generate MFA encryption key: %v", err))
+	}
+	return key
+}
+
+func totpProvisioningURI(username, secret string) string {
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {mfaIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {strconv.Itoa(totpDigits)},
+		"period":    {strconv.FormatInt(totpPeriod, 10)},
+	}
+	label := url.PathEscape(mfaIssuer + ":" + username)
+	// Authenticator apps expect %20 rather than + for spaces
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// totpCode is the HOTP value (RFC 4226) of the time step
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// matchTOTP returns the step the code belongs to. Steps at or before lastStep were already
+// used and are rejected, so a code cannot be replayed.
+func matchTOTP(secret []byte, code string, lastStep int64, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if hmac.Equal([]byte(totpCode(secret, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeMFACode strips the spaces and dashes people type into codes
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRecoveryCodes returns codes formatted for display ("3f9a1-c07e2") and their hashes
+func newRecoveryCodes() ([]string, []string) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength/2)
+		rand.Read(b)
+		raw := hex.EncodeToString(b)
+		codes[i] = raw[:recoveryCodeLength/2] + "-" + raw[recoveryCodeLength/2:]
+		hashes[i] = hashOpaqueToken(raw)
+	}
+	return codes, hashes
+}
+
+// encryptMFASecret seals a TOTP secret with AES-GCM
+func encryptMFASecret(secret []byte) (string, error) {
+	block, err := aes.NewCipher(mfaEncryptionKey[:])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, secret, nil)), nil
+}
+
+func decryptMFASecret(sealed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(mfaEncryptionKey[:])
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("malformed secret")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
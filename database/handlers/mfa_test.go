@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+// SHA-1 test vectors from RFC 6238 appendix B
+func TestTOTPCodeRFC6238(t *testing.T) {
+	digits := totpDigits
+	totpDigits = 8
+	t.Cleanup(func() { totpDigits = digits })
+
+	secret := []byte("12345678901234567890")
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		if got := totpCode(secret, tc.unix/totpPeriod); got != tc.want {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestMatchTOTPRejectsReplay(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+
+	for _, tc := range []struct {
+		name     string
+		step     int64
+		lastStep int64
+		ok       bool
+	}{
+		{"current step", current, 0, true},
+		{"one step early", current - 1, 0, true},
+		{"one step late", current + 1, 0, true},
+		{"outside the skew", current - 2, 0, false},
+		{"already used", current, current, false},
+		{"older than the last used", current - 1, current, false},
+		{"newer than the last used", current + 1, current, true},
+	} {
+		step, ok := matchTOTP(secret, totpCode(secret, tc.step), tc.lastStep, now)
+		if ok != tc.ok || (ok && step != tc.step) {
+			t.Errorf("%s: matchTOTP = %d, %v; want %d, %v", tc.name, step, ok, tc.step, tc.ok)
+		}
+	}
+}
+
+func TestMFASecretRoundTrip(t *testing.T) {
+	key := mfaEncryptionKey
+	t.Cleanup(func() { mfaEncryptionKey = key })
+
+	sealed, err := encryptMFASecret([]byte("12345678901234567890"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decryptMFASecret(sealed); err != nil || string(got) != "12345678901234567890" {
+		t.Fatalf("decryptMFASecret = %q, %v", got, err)
+	}
+
+	SetMFAEncryptionKey("another key")
+	if _, err := decryptMFASecret(sealed); err == nil {
+		t.Error("secret opened with a different key")
+	}
+}
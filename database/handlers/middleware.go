@@ -8,7 +8,7 @@ import (
 )
 
 // AuthMiddleware ตรวจสอบ JWT token
-func AuthMiddleware() gin.HandlerFunc {
+func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		
@@ -34,14 +34,29 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Reject tokens of revoked sessions or from before a "log out everywhere"
+		if err := h.CheckSessionDB(claims); err == errTokenRevoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
 		// Set user ID and role in context
 		c.Set("userID", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("mfa", claims.MFA)
+		c.Set("permissions", claims.Permissions)
 		c.Next()
 	}
 }
 
 // AdminMiddleware ตรวจสอบว่า user เป็น admin
+// Admin tokens must come from a session that passed two-factor authentication
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
@@ -50,6 +65,11 @@ func AdminMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if !c.GetBool("mfa") {
+			c.JSON(http.StatusForbidden, gin.H{"error": errMFARequired.Error(), "mfa_required": true})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
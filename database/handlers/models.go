@@ -6,16 +6,19 @@ import (
 
 // Models
 type User struct {
-	UserID       int       `json:"user_id"`
-	FirstName    string    `json:"first_name"`
-	LastName     string    `json:"last_name"`
-	UserName     string    `json:"username"`
-	PasswordHash string    `json:"-"`
-	Email        string    `json:"email"`
-	PhoneNumber  string    `json:"phone_number"`
-	StudentID    string    `json:"student_id"`
-	Role         string    `json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
+	UserID          int        `json:"user_id"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	UserName        string     `json:"username"`
+	PasswordHash    string     `json:"-"`
+	Email           string     `json:"email"`
+	PhoneNumber     string     `json:"phone_number"`
+	StudentID       string     `json:"student_id"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	ProfilePicture  string     `json:"profile_picture"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       *time.Time `json:"updated_at"`
 }
 
 type Court struct {
@@ -27,21 +30,13 @@ type Court struct {
 }
 
 type Booking struct {
-	BookingID     int       `json:"booking_id"`
-	CourtID       int       `json:"court_id"`
-	UserID        int       `json:"user_id"`
-	StartTime     time.Time `json:"start_time"`
-	EndTime       time.Time `json:"end_time"`
-	BookingStatus string    `json:"booking_status"`
-	CreatedAt     time.Time `json:"created_at"`
+	BookingID     int         `json:"booking_id"`
+	CourtID       int         `json:"court_id"`
+	UserID        int         `json:"user_id"`
+	StartTime     time.Time   `json:"start_time"`
+	EndTime       time.Time   `json:"end_time"`
+	BookingStatus string      `json:"booking_status"`
+	Price         Money       `json:"price"`
+	Breakdown     []PriceLine `json:"price_breakdown,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
 }
-
-// Global data storage
-var (
-	Users         []User
-	Courts        []Court
-	Bookings      []Booking
-	NextUserID    = 1
-	NextCourtID   = 1
-	NextBookingID = 1
-)
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notification event types
+const (
+	EventBookingConfirmed = "booking_confirmed"
+	EventBookingCancelled = "booking_cancelled"
+	// EventWaitlistAvailable tells a waiting user their slot opened up. Users can already set
+	// preferences for it; nothing emits it until bookings have a waitlist.
+	EventWaitlistAvailable = "waitlist_available"
+	EventCourtClosed       = "court_closed"
+	EventAnnouncement      = "announcement"
+)
+
+var NotificationEvents = []string{
+	EventBookingConfirmed,
+	EventBookingCancelled,
+	EventWaitlistAvailable,
+	EventCourtClosed,
+	EventAnnouncement,
+}
+
+type Notification struct {
+	NotificationID int        `json:"notification_id"`
+	UserID         int        `json:"user_id"`
+	Event          string     `json:"event"`
+	Title          string     `json:"title"`
+	Message        string     `json:"message"`
+	ReadAt         *time.Time `json:"read_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// NotificationPreferences maps event -> channel -> enabled
+type NotificationPreferences map[string]map[string]bool
+
+type UpdateNotificationPreferencesRequest struct {
+	Preferences NotificationPreferences `json:"preferences"`
+	LineUserID  *string                 `json:"line_user_id"`
+}
+
+type DeletePushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+// GET /api/notifications/preferences
+func (h *Handler) HandleGetNotificationPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	prefs, err := h.GetNotificationPreferencesDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	to, err := h.GetRecipientDB(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"preferences":  prefs,
+		"channels":     RegisteredChannelNames(),
+		"line_user_id": to.LineUserID,
+	}
+	if ch, ok := getNotificationChannel(ChannelWebPush); ok {
+		response["webpush_public_key"] = ch.(*WebPushChannel).PublicKey
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PUT /api/notifications/preferences
+func (h *Handler) HandleUpdateNotificationPreferences(c *gin.Context) {
+	var req UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if err := ValidateNotificationPreferences(req.Preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(int)
+
+	if err := h.SaveNotificationPreferencesDB(userID, req.Preferences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.LineUserID != nil {
+		if err := h.SetLineUserIDDB(userID, *req.LineUserID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	prefs, err := h.GetNotificationPreferencesDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "notification preferences updated",
+		"preferences": prefs,
+	})
+}
+
+// POST /api/notifications/push-subscriptions
+func (h *Handler) HandleAddPushSubscription(c *gin.Context) {
+	var req PushSubscription
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if err := validatePushEndpoint(req.Endpoint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(int)
+
+	if err := h.SavePushSubscriptionDB(userID, req); err == errPushSubscriptionTaken {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "push subscription saved"})
+}
+
+// DELETE /api/notifications/push-subscriptions
+func (h *Handler) HandleDeletePushSubscription(c *gin.Context) {
+	var req DeletePushSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(int)
+
+	if err := h.DeletePushSubscriptionDB(userID, req.Endpoint); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "push subscription removed"})
+}
+
+// Internal functions
+
+// DefaultNotificationPreferences enables the in-app inbox (and the log stub) for every event
+func DefaultNotificationPreferences() NotificationPreferences {
+	prefs := NotificationPreferences{}
+	for _, event := range NotificationEvents {
+		prefs[event] = map[string]bool{
+			ChannelInApp:   true,
+			ChannelEmail:   false,
+			ChannelLine:    false,
+			ChannelWebPush: false,
+			ChannelLog:     true,
+		}
+	}
+	return prefs
+}
+
+func ValidateNotificationPreferences(prefs NotificationPreferences) error {
+	for event, channels := range prefs {
+		if !isNotificationEvent(event) {
+			return fmt.Errorf("unknown notification event: %s", event)
+		}
+		for channel := range channels {
+			if !isNotificationChannel(channel) {
+				return fmt.Errorf("unknown notification channel: %s", channel)
+			}
+		}
+	}
+	return nil
+}
+
+func isNotificationEvent(event string) bool {
+	for _, e := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotificationChannel(channel string) bool {
+	switch channel {
+	case ChannelEmail, ChannelLine, ChannelWebPush, ChannelInApp, ChannelLog:
+		return true
+	}
+	return false
+}
+
+// NotifyUser sends n through every channel the user enabled for n.Event.
+// Delivery errors are logged, never returned, so callers can fire and forget.
+func (h *Handler) NotifyUser(userID int, n Notification) {
+	to, err := h.GetRecipientDB(userID)
+	if err != nil {
+		log.Printf("Error loading notification recipient %d: %v", userID, err)
+		return
+	}
+
+	prefs, err := h.GetNotificationPreferencesDB(userID)
+	if err != nil {
+		log.Printf("Error loading notification preferences for user %d: %v", userID, err)
+		return
+	}
+
+	n.UserID = userID
+	for channel, enabled := range prefs[n.Event] {
+		if !enabled {
+			continue
+		}
+		ch, ok := getNotificationChannel(channel)
+		if !ok {
+			continue
+		}
+		if err := ch.Send(to, n); err != nil {
+			log.Printf("Error sending %s notification to user %d: %v", channel, userID, err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notification channel names
+const (
+	ChannelEmail   = "email"
+	ChannelLine    = "line"
+	ChannelWebPush = "webpush"
+	ChannelInApp   = "in_app"
+	ChannelLog     = "log"
+)
+
+// Recipient holds the contact details a channel needs to reach a user
+type Recipient struct {
+	UserID     int
+	Name       string
+	Email      string
+	LineUserID string
+}
+
+// NotificationChannel delivers a notification through one medium (email, LINE, ...)
+type NotificationChannel interface {
+	Name() string
+	Send(to Recipient, n Notification) error
+}
+
+var (
+	notificationChannels   = map[string]NotificationChannel{}
+	notificationChannelsMu sync.RWMutex
+)
+
+// RegisterNotificationChannel makes a channel available to the dispatcher
+func RegisterNotificationChannel(ch NotificationChannel) {
+	notificationChannelsMu.Lock()
+	defer notificationChannelsMu.Unlock()
+	notificationChannels[ch.Name()] = ch
+}
+
+func getNotificationChannel(name string) (NotificationChannel, bool) {
+	notificationChannelsMu.RLock()
+	defer notificationChannelsMu.RUnlock()
+	ch, ok := notificationChannels[name]
+	return ch, ok
+}
+
+// RegisteredChannelNames returns the channels users can currently opt into
+func RegisteredChannelNames() []string {
+	notificationChannelsMu.RLock()
+	defer notificationChannelsMu.RUnlock()
+
+	var names []string
+	for _, name := range []string{ChannelInApp, ChannelEmail, ChannelLine, ChannelWebPush, ChannelLog} {
+		if _, ok := notificationChannels[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// EmailChannel sends notifications through a Mailer
+type EmailChannel struct {
+	Mailer Mailer
+}
+
+func (e *EmailChannel) Name() string { return ChannelEmail }
+
+func (e *EmailChannel) Send(to Recipient, n Notification) error {
+	if to.Email == "" {
+		return nil
+	}
+	return e.Mailer.SendMail(to.Email, n.Title, n.Message)
+}
+
+// LineChannel pushes notifications through the LINE Messaging API
+type LineChannel struct {
+	AccessToken string
+	Endpoint    string
+	Client      *http.Client
+}
+
+const lineDefaultPushEndpoint = "https://api.line.me/v2/bot/message/push"
+
+func NewLineChannel(accessToken string) *LineChannel {
+	return &LineChannel{
+		AccessToken: accessToken,
+		Endpoint:    lineDefaultPushEndpoint,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (l *LineChannel) Name() string { return ChannelLine }
+
+func (l *LineChannel) Send(to Recipient, n Notification) error {
+	if to.LineUserID == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(gin.H{
+		"to": to.LineUserID,
+		"messages": []gin.H{
+			{"type": "text", "text": n.Title + "\n" + n.Message},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+l.AccessToken)
+
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("line push failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// InAppChannel stores notifications in the user's inbox
+type InAppChannel struct {
+	h *Handler
+}
+
+func NewInAppChannel(h *Handler) *InAppChannel {
+	return &InAppChannel{h: h}
+}
+
+func (i *InAppChannel) Name() string { return ChannelInApp }
+
+func (i *InAppChannel) Send(to Recipient, n Notification) error {
+	_, err := i.h.CreateNotificationDB(to.UserID, n)
+	return err
+}
+
+// LogChannel only logs and remembers what was sent; used in tests and local development
+type LogChannel struct {
+	mu   sync.Mutex
+	Sent []Notification
+}
+
+func (l *LogChannel) Name() string { return ChannelLog }
+
+func (l *LogChannel) Send(to Recipient, n Notification) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n.UserID = to.UserID
+	l.Sent = append(l.Sent, n)
+	log.Printf("🔔 [%s] user %d: %s - %s", n.Event, to.UserID, n.Title, n.Message)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far
+func (l *LogChannel) Messages() []Notification {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Notification(nil), l.Sent...)
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// University single sign-on through OpenID Connect (authorization code flow with PKCE).
+// The IdP's ID token is only used to find or create the local user; the client then gets
+// our own token pair, exactly as after a password login.
+
+// OIDCProvider is the identity provider configuration
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients (PKCE only)
+	RedirectURL  string
+	Scopes       []string
+	// StudentIDClaim names the ID token claim holding the student ID
+	StudentIDClaim string
+}
+
+var (
+	oidcMu         sync.RWMutex
+	oidcProvider   *OIDCProvider
+	oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+	oidcLoginTTL   = 10 * time.Minute // how long a started login may take at the IdP
+
+	// Discovery document and signing keys of the provider, fetched on first use
+	oidcMetadata    *oidcDiscovery
+	oidcKeys        = map[string]oidcKey{}
+	oidcKeysFetched time.Time
+)
+
+var errOIDCNotConfigured = fmt.Errorf("single sign-on is not configured")
+
+// SetOIDCProvider enables SSO login; nil disables it
+func SetOIDCProvider(p *OIDCProvider) {
+	if p != nil {
+		p.Issuer = strings.TrimSuffix(p.Issuer, "/")
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		if p.StudentIDClaim == "" {
+			p.StudentIDClaim = "student_id"
+		}
+	}
+
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	oidcProvider = p
+	oidcMetadata = nil
+	oidcKeys = map[string]oidcKey{}
+	oidcKeysFetched = time.Time{}
+}
+
+// OIDCIdentity is what we take from a verified ID token
+type OIDCIdentity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	StudentID         string
+	GivenName         string
+	FamilyName        string
+	PreferredUsername string
+}
+
+// GET /api/auth/oidc/login
+func (h *Handler) HandleOIDCLogin(c *gin.Context) {
+	provider := currentOIDCProvider()
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errOIDCNotConfigured.Error()})
+		return
+	}
+
+	meta, err := oidcDiscover(provider)
+	if err != nil {
+		log.Printf("Error fetching OIDC discovery document: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+
+	state, _ := newOpaqueToken()
+	nonce, _ := newOpaqueToken()
+	verifier, _ := newOpaqueToken()
+	if err := h.CreateOIDCLoginDB(state, nonce, verifier); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {provider.RedirectURL},
+		"scope":                 {strings.Join(provider.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	c.Redirect(http.StatusFound, meta.AuthorizationEndpoint+"?"+params.Encode())
+}
+
+// GET /api/auth/oidc/callback?code=...&state=...
+func (h *Handler) HandleOIDCCallback(c *gin.Context) {
+	provider := currentOIDCProvider()
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errOIDCNotConfigured.Error()})
+		return
+	}
+
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in was not completed: " + e})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	// The state is single use and carries the nonce and PKCE verifier of this login
+	nonce, verifier, err := h.ConsumeOIDCLoginDB(state)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rawIDToken, err := exchangeOIDCCode(provider, code, verifier)
+	if err != nil {
+		log.Printf("Error exchanging OIDC code: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in failed"})
+		return
+	}
+
+	identity, err := verifyOIDCIDToken(provider, rawIDToken, nonce)
+	if err != nil {
+		log.Printf("Error verifying OIDC ID token: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in failed"})
+		return
+	}
+
+	user, err := h.LoginOIDCUserDB(identity)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.RespondLogin(c, user)
+}
+
+// Internal functions
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcKey struct {
+	Method jwt.SigningMethod
+	Public crypto.PublicKey
+}
+
+func currentOIDCProvider() *OIDCProvider {
+	oidcMu.RLock()
+	defer oidcMu.RUnlock()
+	return oidcProvider
+}
+
+func oidcGetJSON(endpoint string, v interface{}) error {
+	resp, err := oidcHTTPClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// oidcDiscover fetches and caches the provider's discovery document
+func oidcDiscover(provider *OIDCProvider) (*oidcDiscovery, error) {
+	oidcMu.RLock()
+	meta := oidcMetadata
+	oidcMu.RUnlock()
+	if meta != nil {
+		return meta, nil
+	}
+
+	meta = &oidcDiscovery{}
+	if err := oidcGetJSON(provider.Issuer+"/.well-known/openid-configuration", meta); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != provider.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", meta.Issuer, provider.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("incomplete discovery document")
+	}
+
+	oidcMu.Lock()
+	oidcMetadata = meta
+	oidcMu.Unlock()
+	return meta, nil
+}
+
+// exchangeOIDCCode redeems the authorization code and returns the raw ID token
+func exchangeOIDCCode(provider *OIDCProvider, code, verifier string) (string, error) {
+	meta, err := oidcDiscover(provider)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {provider.ClientID},
+	}
+	req, err := http.NewRequest(http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token endpoint returned no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// verifyOIDCIDToken checks the ID token signature, issuer, audience, expiry and nonce
+func verifyOIDCIDToken(provider *OIDCProvider, raw, nonce string) (OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			key, err := oidcSigningKey(provider, kid)
+			if err != nil {
+				return nil, err
+			}
+			if token.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return key.Public, nil
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(provider.ClientID),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return OIDCIdentity{}, err
+	}
+	if _, ok := claims["exp"]; !ok {
+		return OIDCIdentity{}, fmt.Errorf("id token has no expiry")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return OIDCIdentity{}, fmt.Errorf("id token nonce mismatch")
+	}
+
+	str := func(name string) string {
+		switch v := claims[name].(type) {
+		case string:
+			return strings.TrimSpace(v)
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+		return ""
+	}
+
+	identity := OIDCIdentity{
+		Issuer:            provider.Issuer,
+		Subject:           str("sub"),
+		Email:             strings.ToLower(str("email")),
+		StudentID:         str(provider.StudentIDClaim),
+		GivenName:         str("given_name"),
+		FamilyName:        str("family_name"),
+		PreferredUsername: str("preferred_username"),
+	}
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	if identity.Subject == "" {
+		return OIDCIdentity{}, fmt.Errorf("id token has no subject")
+	}
+	return identity, nil
+}
+
+// oidcSigningKey returns the provider key for kid, refetching the JWKS (at most once a
+// minute) when the kid is unknown so key rotation at the IdP is picked up
+func oidcSigningKey(provider *OIDCProvider, kid string) (oidcKey, error) {
+	oidcMu.RLock()
+	key, ok := oidcKeys[kid]
+	stale := time.Since(oidcKeysFetched) > time.Minute
+	oidcMu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return oidcKey{}, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	meta, err := oidcDiscover(provider)
+	if err != nil {
+		return oidcKey{}, err
+	}
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := oidcGetJSON(meta.JWKSURI, &set); err != nil {
+		return oidcKey{}, err
+	}
+
+	keys := map[string]oidcKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		k, err := parseJWK(jwk)
+		if err != nil {
+			log.Printf("Warning: skipping IdP key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = k
+	}
+
+	oidcMu.Lock()
+	oidcKeys = keys
+	oidcKeysFetched = time.Now()
+	oidcMu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		// A single key without kid is used for every token
+		if len(keys) == 1 && kid == "" {
+			for _, k := range keys {
+				return k, nil
+			}
+		}
+		return oidcKey{}, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func parseJWK(jwk JWK) (oidcKey, error) {
+	decode := func(s string) ([]byte, error) { return base64.RawURLEncoding.DecodeString(s) }
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return oidcKey{}, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return oidcKey{}, err
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < 2048 {
+			return oidcKey{}, fmt.Errorf("RSA key too small")
+		}
+		return oidcKey{Method: jwt.SigningMethodRS256, Public: pub}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return oidcKey{}, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return oidcKey{}, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return oidcKey{}, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return oidcKey{Method: jwt.SigningMethodES256, Public: pub}, nil
+	case "OKP":
+		x, err := decode(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return oidcKey{}, fmt.Errorf("invalid Ed25519 key")
+		}
+		return oidcKey{Method: jwt.SigningMethodEdDSA, Public: ed25519.PublicKey(x)}, nil
+	}
+	return oidcKey{}, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginOIDCUserLinksOnlyVerifiedAccounts(t *testing.T) {
+	h := newSQLiteHandler(t)
+
+	verifiedAt := time.Now()
+	owner := User{FirstName: "Alice", UserName: "alice", PasswordHash: "hash", Email: "alice@uni.th", Role: RoleMember, EmailVerifiedAt: &verifiedAt}
+	if err := h.Users.CreateUser(&owner, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Registered with someone else's address and student ID, never verified
+	squatter := User{FirstName: "Mallory", UserName: "mallory", PasswordHash: "hash", Email: "bob@uni.th", StudentID: "6400000002", Role: RoleMember}
+	if err := h.Users.CreateUser(&squatter, nil); err != nil {
+		t.Fatal(err)
+	}
+	typedStudentID := User{FirstName: "Trent", UserName: "trent", PasswordHash: "hash", Email: "trent@uni.th", StudentID: "6400000003", Role: RoleMember}
+	if err := h.Users.CreateUser(&typedStudentID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		id       OIDCIdentity
+		wantUser int // 0 means a new account, -1 an error
+	}{
+		{"verified on both sides", OIDCIdentity{Subject: "a", Email: "alice@uni.th", EmailVerified: true}, owner.UserID},
+		{"not verified by the IdP", OIDCIdentity{Subject: "a2", Email: "alice@uni.th"}, -1},
+		{"local account unverified", OIDCIdentity{Subject: "b", Email: "bob@uni.th", EmailVerified: true}, -1},
+		{"student ID only", OIDCIdentity{Subject: "c", Email: "carol.real@uni.th", EmailVerified: true, StudentID: "6400000003"}, 0},
+	} {
+		tc.id.Issuer = "https://idp.uni.th"
+		u, err := h.LoginOIDCUserDB(tc.id)
+		switch {
+		case tc.wantUser < 0:
+			if err == nil {
+				t.Errorf("%s: logged in as user %d, want an error", tc.name, u.UserID)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.wantUser > 0 && u.UserID != tc.wantUser:
+			t.Errorf("%s: logged in as user %d, want %d", tc.name, u.UserID, tc.wantUser)
+		case tc.wantUser == 0 && (u.UserID == owner.UserID || u.UserID == squatter.UserID || u.UserID == typedStudentID.UserID):
+			t.Errorf("%s: linked to existing user %d, want a new account", tc.name, u.UserID)
+		case tc.wantUser == 0 && u.StudentID != "":
+			t.Errorf("%s: new account took student ID %s of another account", tc.name, u.StudentID)
+		}
+	}
+}
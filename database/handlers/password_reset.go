@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// passwordResetTTL is how long an emailed reset link stays valid
+var passwordResetTTL = time.Hour
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// POST /api/auth/password/forgot
+func (h *Handler) HandleForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	// Work happens in the background so the response (and its timing) is the same
+	// whether or not the account exists
+	go h.SendPasswordReset(req.Email, c.ClientIP())
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if an account with that email exists, a reset link has been sent",
+	})
+}
+
+// POST /api/auth/password/reset
+func (h *Handler) HandleResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if err := h.ResetPasswordDB(req.Token, req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset, please log in again"})
+}
+
+// Internal functions
+
+// SendPasswordReset issues a reset token for the account with email, if any, and mails the link
+func (h *Handler) SendPasswordReset(email, ip string) {
+	user, token, err := h.CreatePasswordResetDB(email, ip)
+	if err != nil {
+		log.Printf("Error creating password reset: %v", err)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", appBaseURL, url.QueryEscape(token))
+	body := fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to reset the password for your court booking account.\n"+
+			"Open this link within %d minutes to choose a new password:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.",
+		user.FirstName, int(passwordResetTTL/time.Minute), link,
+	)
+
+	if err := accountMailer.SendMail(user.Email, "Reset your password", body); err != nil {
+		log.Printf("Error sending password reset email to user %d: %v", user.UserID, err)
+	}
+}
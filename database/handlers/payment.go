@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Booking statuses
+const (
+	StatusPendingPayment = "PendingPayment"
+	StatusConfirmed      = "Confirmed"
+	StatusExpired        = "Expired"
+	StatusCancelled      = "Cancelled"
+)
+
+// Payment statuses
+const (
+	PaymentPending        = "Pending"
+	PaymentPaid           = "Paid"
+	PaymentExpired        = "Expired"
+	PaymentRefundRequired = "RefundRequired"
+	PaymentRefunded       = "Refunded"
+)
+
+var paymentStatuses = []string{PaymentPending, PaymentPaid, PaymentExpired, PaymentRefundRequired, PaymentRefunded}
+
+var (
+	errInvalidSignature      = fmt.Errorf("invalid webhook signature")
+	errPaymentsNotConfigured = fmt.Errorf("payments are not configured")
+	errPaymentNotFound       = fmt.Errorf("payment not found")
+	errRefundNotRequired     = fmt.Errorf("payment is not awaiting a refund")
+)
+
+// Charge is what a provider returns for a new payment request
+type Charge struct {
+	Reference string
+	QRPayload string
+}
+
+// PaymentEvent is a provider notification about one charge
+type PaymentEvent struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	Amount    Money  `json:"amount"`
+}
+
+// PaymentProvider creates charges and authenticates the provider's webhooks
+type PaymentProvider interface {
+	Name() string
+	CreateCharge(reference string, amount Money) (Charge, error)
+	ParseWebhook(r *http.Request, body []byte) (PaymentEvent, error)
+}
+
+type Payment struct {
+	PaymentID int        `json:"payment_id"`
+	BookingID int        `json:"booking_id"`
+	Provider  string     `json:"provider"`
+	Reference string     `json:"reference"`
+	Amount    Money      `json:"amount"`
+	Status    string     `json:"status"`
+	QRPayload string     `json:"qr_payload"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	PaidAt    *time.Time `json:"paid_at"`
+}
+
+// PaymentListing is a payment with its booking, as admins see it
+type PaymentListing struct {
+	Payment
+	UserID        int        `json:"user_id"`
+	UserName      string     `json:"username"`
+	BookingStatus string     `json:"booking_status"`
+	RefundedAt    *time.Time `json:"refunded_at"`
+	RefundedBy    *int       `json:"refunded_by"`
+}
+
+var (
+	paymentProvider PaymentProvider
+	paymentHold     = 15 * time.Minute
+)
+
+// SetPaymentProvider sets the provider used for paid bookings and how long their slot is held
+func SetPaymentProvider(p PaymentProvider, hold time.Duration) {
+	paymentProvider = p
+	if hold > 0 {
+		paymentHold = hold
+	}
+}
+
+// GET /api/bookings/:bookingId/payment
+func (h *Handler) HandleGetBookingPayment(c *gin.Context) {
+	bid, err := ParseBookingID(c.Param("bookingId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid booking id"})
+		return
+	}
+
+	booking, err := h.Bookings.GetBooking(bid)
+	if err != nil || (booking.UserID != c.MustGet("userID").(int) && !HasPermission(c, PermBookingsViewAny)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+
+	payment, err := h.GetPaymentByBookingDB(bid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"booking_status": booking.BookingStatus, "payment": payment})
+}
+
+// POST /api/payments/webhook
+func (h *Handler) HandlePaymentWebhook(c *gin.Context) {
+	if paymentProvider == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errPaymentsNotConfigured.Error()})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read body"})
+		return
+	}
+
+	ev, err := paymentProvider.ParseWebhook(c.Request, body)
+	if err == errInvalidSignature {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only successful payments change anything; acknowledge the rest
+	if ev.Status != "paid" {
+		c.JSON(http.StatusOK, gin.H{"message": "ignored"})
+		return
+	}
+
+	payment, booking, err := h.ConfirmPaymentDB(ev.Reference, ev.Amount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if booking.BookingStatus == StatusConfirmed {
+		go h.NotifyUser(booking.UserID, Notification{
+			Event:   EventBookingConfirmed,
+			Title:   "Booking confirmed",
+			Message: fmt.Sprintf("Payment of %s THB received; booking #%d starting %s is confirmed", payment.Amount, booking.BookingID, booking.StartTime.In(venueLocation()).Format("2006-01-02 15:04")),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "payment processed",
+		"payment_status": payment.Status,
+		"booking_status": booking.BookingStatus,
+	})
+}
+
+// GET /api/admin/payments
+func (h *Handler) HandleGetPayments(c *gin.Context) {
+	page, pageSize, err := ParsePagination(c, 50, 200)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && !isPaymentStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of " + strings.Join(paymentStatuses, ", ")})
+		return
+	}
+
+	payments, err := h.GetPaymentsDB(status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": payments, "page": page, "page_size": pageSize})
+}
+
+// POST /api/admin/payments/:paymentId/refunded
+func (h *Handler) HandleMarkPaymentRefunded(c *gin.Context) {
+	pid, err := strconv.Atoi(c.Param("paymentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment id"})
+		return
+	}
+
+	payment, err := h.MarkPaymentRefundedDB(pid, c.MustGet("userID").(int))
+	switch err {
+	case nil:
+	case errPaymentNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errRefundNotRequired:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "payment marked refunded", "data": payment})
+}
+
+// Internal functions
+
+func isPaymentStatus(status string) bool {
+	for _, s := range paymentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func newPaymentReference() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "BK" + hex.EncodeToString(b)
+}
+
+// StartPaymentExpiryWorker releases slots whose payment hold has run out
+func (h *Handler) StartPaymentExpiryWorker(interval time.Duration) {
+	go func() {
+		for {
+			expired, err := h.ExpirePendingBookingsDB()
+			if err != nil {
+				log.Printf("Error expiring pending bookings: %v", err)
+			} else if expired > 0 {
+				log.Printf("✅ Released %d unpaid booking holds", expired)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
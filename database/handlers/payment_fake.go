@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// FakePaymentProvider is an in-process provider for tests and local development.
+// Webhooks are accepted unsigned; Paid builds the body a real provider would send.
+type FakePaymentProvider struct {
+	mu      sync.Mutex
+	Charges map[string]Money
+}
+
+func NewFakePaymentProvider() *FakePaymentProvider {
+	return &FakePaymentProvider{Charges: make(map[string]Money)}
+}
+
+func (f *FakePaymentProvider) Name() string { return "fake" }
+
+func (f *FakePaymentProvider) CreateCharge(reference string, amount Money) (Charge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Charges[reference] = amount
+
+	payload, err := PromptPayPayload("0000000000000", amount, reference)
+	if err != nil {
+		return Charge{}, err
+	}
+	return Charge{Reference: reference, QRPayload: payload}, nil
+}
+
+func (f *FakePaymentProvider) ParseWebhook(r *http.Request, body []byte) (PaymentEvent, error) {
+	var ev PaymentEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return PaymentEvent{}, fmt.Errorf("invalid webhook payload")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.Charges[ev.Reference]; !ok {
+		return PaymentEvent{}, fmt.Errorf("unknown reference")
+	}
+	return ev, nil
+}
+
+// Paid returns a webhook body reporting the charge as fully paid
+func (f *FakePaymentProvider) Paid(reference string) []byte {
+	f.mu.Lock()
+	amount := f.Charges[reference]
+	f.mu.Unlock()
+
+	body, _ := json.Marshal(PaymentEvent{Reference: reference, Status: "paid", Amount: amount})
+	return body
+}
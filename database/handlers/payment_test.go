@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+// usePaymentProvider sets the fake provider for the rest of the test
+func usePaymentProvider(t *testing.T) {
+	prev, prevHold := paymentProvider, paymentHold
+	SetPaymentProvider(NewFakePaymentProvider(), time.Hour)
+	t.Cleanup(func() { paymentProvider, paymentHold = prev, prevHold })
+}
+
+func TestConfirmPaymentLeavesCancelledBooking(t *testing.T) {
+	h := newSQLiteHandler(t)
+	usePaymentProvider(t)
+
+	alice := mustCreateUser(t, h.Stores, "alice")
+	court := mustCreateCourt(t, h.Stores, "tennis", "T1", 1)
+	start := GetCurrentTime().Add(48 * time.Hour).Truncate(time.Hour)
+	b, p, err := h.Bookings.CreateBooking(NewBooking{
+		UserID: alice.UserID, CourtID: court.CourtID, StartTime: start, EndTime: start.Add(time.Hour),
+		Quote: PriceQuote{Total: 200_00},
+	})
+	if err != nil || p == nil {
+		t.Fatalf("CreateBooking = %+v, %v", p, err)
+	}
+	if _, err := h.Bookings.CancelBooking(b.BookingID, alice.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	p, got, err := h.ConfirmPaymentDB(p.Reference, p.Amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BookingStatus != StatusCancelled || p.Status != PaymentRefundRequired {
+		t.Errorf("late payment: booking %s, payment %s; want %s, %s", got.BookingStatus, p.Status, StatusCancelled, PaymentRefundRequired)
+	}
+	if stored, err := h.Bookings.GetBooking(b.BookingID); err != nil || stored.BookingStatus != StatusCancelled {
+		t.Errorf("stored booking = %+v, %v; want it still cancelled", stored, err)
+	}
+}
+
+func TestStaffCancellationRefunds(t *testing.T) {
+	h := newSQLiteHandler(t)
+	usePaymentProvider(t)
+	alice := mustCreateUser(t, h.Stores, "alice")
+	admin := mustCreateUser(t, h.Stores, "admin")
+	court := mustCreateCourt(t, h.Stores, "tennis", "T1", 1)
+	// Too late for the owner to get a refund, but staff cancellations always refund
+	start := GetCurrentTime().Add(2 * time.Hour).Truncate(time.Hour)
+	if _, err := h.TopUpWalletDB(alice.UserID, 200_00, "", admin.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	byWallet, _, err := h.Bookings.CreateBooking(NewBooking{
+		UserID: alice.UserID, CourtID: court.CourtID, StartTime: start, EndTime: start.Add(time.Hour),
+		Quote: PriceQuote{Total: 200_00}, PaymentMethod: PayByWallet,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byQR, p, err := h.Bookings.CreateBooking(NewBooking{
+		UserID: alice.UserID, CourtID: court.CourtID, StartTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour),
+		Quote: PriceQuote{Total: 150_00},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := h.ConfirmPaymentDB(p.Reference, p.Amount); err != nil {
+		t.Fatal(err)
+	}
+
+	if refund, err := h.Bookings.CancelBooking(byWallet.BookingID, admin.UserID); err != nil || refund != 200_00 {
+		t.Errorf("cancelling wallet booking = %s, %v; want 200.00 refunded", refund, err)
+	}
+	if refund, err := h.Bookings.CancelBooking(byQR.BookingID, admin.UserID); err != nil || refund != 0 {
+		t.Errorf("cancelling PromptPay booking = %s, %v; want no wallet refund", refund, err)
+	}
+	if balance, _ := h.GetWalletBalanceDB(alice.UserID); balance != 200_00 {
+		t.Errorf("wallet balance = %s, want 200.00", balance)
+	}
+	if p, err := h.GetPaymentByBookingDB(byQR.BookingID); err != nil || p.Status != PaymentRefundRequired {
+		t.Errorf("PromptPay payment = %+v, %v; want %s", p, err, PaymentRefundRequired)
+	}
+	if r, err := h.ReconcileLedgerDB(); err != nil || !r.Balanced {
+		t.Errorf("ledger = %+v, %v; want balanced", r, err)
+	}
+}
+
+func TestMarkPaymentRefunded(t *testing.T) {
+	h := newSQLiteHandler(t)
+	usePaymentProvider(t)
+	alice := mustCreateUser(t, h.Stores, "alice")
+	admin := mustCreateUser(t, h.Stores, "admin")
+	court := mustCreateCourt(t, h.Stores, "tennis", "T1", 1)
+	start := GetCurrentTime().Add(48 * time.Hour).Truncate(time.Hour)
+
+	b, p, err := h.Bookings.CreateBooking(NewBooking{
+		UserID: alice.UserID, CourtID: court.CourtID, StartTime: start, EndTime: start.Add(time.Hour),
+		Quote: PriceQuote{Total: 200_00},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := h.ConfirmPaymentDB(p.Reference, p.Amount); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.MarkPaymentRefundedDB(p.PaymentID, admin.UserID); err != errRefundNotRequired {
+		t.Errorf("refunding a paid booking = %v, want %v", err, errRefundNotRequired)
+	}
+	if _, err := h.Bookings.CancelBooking(b.BookingID, alice.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := h.GetPaymentsDB(PaymentRefundRequired, 50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].PaymentID != p.PaymentID || due[0].UserName != "alice" || due[0].BookingStatus != StatusCancelled {
+		t.Fatalf("payments awaiting a refund = %+v, want alice's cancelled booking", due)
+	}
+
+	refunded, err := h.MarkPaymentRefundedDB(p.PaymentID, admin.UserID)
+	if err != nil || refunded.Status != PaymentRefunded {
+		t.Fatalf("MarkPaymentRefundedDB = %+v, %v; want %s", refunded, err, PaymentRefunded)
+	}
+	if _, err := h.MarkPaymentRefundedDB(p.PaymentID, admin.UserID); err != errRefundNotRequired {
+		t.Errorf("refunding twice = %v, want %v", err, errRefundNotRequired)
+	}
+	if _, err := h.MarkPaymentRefundedDB(p.PaymentID+1, admin.UserID); err != errPaymentNotFound {
+		t.Errorf("refunding an unknown payment = %v, want %v", err, errPaymentNotFound)
+	}
+
+	// A provider retry must not reopen the refund
+	if retried, _, err := h.ConfirmPaymentDB(p.Reference, p.Amount); err != nil || retried.Status != PaymentRefunded {
+		t.Errorf("webhook retry = %+v, %v; want it still %s", retried, err, PaymentRefunded)
+	}
+	if due, _ := h.GetPaymentsDB(PaymentRefundRequired, 50, 0); len(due) != 0 {
+		t.Errorf("%d payments still awaiting a refund, want none", len(due))
+	}
+	done, err := h.GetPaymentsDB(PaymentRefunded, 50, 0)
+	if err != nil || len(done) != 1 || done[0].RefundedAt == nil || done[0].RefundedBy == nil || *done[0].RefundedBy != admin.UserID {
+		t.Errorf("refunded payments = %+v, %v; want one refunded by admin", done, err)
+	}
+}
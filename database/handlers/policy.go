@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Versioned terms of service and privacy policy. Admins write drafts and publish them; the
+// latest published version of each kind is current, and users must have accepted every
+// current document before they can use the booking endpoints.
+
+// Policy kinds
+const (
+	PolicyTerms   = "terms"
+	PolicyPrivacy = "privacy"
+)
+
+var errPolicyNotAccepted = fmt.Errorf("please review and accept the current terms and privacy policy")
+
+type PolicyDocument struct {
+	DocumentID  int        `json:"document_id"`
+	Kind        string     `json:"kind" binding:"required,oneof=terms privacy"`
+	Version     string     `json:"version" binding:"required,max=20"`
+	Title       string     `json:"title" binding:"required,max=200"`
+	Body        string     `json:"body" binding:"required"`
+	PublishedAt *time.Time `json:"published_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Acceptances int        `json:"acceptances,omitempty"`
+}
+
+type PolicyAcceptance struct {
+	DocumentID int       `json:"document_id"`
+	Kind       string    `json:"kind"`
+	Version    string    `json:"version"`
+	IPAddress  string    `json:"ip_address"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+// PolicyConsent is what a new user agreed to when signing up
+type PolicyConsent struct {
+	DocumentIDs []int
+	IPAddress   string
+}
+
+type AcceptPoliciesRequest struct {
+	DocumentIDs []int `json:"document_ids" binding:"required,min=1"`
+}
+
+// GET /api/policies
+func (h *Handler) HandleGetCurrentPolicies(c *gin.Context) {
+	docs, err := h.GetCurrentPoliciesDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": docs})
+}
+
+// GET /api/users/me/consents
+func (h *Handler) HandleGetMyConsents(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	pending, err := h.GetPendingPoliciesDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	history, err := h.GetPolicyAcceptancesDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pending": pending, "accepted": history})
+}
+
+// POST /api/users/me/consents
+func (h *Handler) HandleAcceptPolicies(c *gin.Context) {
+	var req AcceptPoliciesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	userID := c.MustGet("userID").(int)
+
+	if err := h.AcceptPoliciesDB(userID, req.DocumentIDs, c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pending, err := h.GetPendingPoliciesDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "policies accepted", "pending": pending})
+}
+
+// GET /api/admin/policies
+func (h *Handler) HandleGetPolicies(c *gin.Context) {
+	docs, err := h.GetPoliciesDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": docs})
+}
+
+// POST /api/admin/policies - creates an unpublished draft
+func (h *Handler) HandleCreatePolicy(c *gin.Context) {
+	var req PolicyDocument
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	doc, err := h.CreatePolicyDB(req, c.MustGet("userID").(int))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "draft created", "data": doc})
+}
+
+// PUT /api/admin/policies/:documentId - drafts only; published versions are immutable
+func (h *Handler) HandleUpdatePolicy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("documentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
+		return
+	}
+
+	var req PolicyDocument
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	req.DocumentID = id
+	if err := h.UpdatePolicyDB(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "draft updated"})
+}
+
+// POST /api/admin/policies/:documentId/publish
+// Every user has to accept the new version before booking again
+func (h *Handler) HandlePublishPolicy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("documentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
+		return
+	}
+
+	if err := h.PublishPolicyDB(id, c.MustGet("userID").(int)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "policy published"})
+}
+
+// RequirePolicyAcceptance ตรวจสอบว่า user ยอมรับเงื่อนไขฉบับปัจจุบันแล้ว (use after AuthMiddleware)
+func (h *Handler) RequirePolicyAcceptance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pending, err := h.GetPendingPoliciesDB(c.MustGet("userID").(int))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if len(pending) > 0 {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":            errPolicyNotAccepted.Error(),
+				"consent_required": true,
+				"pending":          pending,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
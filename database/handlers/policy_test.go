@@ -0,0 +1,36 @@
+package handlers
+
+import "testing"
+
+func TestCreateUserRecordsConsentAtomically(t *testing.T) {
+	h := newSQLiteHandler(t)
+	admin := mustCreateUser(t, h.Stores, "admin")
+
+	doc, err := h.CreatePolicyDB(PolicyDocument{Kind: PolicyTerms, Version: "test", Title: "Terms", Body: "..."}, admin.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PublishPolicyDB(doc.DocumentID, admin.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Consent that cannot be recorded leaves no account behind
+	bob := User{FirstName: "Bob", UserName: "bob", PasswordHash: "hash", Email: "bob@uni.th", Role: RoleMember}
+	if err := h.Users.CreateUser(&bob, &PolicyConsent{DocumentIDs: []int{doc.DocumentID, doc.DocumentID + 100}}); err == nil {
+		t.Fatal("CreateUser with an unpublished document succeeded")
+	}
+	if _, err := h.Users.GetUserByUserName("bob"); err != errUserNotFound {
+		t.Errorf("GetUserByUserName(bob) = %v, want %v", err, errUserNotFound)
+	}
+
+	if err := h.Users.CreateUser(&bob, &PolicyConsent{DocumentIDs: []int{doc.DocumentID}, IPAddress: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	accepted, err := h.GetPolicyAcceptancesDB(bob.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accepted) != 1 || accepted[0].DocumentID != doc.DocumentID || accepted[0].IPAddress != "192.0.2.1" {
+		t.Errorf("acceptances = %+v, want document %d from 192.0.2.1", accepted, doc.DocumentID)
+	}
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pricing tiers (users.PricingTier)
+const (
+	TierMember  = "Member"
+	TierStudent = "Student"
+	TierGuest   = "Guest"
+)
+
+// Band day types
+const (
+	DayTypeAll     = "all"
+	DayTypeWeekday = "weekday"
+	DayTypeWeekend = "weekend"
+)
+
+// Money is an amount in satang (1/100 baht); JSON uses baht with two decimals
+type Money int64
+
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	v, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// ParseMoney parses a baht amount such as "150", "150.5" or "150.50"
+func ParseMoney(s string) (Money, error) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if !isDigits(whole) || len(frac) > 2 || (frac != "" && !isDigits(frac)) {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	b, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	st, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+
+	m := Money(b*100 + st)
+	if neg {
+		m = -m
+	}
+	return m, nil
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// PriceRate is the base hourly rate for a sport, or for one court when CourtID is set
+type PriceRate struct {
+	RateID     int    `json:"rate_id"`
+	SportType  string `json:"sport_type" binding:"required"`
+	CourtID    *int   `json:"court_id"`
+	HourlyRate Money  `json:"hourly_rate"`
+}
+
+// PriceBand multiplies the base rate for hours in [StartHour, EndHour) on matching days
+type PriceBand struct {
+	BandID      int    `json:"band_id"`
+	Name        string `json:"name" binding:"required"`
+	DayType     string `json:"day_type" binding:"required,oneof=all weekday weekend"`
+	StartHour   int    `json:"start_hour" binding:"min=0,max=23"`
+	EndHour     int    `json:"end_hour" binding:"min=1,max=24"`
+	RatePercent int    `json:"rate_percent" binding:"required,min=0"`
+}
+
+// PriceLine is one hourly (or partial) segment of a quote
+type PriceLine struct {
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	Band        string    `json:"band"`
+	HourlyRate  Money     `json:"hourly_rate"`
+	BandPercent int       `json:"band_percent"`
+	TierPercent int       `json:"tier_percent"`
+	Amount      Money     `json:"amount"`
+}
+
+type PriceQuote struct {
+	CourtID   int         `json:"court_id"`
+	SportType string      `json:"sport_type"`
+	Tier      string      `json:"tier"`
+	StartTime time.Time   `json:"start_time"`
+	EndTime   time.Time   `json:"end_time"`
+	Lines     []PriceLine `json:"breakdown"`
+	Subtotal  Money       `json:"subtotal"`
+	// Discount and VoucherCode are set when a voucher has been applied
+	Discount    Money  `json:"discount"`
+	VoucherCode string `json:"voucher_code,omitempty"`
+	Total       Money  `json:"total"`
+	Currency    string `json:"currency"`
+}
+
+// PricingConfig is everything the engine needs; loaded from the pricing tables
+type PricingConfig struct {
+	Rates []PriceRate    `json:"rates"`
+	Bands []PriceBand    `json:"bands"`
+	Tiers map[string]int `json:"tiers"`
+}
+
+type SetPricingTiersRequest struct {
+	Tiers map[string]int `json:"tiers" binding:"required"`
+}
+
+type SetUserPricingTierRequest struct {
+	Tier string `json:"tier" binding:"required,oneof=Member Student Guest"`
+}
+
+// GET /api/pricing/quote?court_id=&start_time=&end_time=&voucher_code=
+func (h *Handler) HandleGetPriceQuote(c *gin.Context) {
+	courtID, err := strconv.Atoi(c.Query("court_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid court_id"})
+		return
+	}
+
+	start, end, err := ParseBookingTimes(CreateBookingRequest{StartTime: c.Query("start_time"), EndTime: c.Query("end_time")})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(int)
+
+	quote, err := h.QuoteBookingDB(userID, courtID, start, end)
+	if err == errCourtNotFound || err == errUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if code := c.Query("voucher_code"); code != "" {
+		if _, err := h.ApplyVoucherDB(code, userID, &quote); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quote": quote})
+}
+
+// GET /api/admin/pricing
+func (h *Handler) HandleGetPricingConfig(c *gin.Context) {
+	cfg, err := h.GetPricingConfigDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": cfg})
+}
+
+// PUT /api/admin/pricing/rates
+func (h *Handler) HandleUpsertPriceRate(c *gin.Context) {
+	var req PriceRate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.HourlyRate < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hourly_rate must not be negative"})
+		return
+	}
+
+	rate, err := h.UpsertPriceRateDB(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "rate saved", "rate": rate})
+}
+
+// DELETE /api/admin/pricing/rates/:rateId
+func (h *Handler) HandleDeletePriceRate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("rateId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rate id"})
+		return
+	}
+
+	if err := h.DeletePriceRateDB(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "rate deleted"})
+}
+
+// POST /api/admin/pricing/bands
+func (h *Handler) HandleCreatePriceBand(c *gin.Context) {
+	var req PriceBand
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.EndHour <= req.StartHour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_hour must be after start_hour"})
+		return
+	}
+
+	band, err := h.CreatePriceBandDB(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "band created", "band": band})
+}
+
+// DELETE /api/admin/pricing/bands/:bandId
+func (h *Handler) HandleDeletePriceBand(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("bandId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid band id"})
+		return
+	}
+
+	if err := h.DeletePriceBandDB(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "band deleted"})
+}
+
+// PUT /api/admin/pricing/tiers
+func (h *Handler) HandleSetPricingTiers(c *gin.Context) {
+	var req SetPricingTiersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	for tier, pct := range req.Tiers {
+		if !isPricingTier(tier) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown pricing tier: " + tier})
+			return
+		}
+		if pct < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate percent must not be negative"})
+			return
+		}
+	}
+
+	if err := h.SetPricingTiersDB(req.Tiers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "pricing tiers updated"})
+}
+
+// PUT /api/admin/users/:id/pricing-tier
+func (h *Handler) HandleSetUserPricingTier(c *gin.Context) {
+	uid, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req SetUserPricingTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if err := h.SetUserPricingTierDB(uid, req.Tier); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "pricing tier updated", "user_id": uid, "tier": req.Tier})
+}
+
+// Internal functions
+
+func isPricingTier(tier string) bool {
+	return tier == TierMember || tier == TierStudent || tier == TierGuest
+}
+
+// baseRate prefers a court-specific rate over the sport-wide one
+func (cfg PricingConfig) baseRate(court Court) Money {
+	var sportRate Money
+	for _, r := range cfg.Rates {
+		if r.CourtID != nil && *r.CourtID == court.CourtID {
+			return r.HourlyRate
+		}
+		if r.CourtID == nil && r.SportType == court.SportType {
+			sportRate = r.HourlyRate
+		}
+	}
+	return sportRate
+}
+
+// band finds the band for an hour; weekday/weekend bands win over "all" bands
+func (cfg PricingConfig) band(t time.Time) (string, int) {
+	weekend := t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+	hour := t.Hour()
+
+	name, pct, found := "standard", 100, false
+	for _, b := range cfg.Bands {
+		if hour < b.StartHour || hour >= b.EndHour {
+			continue
+		}
+		if (b.DayType == DayTypeWeekend && weekend) || (b.DayType == DayTypeWeekday && !weekend) {
+			return b.Name, b.RatePercent
+		}
+		if b.DayType == DayTypeAll && !found {
+			name, pct, found = b.Name, b.RatePercent, true
+		}
+	}
+	return name, pct
+}
+
+func (cfg PricingConfig) tierPercent(tier string) int {
+	if pct, ok := cfg.Tiers[tier]; ok {
+		return pct
+	}
+	return 100
+}
+
+// Quote prices [start, end) hour by hour; partial hours are charged pro rata by the minute.
+// Hours are those of the venue's clock, so zones with a half-hour offset split on :00 too.
+// Callers keep the span short (see maxBookingDuration): there is one line per hour.
+func (cfg PricingConfig) Quote(court Court, tier string, start, end time.Time) PriceQuote {
+	quote := PriceQuote{
+		CourtID:   court.CourtID,
+		SportType: court.SportType,
+		Tier:      tier,
+		StartTime: start,
+		EndTime:   end,
+		Lines:     []PriceLine{},
+		Currency:  "THB",
+	}
+
+	rate := cfg.baseRate(court)
+	tierPct := cfg.tierPercent(tier)
+
+	loc := venueLocation()
+	cursor := start.In(loc)
+	finish := end.In(loc)
+	for cursor.Before(finish) {
+		next := time.Date(cursor.Year(), cursor.Month(), cursor.Day(), cursor.Hour()+1, 0, 0, 0, loc)
+		if next.After(finish) {
+			next = finish
+		}
+
+		bandName, bandPct := cfg.band(cursor)
+		minutes := int64(next.Sub(cursor) / time.Minute)
+		// rate * band% * tier% * minutes/60, rounded to the nearest satang
+		num := int64(rate) * int64(bandPct) * int64(tierPct) * minutes
+		den := int64(100 * 100 * 60)
+		amount := Money((num + den/2) / den)
+
+		quote.Lines = append(quote.Lines, PriceLine{
+			StartTime:   cursor,
+			EndTime:     next,
+			Band:        bandName,
+			HourlyRate:  rate,
+			BandPercent: bandPct,
+			TierPercent: tierPct,
+			Amount:      amount,
+		})
+		quote.Subtotal += amount
+		cursor = next
+	}
+
+	quote.Total = quote.Subtotal
+	return quote
+}
+
+// QuoteBookingDB loads the court, the user's tier and the pricing tables, then prices the slot
+func (h *Handler) QuoteBookingDB(userID, courtID int, start, end time.Time) (PriceQuote, error) {
+	court, err := h.Courts.GetCourt(courtID)
+	if err != nil {
+		return PriceQuote{}, err
+	}
+
+	tier, err := h.GetUserPricingTierDB(userID)
+	if err != nil {
+		return PriceQuote{}, err
+	}
+
+	cfg, err := h.GetPricingConfigDB()
+	if err != nil {
+		return PriceQuote{}, err
+	}
+
+	return cfg.Quote(*court, tier, start, end), nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuoteSplitsOnLocalHours(t *testing.T) {
+	// A zone with a half-hour offset: hours must still split on the local :00
+	venue := time.FixedZone("IST", 5*60*60+30*60)
+	SetVenueLocation(venue)
+	t.Cleanup(func() { SetVenueLocation(nil) })
+
+	cfg := PricingConfig{
+		Rates: []PriceRate{{SportType: "tennis", HourlyRate: 200_00}},
+		Bands: []PriceBand{{Name: "evening", DayType: DayTypeAll, StartHour: 18, EndHour: 22, RatePercent: 150}},
+	}
+	court := Court{CourtID: 1, SportType: "tennis"}
+	start := time.Date(2030, 3, 4, 17, 30, 0, 0, venue)
+	end := time.Date(2030, 3, 4, 19, 0, 0, 0, venue)
+
+	quote := cfg.Quote(court, "Member", start, end)
+	want := []struct {
+		start, end int // local hour*100 + minute
+		band       string
+		amount     Money
+	}{
+		{1730, 1800, "standard", 100_00},
+		{1800, 1900, "evening", 300_00},
+	}
+	if len(quote.Lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(quote.Lines), len(want), quote.Lines)
+	}
+	for i, w := range want {
+		l := quote.Lines[i]
+		s, e := l.StartTime.In(venue), l.EndTime.In(venue)
+		if s.Hour()*100+s.Minute() != w.start || e.Hour()*100+e.Minute() != w.end || l.Band != w.band || l.Amount != w.amount {
+			t.Errorf("line %d = %s-%s %s %s, want %04d-%04d %s %s",
+				i, s.Format("15:04"), e.Format("15:04"), l.Band, l.Amount, w.start, w.end, w.band, w.amount)
+		}
+	}
+	if quote.Total != 400_00 {
+		t.Errorf("total = %s, want 400.00", quote.Total)
+	}
+}
+
+func TestQuoteUsesVenueZone(t *testing.T) {
+	// The server runs on UTC but the venue is in Bangkok: 11:00Z is 18:00 at the courts
+	SetVenueLocation(time.FixedZone("ICT", 7*60*60))
+	t.Cleanup(func() { SetVenueLocation(nil) })
+
+	cfg := PricingConfig{
+		Rates: []PriceRate{{SportType: "tennis", HourlyRate: 200_00}},
+		Bands: []PriceBand{{Name: "evening", DayType: DayTypeAll, StartHour: 18, EndHour: 22, RatePercent: 150}},
+	}
+	start := time.Date(2030, 3, 4, 11, 0, 0, 0, time.UTC)
+	quote := cfg.Quote(Court{CourtID: 1, SportType: "tennis"}, "Member", start, start.Add(time.Hour))
+	if len(quote.Lines) != 1 || quote.Lines[0].Band != "evening" || quote.Total != 300_00 {
+		t.Errorf("quote = %+v, want one evening hour at 300.00", quote)
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Money
+		ok   bool
+	}{
+		{"150", 150_00, true},
+		{"150.5", 150_50, true},
+		{"150.50", 150_50, true},
+		{"-0.25", -25, true},
+		{"", 0, false},
+		{".5", 0, false},
+		{"1.-5", 0, false},
+		{"1.+5", 0, false},
+		{"+5", 0, false},
+		{"--5", 0, false},
+		{"1.505", 0, false},
+		{"1,5", 0, false},
+	} {
+		got, err := ParseMoney(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseMoney(%q) = %v, %v; want %v, ok %v", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParseBookingTimesLimitsDuration(t *testing.T) {
+	for _, tc := range []struct {
+		start, end string
+		ok         bool
+	}{
+		{"2030-03-04T10:00:00Z", "2030-03-04T11:00:00Z", true},
+		{"2030-03-04T10:00:00Z", "2030-03-04T22:00:00Z", true},
+		{"2030-03-04T10:00:00Z", "2030-03-04T22:01:00Z", false},
+		{"2030-03-04T10:00:00Z", "2040-03-04T10:00:00Z", false},
+		{"2030-03-04T10:00:00Z", "2030-03-04T10:00:00Z", false},
+	} {
+		_, _, err := ParseBookingTimes(CreateBookingRequest{StartTime: tc.start, EndTime: tc.end})
+		if (err == nil) != tc.ok {
+			t.Errorf("ParseBookingTimes(%s, %s) = %v, want ok %v", tc.start, tc.end, err, tc.ok)
+		}
+	}
+}
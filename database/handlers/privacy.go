@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Personal data rights under the PDPA: a JSON export of everything we hold about a user,
+// and account deletion. Deletion anonymises the user row after a grace period; bookings,
+// payments and ledger entries stay (pointing at the anonymous user) for statistics and
+// accounting.
+
+var accountDeletionGrace = 14 * 24 * time.Hour
+
+var errDeletionBlocked = fmt.Errorf("cancel your upcoming bookings and use up your wallet balance before deleting your account")
+
+// Anonymised accounts are renamed deleted-<UserID>, so nobody else may take a name like that
+const deletedUserNamePrefix = "deleted-"
+
+var errUsernameReserved = fmt.Errorf("usernames starting with %q are reserved", deletedUserNamePrefix)
+
+// SetAccountDeletionGrace sets how long a deletion request can be cancelled
+func SetAccountDeletionGrace(d time.Duration) {
+	if d >= 0 {
+		accountDeletionGrace = d
+	}
+}
+
+type AccountDeletion struct {
+	RequestID    int        `json:"request_id"`
+	UserID       int        `json:"user_id"`
+	UserName     string     `json:"username"`
+	Reason       string     `json:"reason"`
+	RequestedAt  time.Time  `json:"requested_at"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	CancelledAt  *time.Time `json:"cancelled_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	Status       string     `json:"status"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"` // required unless the account only signs in through SSO
+	Reason   string `json:"reason" binding:"max=500"`
+}
+
+// GET /api/users/me/export
+func (h *Handler) HandleExportMyData(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	export, err := h.ExportUserDataDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot build export"})
+		return
+	}
+
+	log.Printf("✅ Personal data exported (User: %d)", userID)
+	filename := fmt.Sprintf("court-booking-data-%d-%s.json", userID, GetCurrentTime().Format("20060102"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// POST /api/users/me/deletion
+func (h *Handler) HandleRequestAccountDeletion(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if c.GetString("role") == "Admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin accounts cannot be deleted by themselves"})
+		return
+	}
+
+	deletion, err := h.RequestAccountDeletionDB(c.MustGet("userID").(int), req.Password, req.Reason)
+	if err == errInvalidCredentials {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "password is incorrect"})
+		return
+	}
+	if err == errDeletionBlocked {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": fmt.Sprintf("your account will be deleted on %s unless you cancel before then", deletion.ScheduledFor.In(venueLocation()).Format("2006-01-02 15:04")),
+		"data":    deletion,
+	})
+}
+
+// GET /api/users/me/deletion
+func (h *Handler) HandleGetAccountDeletion(c *gin.Context) {
+	deletion, err := h.GetPendingDeletionDB(c.MustGet("userID").(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if deletion == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no pending deletion request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deletion})
+}
+
+// DELETE /api/users/me/deletion
+func (h *Handler) HandleCancelAccountDeletion(c *gin.Context) {
+	if err := h.CancelAccountDeletionDB(c.MustGet("userID").(int)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account deletion cancelled"})
+}
+
+// GET /api/admin/account-deletions?status=pending|completed|cancelled
+func (h *Handler) HandleGetAccountDeletions(c *gin.Context) {
+	page, pageSize, err := ParsePagination(c, 50, 200)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != "pending" && status != "completed" && status != "cancelled" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, completed or cancelled"})
+		return
+	}
+
+	deletions, err := h.GetAccountDeletionsDB(status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deletions, "page": page, "page_size": pageSize})
+}
+
+// StartAccountDeletionWorker anonymises accounts whose grace period has passed
+func (h *Handler) StartAccountDeletionWorker(interval time.Duration) {
+	go func() {
+		for {
+			deleted, err := h.ProcessDueDeletionsDB()
+			if err != nil {
+				log.Printf("Error processing account deletions: %v", err)
+			} else if deleted > 0 {
+				log.Printf("✅ Anonymised %d deleted accounts", deleted)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Internal functions
+
+// IsReservedUserName reports whether username could clash with an anonymised account
+func IsReservedUserName(username string) bool {
+	return strings.HasPrefix(strings.ToLower(username), deletedUserNamePrefix)
+}
+
+// SendAccountDeletionNotice confirms a deletion request by email
+func SendAccountDeletionNotice(user User, scheduledFor time.Time) {
+	if user.Email == "" {
+		return
+	}
+	body := fmt.Sprintf(
+		"Hi %s,\n\nWe received a request to delete your court booking account. It will be "+
+			"permanently anonymised on %s.\n\nIf you change your mind, sign in and cancel the "+
+			"request before then at %s/profile.",
+		user.FirstName, scheduledFor.Format("2 Jan 2006 15:04"), appBaseURL,
+	)
+	if err := accountMailer.SendMail(user.Email, "Your account deletion request", body); err != nil {
+		log.Printf("Error sending deletion notice to user %d: %v", user.UserID, err)
+	}
+}
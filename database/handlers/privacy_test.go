@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDeletedUserNamesAreReserved(t *testing.T) {
+	h := newSQLiteHandler(t)
+	grace := accountDeletionGrace
+	SetAccountDeletionGrace(0)
+	t.Cleanup(func() { SetAccountDeletionGrace(grace) })
+
+	alice, err := h.RegisterUser(RegisterRequest{FirstName: "Alice", UserName: "alice", Password: "alice-secret", Email: "alice@uni.th"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Squatting the name alice will get once anonymised
+	squat := fmt.Sprintf("Deleted-%d", alice.UserID)
+	if _, err := h.RegisterUser(RegisterRequest{FirstName: "Mallory", UserName: squat, Password: "mallory-secret", Email: "mallory@uni.th"}, nil); err != errUsernameReserved {
+		t.Errorf("RegisterUser(%s) = %v, want %v", squat, err, errUsernameReserved)
+	}
+	sso, err := h.LoginOIDCUserDB(OIDCIdentity{
+		Issuer:            "https://idp.uni.th",
+		Subject:           "mallory",
+		Email:             "mallory@uni.th",
+		EmailVerified:     true,
+		PreferredUsername: strings.ToLower(squat),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsReservedUserName(sso.UserName) {
+		t.Errorf("SSO account got the reserved username %s", sso.UserName)
+	}
+
+	if _, err := h.RequestAccountDeletionDB(alice.UserID, "alice-secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := h.ProcessDueDeletionsDB(); err != nil || n != 1 {
+		t.Fatalf("ProcessDueDeletionsDB() = %d, %v; want 1", n, err)
+	}
+	got, err := h.Users.GetUser(alice.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("deleted-%d", alice.UserID); got.UserName != want {
+		t.Errorf("anonymised username = %s, want %s", got.UserName, want)
+	}
+}
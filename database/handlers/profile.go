@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Self-service profile: /api/users/me
+
+var (
+	// profileUploadDir holds uploaded pictures, served under profileUploadURL
+	profileUploadDir    = "uploads/profile"
+	profileUploadURL    = "/uploads/profile"
+	maxProfilePicture   = int64(2 << 20) // 2 MB
+	profilePictureTypes = map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/webp": ".webp",
+	}
+)
+
+// SetProfileUploadDir sets where profile pictures are stored and the URL prefix they are served from
+func SetProfileUploadDir(dir, urlPrefix string) {
+	if dir != "" {
+		profileUploadDir = dir
+	}
+	if urlPrefix != "" {
+		profileUploadURL = strings.TrimSuffix(urlPrefix, "/")
+	}
+}
+
+// ProfileUploadDir is the directory to serve at ProfileUploadURL
+func ProfileUploadDir() string { return profileUploadDir }
+
+func ProfileUploadURL() string { return profileUploadURL }
+
+// UpdateProfileRequest changes only the fields that are present
+type UpdateProfileRequest struct {
+	FirstName   *string `json:"first_name" binding:"omitempty,min=1,max=100"`
+	LastName    *string `json:"last_name" binding:"omitempty,max=100"`
+	PhoneNumber *string `json:"phone_number" binding:"omitempty,max=15"`
+	Email       *string `json:"email" binding:"omitempty,email,max=100"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// GET /api/users/me
+func (h *Handler) HandleGetMyProfile(c *gin.Context) {
+	user, err := h.Users.GetUser(c.MustGet("userID").(int))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": FormatMyProfileResponse(user)})
+}
+
+// PUT /api/users/me
+func (h *Handler) HandleUpdateMyProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*req.Email))
+		if !EmailDomainAllowed(email) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only university email addresses are allowed"})
+			return
+		}
+		req.Email = &email
+	}
+
+	user, emailChanged, err := h.UpdateProfileDB(c.MustGet("userID").(int), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"message":       "profile updated",
+		"email_changed": emailChanged,
+		"data":          FormatMyProfileResponse(user),
+	}
+
+	// A new address has to be verified again before the user can book. Changing it back
+	// and forth does not get around the resend limit.
+	if emailChanged {
+		retryAfter, err := h.ReserveVerificationSendDB(user.UserID)
+		switch {
+		case err != nil:
+			log.Printf("Error recording verification email: %v", err)
+			response["verification_sent"] = false
+		case retryAfter > 0:
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.5)))
+			response["verification_sent"] = false
+			response["verification_retry_after"] = int(retryAfter.Seconds() + 0.5)
+		default:
+			go SendEmailVerification(*user)
+			response["verification_sent"] = true
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PUT /api/users/me/password
+func (h *Handler) HandleChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	userID := c.MustGet("userID").(int)
+
+	if err := h.ChangePasswordDB(userID, req.CurrentPassword, req.NewPassword); err == errInvalidCredentials {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every other session is logged out; this client continues in a new one
+	user, err := h.Users.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tokens, err := h.CreateSessionDB(user, c.GetBool("mfa"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	go SendPasswordChangedNotice(*user)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password changed, other sessions have been logged out",
+		"user":    FormatLoginResponse(user, tokens),
+	})
+}
+
+// POST /api/users/me/picture (multipart form, field "picture")
+func (h *Handler) HandleUploadProfilePicture(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxProfilePicture+64<<10)
+	header, err := c.FormFile("picture")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "picture file is required (max 2 MB)"})
+		return
+	}
+	if header.Size > maxProfilePicture {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "picture must be at most 2 MB"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read picture"})
+		return
+	}
+	defer file.Close()
+
+	// Trust the bytes, not the file name or the client's content type
+	data, err := io.ReadAll(io.LimitReader(file, maxProfilePicture+1))
+	if err != nil || int64(len(data)) > maxProfilePicture {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read picture"})
+		return
+	}
+	ext, ok := profilePictureTypes[http.DetectContentType(data)]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "picture must be a JPEG, PNG or WebP image"})
+		return
+	}
+
+	name, _ := newOpaqueToken()
+	name = fmt.Sprintf("%d-%s%s", userID, name[:16], ext)
+	if err := os.MkdirAll(profileUploadDir, 0o755); err != nil {
+		log.Printf("Error creating upload directory: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot store picture"})
+		return
+	}
+	if err := os.WriteFile(filepath.Join(profileUploadDir, name), data, 0o644); err != nil {
+		log.Printf("Error storing profile picture: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot store picture"})
+		return
+	}
+
+	previous, err := h.SetProfilePictureDB(userID, profileUploadURL+"/"+name)
+	if err != nil {
+		os.Remove(filepath.Join(profileUploadDir, name))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	removeProfilePicture(previous)
+
+	c.JSON(http.StatusOK, gin.H{"message": "profile picture updated", "profile_picture": profileUploadURL + "/" + name})
+}
+
+// DELETE /api/users/me/picture
+func (h *Handler) HandleDeleteProfilePicture(c *gin.Context) {
+	previous, err := h.SetProfilePictureDB(c.MustGet("userID").(int), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	removeProfilePicture(previous)
+
+	c.JSON(http.StatusOK, gin.H{"message": "profile picture removed"})
+}
+
+// Internal functions
+
+// removeProfilePicture deletes a stored picture by its URL; other URLs are left alone
+func removeProfilePicture(url string) {
+	if !strings.HasPrefix(url, profileUploadURL+"/") {
+		return
+	}
+	name := filepath.Base(url)
+	if err := os.Remove(filepath.Join(profileUploadDir, name)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: cannot remove old profile picture %s: %v", name, err)
+	}
+}
+
+// SendPasswordChangedNotice tells the user their password was changed, in case it wasn't them
+func SendPasswordChangedNotice(user User) {
+	if user.Email == "" {
+		return
+	}
+	body := fmt.Sprintf(
+		"Hi %s,\n\nThe password of your court booking account was just changed and all other "+
+			"sessions were logged out.\n\nIf this wasn't you, reset your password at %s/forgot-password.",
+		user.FirstName, appBaseURL,
+	)
+	if err := accountMailer.SendMail(user.Email, "Your password was changed", body); err != nil {
+		log.Printf("Error sending password change notice to user %d: %v", user.UserID, err)
+	}
+}
+
+func FormatMyProfileResponse(user *User) gin.H {
+	return gin.H{
+		"user_id":         user.UserID,
+		"first_name":      user.FirstName,
+		"last_name":       user.LastName,
+		"username":        user.UserName,
+		"email":           user.Email,
+		"email_verified":  user.EmailVerifiedAt != nil,
+		"phone_number":    user.PhoneNumber,
+		"student_id":      user.StudentID,
+		"role":            user.Role,
+		"profile_picture": user.ProfilePicture,
+		"created_at":      user.CreatedAt,
+		"updated_at":      user.UpdatedAt,
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Published check values of CRC-16/CCITT-FALSE
+func TestCRC16CCITT(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want uint16
+	}{
+		{"123456789", 0x29B1},
+		{"", 0xFFFF},
+		{"A", 0xB915},
+	} {
+		if got := crc16CCITT([]byte(tc.data)); got != tc.want {
+			t.Errorf("crc16CCITT(%q) = %04X, want %04X", tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestPromptPayPayload(t *testing.T) {
+	for _, tc := range []struct {
+		id        string
+		amount    Money
+		reference string
+		want      string // payload without the CRC
+		ok        bool
+	}{
+		{"081-234-5678", 0, "", "00020101021129370016A0000006770101110113006681234567853037645802TH6304", true},
+		{"0812345678", 1000_00, "", "00020101021229370016A00000067701011101130066812345678530376454071000.005802TH6304", true},
+		{"1234567890123", 50_00, "BK0123456789abcdef", "00020101021229370016A000000677010111021312345678901235303764540550.005802TH62220518BK0123456789abcdef6304", true},
+		{"123456789012345", 0, "", "00020101021129390016A000000677010111031512345678901234553037645802TH6304", true},
+		{"12345", 0, "", "", false},
+		{"0812345678", 0, "BK-1", "", false},
+		{"0812345678", 0, strings.Repeat("A", 26), "", false},
+	} {
+		got, err := PromptPayPayload(tc.id, tc.amount, tc.reference)
+		if (err == nil) != tc.ok {
+			t.Errorf("PromptPayPayload(%s, %s, %q) error = %v, want ok %v", tc.id, tc.amount, tc.reference, err, tc.ok)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		want := tc.want + fmt.Sprintf("%04X", crc16CCITT([]byte(tc.want)))
+		if got != want {
+			t.Errorf("PromptPayPayload(%s, %s, %q) =\n%s\nwant\n%s", tc.id, tc.amount, tc.reference, got, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Token-bucket rate limiting. Each route group has a policy; anonymous requests draw from a
+// bucket per client IP and authenticated ones from a bucket per user (so students behind the
+// campus NAT don't share one limit). Bucket state lives in a pluggable RateLimitStore.
+
+// RateLimit is a token bucket: Burst tokens, refilled at PerMinute tokens per minute
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateLimitPolicy is the limit of one route group
+type RateLimitPolicy struct {
+	IP   RateLimit // anonymous requests, per client IP
+	User RateLimit // authenticated requests, per user
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next token is available
+	RetryAfter time.Duration
+}
+
+// RateLimitStore takes one token from the bucket key
+type RateLimitStore interface {
+	Take(key string, limit RateLimit) (RateLimitResult, error)
+}
+
+var (
+	rateLimitMu     sync.RWMutex
+	rateLimitStore  RateLimitStore = NewMemoryRateLimitStore()
+	rateLimitPolicy                = map[string]RateLimitPolicy{
+		"auth":     {IP: RateLimit{PerMinute: 10, Burst: 10}, User: RateLimit{PerMinute: 10, Burst: 10}},
+		"public":   {IP: RateLimit{PerMinute: 120, Burst: 60}, User: RateLimit{PerMinute: 120, Burst: 60}},
+		"bookings": {IP: RateLimit{PerMinute: 30, Burst: 10}, User: RateLimit{PerMinute: 30, Burst: 10}},
+		"user":     {IP: RateLimit{PerMinute: 60, Burst: 30}, User: RateLimit{PerMinute: 120, Burst: 60}},
+		"admin":    {IP: RateLimit{PerMinute: 60, Burst: 30}, User: RateLimit{PerMinute: 300, Burst: 100}},
+	}
+)
+
+// SetRateLimitStore sets where bucket state is kept
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimitStore = store
+}
+
+// SetRateLimitPolicy sets the limits of a route group
+func SetRateLimitPolicy(group string, policy RateLimitPolicy) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimitPolicy[group] = policy
+}
+
+// RateLimitMiddleware จำกัดจำนวน request ของ route group (put after AuthMiddleware to limit per user)
+func RateLimitMiddleware(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rateLimitMu.RLock()
+		policy, ok := rateLimitPolicy[group]
+		store := rateLimitStore
+		rateLimitMu.RUnlock()
+		if !ok || store == nil {
+			c.Next()
+			return
+		}
+
+		limit := policy.IP
+		key := group + ":ip:" + c.ClientIP()
+		if userID, exists := c.Get("userID"); exists {
+			limit = policy.User
+			key = fmt.Sprintf("%s:user:%d", group, userID)
+		}
+		if limit.PerMinute <= 0 || limit.Burst <= 0 {
+			c.Next()
+			return
+		}
+
+		result, err := store.Take(key, limit)
+		if err != nil {
+			// Fail open: a broken limiter must not take the API down
+			log.Printf("Error checking rate limit: %v", err)
+			c.Next()
+			return
+		}
+
+		// IETF RateLimit header fields
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=60;burst=%d", limit.PerMinute, limit.Burst))
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded, try again later"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Internal functions
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// refillBucket applies the token-bucket arithmetic shared by the stores
+func refillBucket(tokens float64, elapsed time.Duration, limit RateLimit) (float64, RateLimitResult) {
+	rate := float64(limit.PerMinute) / 60 // tokens per second
+	tokens = math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*rate)
+
+	result := RateLimitResult{Allowed: tokens >= 1}
+	if result.Allowed {
+		tokens--
+	}
+	result.Remaining = int(tokens)
+	if tokens < 1 {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return tokens, result
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process memory (single instance deployments)
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	calls   int
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*memoryBucket{}}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	var result RateLimitResult
+	b.tokens, result = refillBucket(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+
+	// Drop idle buckets now and then; an idle bucket is full again anyway
+	s.calls++
+	if s.calls%10000 == 0 {
+		for k, v := range s.buckets {
+			if now.Sub(v.updated) > time.Hour {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role-based access control. Every role maps to a set of permissions which are resolved when
+// a token is issued and carried in its claims, so a change to a role's permissions reaches
+// its users with their next token refresh. Admin always holds every permission.
+
+// Roles
+const (
+	RoleMember      = "Member"
+	RoleStaff       = "Staff"
+	RoleCoach       = "Coach"
+	RoleClubManager = "ClubManager"
+	RoleAdmin       = "Admin"
+)
+
+var Roles = []string{RoleMember, RoleStaff, RoleCoach, RoleClubManager, RoleAdmin}
+
+// Permissions
+const (
+	PermBookingsViewAny     = "bookings:view_any"
+	PermBookingsCancelAny   = "bookings:cancel_any"
+	PermBookingsReset       = "bookings:reset"
+	PermCourtsManage        = "courts:manage"
+	PermAnnouncementsManage = "announcements:manage"
+	PermPricingManage       = "pricing:manage"
+	PermVouchersManage      = "vouchers:manage"
+	PermWalletsTopUp        = "wallets:topup"
+	PermPaymentsRefund      = "payments:refund"
+	PermReportsView         = "reports:view"
+	PermUsersView           = "users:view"
+	PermUsersManage         = "users:manage"
+	PermPoliciesManage      = "policies:manage"
+)
+
+// Permissions lists every permission with what it allows
+var Permissions = []struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}{
+	{PermBookingsViewAny, "view bookings and payments of any user"},
+	{PermBookingsCancelAny, "cancel bookings of any user"},
+	{PermBookingsReset, "reset all bookings"},
+	{PermCourtsManage, "open and close courts"},
+	{PermAnnouncementsManage, "send announcements"},
+	{PermPricingManage, "manage prices, bands and pricing tiers"},
+	{PermVouchersManage, "manage vouchers"},
+	{PermWalletsTopUp, "top up wallets"},
+	{PermPaymentsRefund, "list payments and mark refunds as paid back"},
+	{PermReportsView, "view financial reports"},
+	{PermUsersView, "view full profiles of other users"},
+	{PermUsersManage, "unlock accounts, reset 2FA and review account deletions"},
+	{PermPoliciesManage, "write and publish terms and privacy policies"},
+}
+
+// defaultRolePermissions is seeded into an empty role_permissions table
+var defaultRolePermissions = map[string][]string{
+	RoleCoach: {PermBookingsViewAny, PermUsersView},
+	RoleStaff: {
+		PermBookingsViewAny, PermBookingsCancelAny, PermCourtsManage, PermAnnouncementsManage,
+		PermWalletsTopUp, PermUsersView,
+	},
+	RoleClubManager: {
+		PermBookingsViewAny, PermBookingsCancelAny, PermCourtsManage, PermAnnouncementsManage,
+		PermWalletsTopUp, PermUsersView, PermPricingManage, PermVouchersManage, PermReportsView,
+		PermPaymentsRefund,
+	},
+}
+
+var errPermissionDenied = fmt.Errorf("you do not have permission to do this")
+
+// Role permissions are cached briefly; changes made on another instance show up within rolePermissionTTL
+var rolePermissionTTL = time.Minute
+
+type rolePermissionCache struct {
+	mu     sync.Mutex
+	roles  map[string][]string
+	loaded time.Time
+}
+
+type RolePermissions struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+	Editable    bool     `json:"editable"`
+}
+
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=Member Staff Coach ClubManager Admin"`
+}
+
+// GET /api/admin/roles
+func (h *Handler) HandleGetRoles(c *gin.Context) {
+	roles := make([]RolePermissions, 0, len(Roles))
+	for _, role := range Roles {
+		perms, err := h.PermissionsForRole(role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		roles = append(roles, RolePermissions{Role: role, Permissions: perms, Editable: role != RoleAdmin})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": roles, "permissions": Permissions})
+}
+
+// PUT /api/admin/roles/:role/permissions - replaces the permissions of a role
+func (h *Handler) HandleSetRolePermissions(c *gin.Context) {
+	role := c.Param("role")
+	if !IsRole(role) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown role"})
+		return
+	}
+	if role == RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admin always has every permission"})
+		return
+	}
+
+	var req SetRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	for _, perm := range req.Permissions {
+		if !IsPermission(perm) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown permission %q", perm)})
+			return
+		}
+	}
+
+	if err := h.SetRolePermissionsDB(role, req.Permissions, c.MustGet("userID").(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.invalidateRolePermissions()
+
+	perms, _ := h.PermissionsForRole(role)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "permissions updated, users get them with their next token refresh",
+		"data":    RolePermissions{Role: role, Permissions: perms, Editable: true},
+	})
+}
+
+// PUT /api/admin/users/:id/role
+// The user's sessions are revoked so the new role applies at their next sign-in
+func (h *Handler) HandleSetUserRole(c *gin.Context) {
+	uid, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if uid == c.MustGet("userID").(int) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot change your own role"})
+		return
+	}
+
+	if err := h.SetUserRoleDB(uid, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("user %d is now %s", uid, req.Role)})
+}
+
+// RequirePermission ตรวจสอบว่า token มีสิทธิ์ที่ route ต้องการ (use after AuthMiddleware)
+// Admin tokens must also come from a session that passed two-factor authentication
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mfaRequired(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": errMFARequired.Error(), "mfa_required": true})
+			c.Abort()
+			return
+		}
+		if !HasPermission(c, perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": errPermissionDenied.Error(), "permission": perm})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Internal functions
+
+// HasPermission reports whether the authenticated token carries perm.
+// An admin session without two-factor authentication has no permissions at all.
+func HasPermission(c *gin.Context, perm string) bool {
+	if mfaRequired(c) {
+		return false
+	}
+	perms, _ := c.Get("permissions")
+	list, _ := perms.([]string)
+	for _, p := range list {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// mfaRequired reports whether the token is an admin's that skipped two-factor authentication
+func mfaRequired(c *gin.Context) bool {
+	return c.GetString("role") == RoleAdmin && !c.GetBool("mfa")
+}
+
+func IsRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func IsPermission(perm string) bool {
+	for _, p := range Permissions {
+		if p.Name == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionsForRole resolves the permissions of role, from the cache when it is fresh
+func (h *Handler) PermissionsForRole(role string) ([]string, error) {
+	if role == RoleAdmin {
+		all := make([]string, len(Permissions))
+		for i, p := range Permissions {
+			all[i] = p.Name
+		}
+		return all, nil
+	}
+
+	h.rolePermissions.mu.Lock()
+	defer h.rolePermissions.mu.Unlock()
+
+	if h.rolePermissions.roles == nil || time.Since(h.rolePermissions.loaded) > rolePermissionTTL {
+		mapping, err := h.GetRolePermissionsDB()
+		if err != nil {
+			return nil, err
+		}
+		h.rolePermissions.roles = mapping
+		h.rolePermissions.loaded = time.Now()
+	}
+
+	perms := h.rolePermissions.roles[role]
+	if perms == nil {
+		perms = []string{}
+	}
+	return perms, nil
+}
+
+func (h *Handler) invalidateRolePermissions() {
+	h.rolePermissions.mu.Lock()
+	h.rolePermissions.roles = nil
+	h.rolePermissions.mu.Unlock()
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// SlotChangeChannel is the Postgres NOTIFY channel fed by the bookings/courts triggers
+const SlotChangeChannel = "slot_changes"
+
+const slotStreamHeartbeat = 25 * time.Second
+
+var errInvalidDate = fmt.Errorf("invalid date format, use YYYY-MM-DD")
+
+// SlotUpdate is pushed to stream subscribers whenever availability may have changed
+type SlotUpdate struct {
+	SportType string         `json:"sport_type"`
+	Date      string         `json:"date"`
+	Slots     map[string]int `json:"slots"`
+}
+
+// slotChange is the payload written by notify_slot_change()/notify_court_change()
+type slotChange struct {
+	Op        string     `json:"op"`
+	CourtID   int        `json:"court_id"`
+	SportType string     `json:"sport_type"`
+	StartTime *time.Time `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+}
+
+type slotSubscription struct {
+	sportType string
+	date      string
+	updates   chan SlotUpdate
+}
+
+// slotHub fans slot changes out to the SSE clients connected to this instance
+type slotHub struct {
+	mu    sync.Mutex
+	subs  map[*slotSubscription]struct{}
+	build func(sportType, date string) (SlotUpdate, error)
+}
+
+// newSlotHub returns a hub that computes availability with build
+func newSlotHub(build func(sportType, date string) (SlotUpdate, error)) *slotHub {
+	return &slotHub{subs: make(map[*slotSubscription]struct{}), build: build}
+}
+
+func (h *slotHub) subscribe(sportType, date string) *slotSubscription {
+	sub := &slotSubscription{sportType: sportType, date: date, updates: make(chan SlotUpdate, 1)}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *slotHub) unsubscribe(sub *slotSubscription) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+// keys returns the distinct sport/date pairs someone is watching
+func (h *slotHub) keys() map[[2]string]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make(map[[2]string]bool)
+	for sub := range h.subs {
+		keys[[2]string{sub.sportType, sub.date}] = true
+	}
+	return keys
+}
+
+func (h *slotHub) publish(update SlotUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if sub.sportType != update.SportType || sub.date != update.Date {
+			continue
+		}
+		// Only the latest snapshot matters; replace anything not yet delivered
+		select {
+		case <-sub.updates:
+		default:
+		}
+		sub.updates <- update
+	}
+}
+
+// refresh recomputes and publishes availability for the watched keys matching fn
+func (h *slotHub) refresh(match func(sportType, date string) bool) {
+	for key := range h.keys() {
+		if !match(key[0], key[1]) {
+			continue
+		}
+		update, err := h.build(key[0], key[1])
+		if err != nil {
+			log.Printf("Error refreshing slots for %s on %s: %v", key[0], key[1], err)
+			continue
+		}
+		h.publish(update)
+	}
+}
+
+func (h *slotHub) handleChange(change slotChange) {
+	h.refresh(func(sportType, date string) bool {
+		if sportType != change.SportType {
+			return false
+		}
+		// Court status changes affect every date
+		if change.StartTime == nil || change.EndTime == nil {
+			return true
+		}
+		day, err := time.ParseInLocation("2006-01-02", date, venueLocation())
+		if err != nil {
+			return false
+		}
+		return change.StartTime.Before(day.AddDate(0, 0, 1)) && change.EndTime.After(day)
+	})
+}
+
+// GET /api/slots/stream
+func (h *Handler) HandleStreamSlots(c *gin.Context) {
+	sportType := c.Query("sportType")
+	dateStr := c.Query("date")
+
+	if sportType == "" || dateStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing sportType or date query parameter"})
+		return
+	}
+
+	initial, err := h.buildSlotUpdate(sportType, dateStr)
+	if err == errSportNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sport type not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub := h.slots.subscribe(sportType, dateStr)
+	defer h.slots.unsubscribe(sub)
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+
+	c.SSEvent("slots", initial)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(slotStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case update := <-sub.updates:
+			c.SSEvent("slots", update)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// Internal functions
+
+func (h *Handler) buildSlotUpdate(sportType, dateStr string) (SlotUpdate, error) {
+	date, err := time.ParseInLocation("2006-01-02", dateStr, venueLocation())
+	if err != nil {
+		return SlotUpdate{}, errInvalidDate
+	}
+
+	slots, err := h.GetSlotAvailability(sportType, date)
+	if err != nil {
+		return SlotUpdate{}, err
+	}
+
+	return SlotUpdate{SportType: sportType, Date: dateStr, Slots: slots}, nil
+}
+
+// PublishSlotChange updates the live streams affected by a slot_changes payload. The Postgres
+// listener calls it for every notification; SQLite deployments register it as the
+// sqlitedb notify handler instead.
+func (h *Handler) PublishSlotChange(channel, payload string) {
+	if channel != SlotChangeChannel {
+		return
+	}
+	var change slotChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		log.Printf("Error decoding slot change: %v", err)
+		return
+	}
+	h.slots.handleChange(change)
+}
+
+// StartSlotListener LISTENs on SlotChangeChannel so every API instance sees
+// bookings made through any other instance. It runs until the process exits.
+func (h *Handler) StartSlotListener(dsn string) error {
+	listener := pq.NewListener(dsn, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Slot listener event %d: %v", ev, err)
+		}
+	})
+	if err := listener.Listen(SlotChangeChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case n := <-listener.Notify:
+				// nil means the connection was re-established and events may have been missed
+				if n == nil {
+					h.slots.refresh(func(string, string) bool { return true })
+					continue
+				}
+				h.PublishSlotChange(n.Channel, n.Extra)
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+
+	log.Println("✅ Listening for slot changes")
+	return nil
+}
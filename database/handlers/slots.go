@@ -14,7 +14,7 @@ type AvailableSlot struct {
 }
 
 // GET /api/slots/available
-func HandleGetAvailableSlots(c *gin.Context) {
+func (h *Handler) HandleGetAvailableSlots(c *gin.Context) {
 	sportType := c.Query("sportType")
 	dateStr := c.Query("date")
 
@@ -24,47 +24,82 @@ func HandleGetAvailableSlots(c *gin.Context) {
 	}
 
 	// Validate date format
-	_, err := time.Parse("2006-01-02", dateStr)
+	date, err := time.ParseInLocation("2006-01-02", dateStr, venueLocation())
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
 		return
 	}
 
-	// Get courts for this sport type
-	courts := GetCourtsList(sportType)
-	if len(courts) == 0 {
+	// Calculate available slots
+	slots, err := h.GetSlotAvailability(sportType, date)
+	if err == errSportNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "sport type not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Calculate available slots
-	slots := make(map[string]int)
-	for hour := 10; hour < 22; hour++ {
-		timeStr := fmt.Sprintf("%02d:00", hour)
-		slots[timeStr] = len(courts) // All available initially
+	c.JSON(http.StatusOK, gin.H{"sport_type": sportType, "date": dateStr, "slots": slots})
+}
+
+// Internal functions
+
+// Courts open from 10:00 and the last one-hour slot starts at 21:00
+const (
+	openingHour = 10
+	closingHour = 22
+)
+
+var errSportNotFound = fmt.Errorf("sport type not found")
+
+// GetSlotAvailability counts the open, unbooked courts of a sport for every hourly slot on date
+func (h *Handler) GetSlotAvailability(sportType string, date time.Time) (map[string]int, error) {
+	courts, err := h.Courts.ListCourts(sportType)
+	if err != nil {
+		return nil, err
+	}
+	if len(courts) == 0 {
+		return nil, errSportNotFound
 	}
 
-	// Get booked courts for this date and sport type
-	rows, err := DB.Query(`
-		SELECT DISTINCT start_time FROM bookings b
-		JOIN courts c ON b.court_id = c.court_id
-		WHERE c.sport_type = $1 AND b.booking_date = $2
-	`, sportType, dateStr)
-
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var startTime string
-			if err := rows.Scan(&startTime); err != nil {
-				continue
-			}
-			if slots[startTime] > 0 {
-				slots[startTime]--
+	open := make(map[int]bool)
+	for _, c := range courts {
+		if c.Status != "Closed" {
+			open[c.CourtID] = true
+		}
+	}
+
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, venueLocation())
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	active, err := h.Bookings.ActiveBookings(sportType, dayStart, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make(map[int]map[int]bool)
+	for _, b := range active {
+		if !open[b.CourtID] {
+			continue
+		}
+		for hour := openingHour; hour < closingHour; hour++ {
+			slotStart := dayStart.Add(time.Duration(hour) * time.Hour)
+			slotEnd := slotStart.Add(time.Hour)
+			if b.StartTime.Before(slotEnd) && b.EndTime.After(slotStart) {
+				if busy[hour] == nil {
+					busy[hour] = make(map[int]bool)
+				}
+				busy[hour][b.CourtID] = true
 			}
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"sport_type": sportType, "date": dateStr, "slots": slots})
-}
+	slots := make(map[string]int)
+	for hour := openingHour; hour < closingHour; hour++ {
+		slots[fmt.Sprintf("%02d:00", hour)] = len(open) - len(busy[hour])
+	}
 
-// Internal functions (kept for compatibility, but not used anymore)
+	return slots, nil
+}
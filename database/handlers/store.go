@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Storage of users, courts and bookings. Handlers only see the interfaces below; main builds a
+// Handler on an implementation with NewHandler (Postgres in production, memory for tests).
+// Every implementation has to pass the contract suite in store_test.go.
+
+var (
+	errUserNotFound    = fmt.Errorf("user not found")
+	errUsernameTaken   = fmt.Errorf("username already taken")
+	errEmailTaken      = fmt.Errorf("email already registered")
+	errStudentIDTaken  = fmt.Errorf("student ID already registered")
+	errCourtNotFound   = fmt.Errorf("court not found")
+	errBookingNotFound = fmt.Errorf("booking not found")
+	errCourtBooked     = fmt.Errorf("court already booked for this time")
+)
+
+type UserStore interface {
+	// CreateUser inserts u and fills in UserID and CreatedAt. The username, email and student
+	// ID must not be in use yet. The policy documents in consent, if any, are recorded as
+	// accepted in the same transaction.
+	CreateUser(u *User, consent *PolicyConsent) error
+	GetUser(userID int) (*User, error)
+	// GetUserByUserName also loads the password hash
+	GetUserByUserName(username string) (*User, error)
+}
+
+type CourtStore interface {
+	SportTypes() ([]string, error)
+	// ListCourts returns every court, or only those of sportType when it is set
+	ListCourts(sportType string) ([]Court, error)
+	GetCourt(courtID int) (*Court, error)
+	// CreateCourt inserts c and fills in CourtID
+	CreateCourt(c *Court) error
+	SetCourtStatus(courtID int, status string) error
+}
+
+type BookingStore interface {
+	// CreateBooking checks for overlapping active bookings and inserts the booking atomically.
+	// Paid bookings start as PendingPayment and come with their payment.
+	CreateBooking(nb NewBooking) (Booking, *Payment, error)
+	GetBooking(bookingID int) (*Booking, error)
+	// UserBookings returns every booking of a user, latest start first
+	UserBookings(userID int) ([]Booking, error)
+	// UpcomingBookings returns active bookings that have not started yet; courtID 0 means
+	// every court
+	UpcomingBookings(courtID int) ([]Booking, error)
+	// ActiveBookings returns the active bookings on courts of sportType overlapping [from, to)
+	ActiveBookings(sportType string, from, to time.Time) ([]Booking, error)
+	// CancelBooking cancels an active booking and returns the amount refunded to the wallet;
+	// actorID is whoever cancels it, the owner or staff
+	CancelBooking(bookingID, actorID int) (Money, error)
+}
+
+// Stores is what handlers need from storage
+type Stores struct {
+	Users    UserStore
+	Courts   CourtStore
+	Bookings BookingStore
+	// DB backs the features that do not have a store of their own yet (sessions, payments,
+	// wallets, vouchers, notifications, ...); nil when they are not needed
+	DB *sql.DB
+}
+
+// Handler serves the API, its middleware and background workers from the stores it was built
+// with
+type Handler struct {
+	Stores
+	slots           *slotHub
+	rolePermissions rolePermissionCache
+}
+
+// NewHandler returns a Handler on s
+func NewHandler(s Stores) *Handler {
+	h := &Handler{Stores: s}
+	h.slots = newSlotHub(h.buildSlotUpdate)
+	return h
+}
+
+// Internal functions
+
+// isUniqueViolation reports whether err is a unique constraint error from Postgres or SQLite
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"main.go/migrations"
+	"main.go/sqlitedb"
+)
+
+// Contract suite every UserStore, CourtStore and BookingStore implementation has to pass.
+// The Postgres stores are tested when TEST_DATABASE_URL points at a scratch database; its
+// users, courts and bookings are wiped. SQLite runs against a fresh file per subtest.
+
+func TestMemoryStores(t *testing.T) {
+	testStores(t, func(t *testing.T) Stores { return NewMemoryStores() })
+}
+
+func TestPostgresStores(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	testStores(t, func(t *testing.T) Stores {
+		if _, err := db.Exec("TRUNCATE users, courts, bookings RESTART IDENTITY CASCADE"); err != nil {
+			t.Fatal(err)
+		}
+		return NewPostgresStores(db)
+	})
+}
+
+func TestSQLiteStores(t *testing.T) {
+	testStores(t, newSQLiteStores)
+}
+
+// newSQLiteStores returns stores on a fresh, migrated SQLite file
+func newSQLiteStores(t *testing.T) Stores {
+	t.Helper()
+	db, err := sqlitedb.Open(filepath.Join(t.TempDir(), "courts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLiteStores(db)
+}
+
+// newSQLiteHandler returns a Handler on a fresh SQLite database
+func newSQLiteHandler(t *testing.T) *Handler {
+	t.Helper()
+	return NewHandler(newSQLiteStores(t))
+}
+
+func testStores(t *testing.T, newStores func(t *testing.T) Stores) {
+	t.Run("Users", func(t *testing.T) { testUserStore(t, newStores(t)) })
+	t.Run("Courts", func(t *testing.T) { testCourtStore(t, newStores(t)) })
+	t.Run("Bookings", func(t *testing.T) { testBookingStore(t, newStores(t)) })
+	t.Run("ConcurrentBookings", func(t *testing.T) { testConcurrentBookings(t, newStores(t)) })
+}
+
+func testUserStore(t *testing.T, s Stores) {
+	alice := User{FirstName: "Alice", UserName: "alice", PasswordHash: "hash", Email: "alice@uni.th", StudentID: "6400001", Role: RoleMember}
+	if err := s.Users.CreateUser(&alice, nil); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if alice.UserID == 0 || alice.CreatedAt.IsZero() {
+		t.Fatalf("CreateUser did not fill in UserID and CreatedAt: %+v", alice)
+	}
+
+	for _, tc := range []struct {
+		user User
+		want error
+	}{
+		{User{FirstName: "A", UserName: "alice", PasswordHash: "h", Email: "other@uni.th", Role: RoleMember}, errUsernameTaken},
+		{User{FirstName: "A", UserName: "alice2", PasswordHash: "h", Email: "alice@uni.th", Role: RoleMember}, errEmailTaken},
+		{User{FirstName: "A", UserName: "alice3", PasswordHash: "h", Email: "a3@uni.th", StudentID: "6400001", Role: RoleMember}, errStudentIDTaken},
+	} {
+		if err := s.Users.CreateUser(&tc.user, nil); err != tc.want {
+			t.Errorf("CreateUser(%s) = %v, want %v", tc.user.UserName, err, tc.want)
+		}
+	}
+
+	got, err := s.Users.GetUser(alice.UserID)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got.UserName != "alice" || got.Email != "alice@uni.th" || got.StudentID != "6400001" || got.Role != RoleMember {
+		t.Errorf("GetUser = %+v", got)
+	}
+	if got.PasswordHash != "" {
+		t.Errorf("GetUser returned the password hash")
+	}
+
+	got, err = s.Users.GetUserByUserName("alice")
+	if err != nil {
+		t.Fatalf("GetUserByUserName: %v", err)
+	}
+	if got.UserID != alice.UserID || got.PasswordHash != "hash" {
+		t.Errorf("GetUserByUserName = %+v", got)
+	}
+
+	if _, err := s.Users.GetUser(alice.UserID + 100); err != errUserNotFound {
+		t.Errorf("GetUser(unknown) = %v, want %v", err, errUserNotFound)
+	}
+	if _, err := s.Users.GetUserByUserName("nobody"); err != errUserNotFound {
+		t.Errorf("GetUserByUserName(unknown) = %v, want %v", err, errUserNotFound)
+	}
+}
+
+func testCourtStore(t *testing.T, s Stores) {
+	tennis := mustCreateCourt(t, s, "tennis", "Court B", 2)
+	mustCreateCourt(t, s, "tennis", "Court A", 1)
+	mustCreateCourt(t, s, "badminton", "Hall 1", 1)
+
+	sports, err := s.Courts.SportTypes()
+	if err != nil {
+		t.Fatalf("SportTypes: %v", err)
+	}
+	if len(sports) != 2 || sports[0] != "badminton" || sports[1] != "tennis" {
+		t.Errorf("SportTypes = %v", sports)
+	}
+
+	all, err := s.Courts.ListCourts("")
+	if err != nil {
+		t.Fatalf("ListCourts: %v", err)
+	}
+	if names := courtNames(all); names != "Hall 1,Court A,Court B" {
+		t.Errorf("ListCourts() = %s", names)
+	}
+	filtered, err := s.Courts.ListCourts("tennis")
+	if err != nil {
+		t.Fatalf("ListCourts(tennis): %v", err)
+	}
+	if names := courtNames(filtered); names != "Court A,Court B" {
+		t.Errorf("ListCourts(tennis) = %s", names)
+	}
+	none, err := s.Courts.ListCourts("curling")
+	if err != nil || len(none) != 0 {
+		t.Errorf("ListCourts(curling) = %v, %v", none, err)
+	}
+
+	if err := s.Courts.SetCourtStatus(tennis.CourtID, "Closed"); err != nil {
+		t.Fatalf("SetCourtStatus: %v", err)
+	}
+	got, err := s.Courts.GetCourt(tennis.CourtID)
+	if err != nil {
+		t.Fatalf("GetCourt: %v", err)
+	}
+	if got.CourtName != "Court B" || got.CourtNumber != 2 || got.Status != "Closed" {
+		t.Errorf("GetCourt = %+v", got)
+	}
+
+	if _, err := s.Courts.GetCourt(tennis.CourtID + 100); err != errCourtNotFound {
+		t.Errorf("GetCourt(unknown) = %v, want %v", err, errCourtNotFound)
+	}
+	if err := s.Courts.SetCourtStatus(tennis.CourtID+100, "Closed"); err != errCourtNotFound {
+		t.Errorf("SetCourtStatus(unknown) = %v, want %v", err, errCourtNotFound)
+	}
+}
+
+func testBookingStore(t *testing.T, s Stores) {
+	user := mustCreateUser(t, s, "bob")
+	court := mustCreateCourt(t, s, "tennis", "Court 1", 1)
+	other := mustCreateCourt(t, s, "tennis", "Court 2", 2)
+	mustCreateCourt(t, s, "badminton", "Hall 1", 1)
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	first := mustBook(t, s, user.UserID, court.CourtID, start, start.Add(time.Hour))
+	if first.BookingID == 0 || first.BookingStatus != StatusConfirmed {
+		t.Fatalf("CreateBooking = %+v", first)
+	}
+
+	// Overlapping the same court conflicts; touching it or using another court does not
+	if _, _, err := s.Bookings.CreateBooking(NewBooking{UserID: user.UserID, CourtID: court.CourtID, StartTime: start.Add(30 * time.Minute), EndTime: start.Add(90 * time.Minute)}); err != errCourtBooked {
+		t.Errorf("overlapping CreateBooking = %v, want %v", err, errCourtBooked)
+	}
+	second := mustBook(t, s, user.UserID, court.CourtID, start.Add(time.Hour), start.Add(2*time.Hour))
+	mustBook(t, s, user.UserID, other.CourtID, start, start.Add(time.Hour))
+
+	if _, _, err := s.Bookings.CreateBooking(NewBooking{UserID: user.UserID, CourtID: court.CourtID + 100, StartTime: start, EndTime: start.Add(time.Hour)}); err != errCourtNotFound {
+		t.Errorf("CreateBooking(unknown court) = %v, want %v", err, errCourtNotFound)
+	}
+
+	got, err := s.Bookings.GetBooking(first.BookingID)
+	if err != nil {
+		t.Fatalf("GetBooking: %v", err)
+	}
+	if got.UserID != user.UserID || got.CourtID != court.CourtID || !got.StartTime.Equal(start) || got.BookingStatus != StatusConfirmed {
+		t.Errorf("GetBooking = %+v", got)
+	}
+	if _, err := s.Bookings.GetBooking(first.BookingID + 100); err != errBookingNotFound {
+		t.Errorf("GetBooking(unknown) = %v, want %v", err, errBookingNotFound)
+	}
+
+	history, err := s.Bookings.UserBookings(user.UserID)
+	if err != nil {
+		t.Fatalf("UserBookings: %v", err)
+	}
+	if len(history) != 3 || history[0].BookingID != second.BookingID {
+		t.Errorf("UserBookings = %+v, want 3 bookings latest first", history)
+	}
+
+	upcoming, err := s.Bookings.UpcomingBookings(court.CourtID)
+	if err != nil {
+		t.Fatalf("UpcomingBookings: %v", err)
+	}
+	if len(upcoming) != 2 || upcoming[0].BookingID != first.BookingID {
+		t.Errorf("UpcomingBookings(court) = %+v", upcoming)
+	}
+	if all, _ := s.Bookings.UpcomingBookings(0); len(all) != 3 {
+		t.Errorf("UpcomingBookings(0) returned %d bookings, want 3", len(all))
+	}
+
+	active, err := s.Bookings.ActiveBookings("tennis", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ActiveBookings: %v", err)
+	}
+	if len(active) != 2 {
+		t.Errorf("ActiveBookings(tennis) = %+v, want the two bookings starting at %s", active, start)
+	}
+	if active, _ := s.Bookings.ActiveBookings("badminton", start, start.Add(time.Hour)); len(active) != 0 {
+		t.Errorf("ActiveBookings(badminton) = %+v", active)
+	}
+
+	// Cancelling frees the slot and cannot be repeated
+	if _, err := s.Bookings.CancelBooking(first.BookingID, user.UserID); err != nil {
+		t.Fatalf("CancelBooking: %v", err)
+	}
+	if _, err := s.Bookings.CancelBooking(first.BookingID, user.UserID); err == nil {
+		t.Errorf("second CancelBooking succeeded")
+	}
+	if _, err := s.Bookings.CancelBooking(first.BookingID+100, user.UserID); err != errBookingNotFound {
+		t.Errorf("CancelBooking(unknown) = %v, want %v", err, errBookingNotFound)
+	}
+	if got, _ := s.Bookings.GetBooking(first.BookingID); got == nil || got.BookingStatus != StatusCancelled {
+		t.Errorf("cancelled booking = %+v", got)
+	}
+	mustBook(t, s, user.UserID, court.CourtID, start, start.Add(time.Hour))
+}
+
+// testConcurrentBookings races overlapping bookings for one court; exactly one may win
+func testConcurrentBookings(t *testing.T, s Stores) {
+	user := mustCreateUser(t, s, "carol")
+	court := mustCreateCourt(t, s, "tennis", "Court 1", 1)
+	start := time.Now().Add(72 * time.Hour).Truncate(time.Hour)
+
+	const racers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			offset := time.Duration(i) * time.Minute
+			_, _, err := s.Bookings.CreateBooking(NewBooking{UserID: user.UserID, CourtID: court.CourtID, StartTime: start.Add(offset), EndTime: start.Add(time.Hour + offset)})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	won := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			won++
+		case errCourtBooked:
+		default:
+			t.Errorf("CreateBooking: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d overlapping bookings succeeded, want 1", won)
+	}
+}
+
+func mustCreateUser(t *testing.T, s Stores, username string) User {
+	t.Helper()
+	u := User{FirstName: username, UserName: username, PasswordHash: "hash", Email: username + "@uni.th", Role: RoleMember}
+	if err := s.Users.CreateUser(&u, nil); err != nil {
+		t.Fatalf("CreateUser(%s): %v", username, err)
+	}
+	return u
+}
+
+func mustCreateCourt(t *testing.T, s Stores, sportType, name string, number int) Court {
+	t.Helper()
+	c := Court{CourtName: name, SportType: sportType, CourtNumber: number, Status: "Available"}
+	if err := s.Courts.CreateCourt(&c); err != nil {
+		t.Fatalf("CreateCourt(%s): %v", name, err)
+	}
+	return c
+}
+
+func mustBook(t *testing.T, s Stores, userID, courtID int, start, end time.Time) Booking {
+	t.Helper()
+	b, _, err := s.Bookings.CreateBooking(NewBooking{UserID: userID, CourtID: courtID, StartTime: start, EndTime: end})
+	if err != nil {
+		t.Fatalf("CreateBooking(court %d, %s): %v", courtID, start, err)
+	}
+	return b
+}
+
+func courtNames(courts []Court) string {
+	names := ""
+	for i, c := range courts {
+		if i > 0 {
+			names += ","
+		}
+		names += c.CourtName
+	}
+	return names
+}
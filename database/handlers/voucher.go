@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Voucher discount types
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+// Voucher is a promo code. Nil restriction fields mean "no restriction".
+type Voucher struct {
+	VoucherID    int       `json:"voucher_id"`
+	Code         string    `json:"code" binding:"required,max=32"`
+	Description  string    `json:"description"`
+	DiscountType string    `json:"discount_type" binding:"required,oneof=percent fixed"`
+	PercentOff   int       `json:"percent_off" binding:"min=0,max=100"`
+	AmountOff    Money     `json:"amount_off"`
+	MaxDiscount  *Money    `json:"max_discount"`
+	ValidFrom    time.Time `json:"valid_from" binding:"required"`
+	ValidUntil   time.Time `json:"valid_until" binding:"required"`
+	GlobalLimit  *int      `json:"global_limit"`
+	PerUserLimit *int      `json:"per_user_limit"`
+	SportType    *string   `json:"sport_type"`
+	CourtID      *int      `json:"court_id"`
+	StartHour    *int      `json:"start_hour"`
+	EndHour      *int      `json:"end_hour"`
+	// Active defaults to true when omitted from a create/update request
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type VoucherRedemption struct {
+	RedemptionID int       `json:"redemption_id"`
+	VoucherID    int       `json:"voucher_id"`
+	UserID       int       `json:"user_id"`
+	BookingID    int       `json:"booking_id"`
+	Discount     Money     `json:"discount"`
+	Status       string    `json:"booking_status"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// VoucherReport summarises one voucher's redemptions; cancelled/expired bookings are excluded
+type VoucherReport struct {
+	VoucherID     int    `json:"voucher_id"`
+	Code          string `json:"code"`
+	Redemptions   int    `json:"redemptions"`
+	UniqueUsers   int    `json:"unique_users"`
+	TotalDiscount Money  `json:"total_discount"`
+	GlobalLimit   *int   `json:"global_limit"`
+}
+
+// POST /api/admin/vouchers
+func (h *Handler) HandleCreateVoucher(c *gin.Context) {
+	req := Voucher{Active: true}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if err := ValidateVoucher(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	v, err := h.CreateVoucherDB(req)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "voucher created", "voucher": v})
+}
+
+// GET /api/admin/vouchers
+func (h *Handler) HandleGetVouchers(c *gin.Context) {
+	vouchers, err := h.GetVouchersDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": vouchers})
+}
+
+// GET /api/admin/vouchers/:voucherId
+func (h *Handler) HandleGetVoucher(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("voucherId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid voucher id"})
+		return
+	}
+
+	v, err := h.GetVoucherDB(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"voucher": v})
+}
+
+// PUT /api/admin/vouchers/:voucherId
+func (h *Handler) HandleUpdateVoucher(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("voucherId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid voucher id"})
+		return
+	}
+
+	req := Voucher{Active: true}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if err := ValidateVoucher(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.VoucherID = id
+	if err := h.UpdateVoucherDB(req); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "voucher updated", "voucher": req})
+}
+
+// DELETE /api/admin/vouchers/:voucherId
+func (h *Handler) HandleDeleteVoucher(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("voucherId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid voucher id"})
+		return
+	}
+
+	if err := h.DeleteVoucherDB(id); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "voucher deleted"})
+}
+
+// GET /api/admin/vouchers/:voucherId/redemptions
+func (h *Handler) HandleGetVoucherRedemptions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("voucherId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid voucher id"})
+		return
+	}
+
+	redemptions, err := h.GetVoucherRedemptionsDB(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": redemptions})
+}
+
+// GET /api/admin/vouchers/report
+func (h *Handler) HandleGetVoucherReport(c *gin.Context) {
+	report, err := h.GetVoucherReportDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// Internal functions
+
+func NormalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ValidateVoucher(v *Voucher) error {
+	v.Code = NormalizeVoucherCode(v.Code)
+	if v.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	if v.DiscountType == DiscountPercent && v.PercentOff <= 0 {
+		return fmt.Errorf("percent_off must be between 1 and 100")
+	}
+	if v.DiscountType == DiscountFixed && v.AmountOff <= 0 {
+		return fmt.Errorf("amount_off must be positive")
+	}
+	if !v.ValidUntil.After(v.ValidFrom) {
+		return fmt.Errorf("valid_until must be after valid_from")
+	}
+	if (v.GlobalLimit != nil && *v.GlobalLimit < 1) || (v.PerUserLimit != nil && *v.PerUserLimit < 1) {
+		return fmt.Errorf("usage limits must be at least 1")
+	}
+	if (v.StartHour == nil) != (v.EndHour == nil) {
+		return fmt.Errorf("start_hour and end_hour must be set together")
+	}
+	if v.StartHour != nil && (*v.StartHour < 0 || *v.EndHour > 24 || *v.EndHour <= *v.StartHour) {
+		return fmt.Errorf("invalid start_hour/end_hour")
+	}
+	return nil
+}
+
+// Applies checks the validity window and the sport/court/time-band restrictions
+func (v Voucher) Applies(q PriceQuote, now time.Time) error {
+	if !v.Active || now.Before(v.ValidFrom) || !now.Before(v.ValidUntil) {
+		return errVoucherNotValid
+	}
+	if v.SportType != nil && *v.SportType != q.SportType {
+		return fmt.Errorf("voucher is only valid for %s", *v.SportType)
+	}
+	if v.CourtID != nil && *v.CourtID != q.CourtID {
+		return fmt.Errorf("voucher is not valid for this court")
+	}
+	if v.StartHour != nil {
+		loc := venueLocation()
+		start := q.StartTime.In(loc)
+		end := q.EndTime.In(loc)
+		dayStart := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		bandStart := dayStart.Add(time.Duration(*v.StartHour) * time.Hour)
+		bandEnd := dayStart.Add(time.Duration(*v.EndHour) * time.Hour)
+		if start.Before(bandStart) || end.After(bandEnd) {
+			return fmt.Errorf("voucher is only valid between %02d:00 and %02d:00", *v.StartHour, *v.EndHour)
+		}
+	}
+	return nil
+}
+
+// Discount is how much the voucher takes off subtotal, never more than subtotal
+func (v Voucher) Discount(subtotal Money) Money {
+	var d Money
+	switch v.DiscountType {
+	case DiscountPercent:
+		d = subtotal * Money(v.PercentOff) / 100
+		if v.MaxDiscount != nil && d > *v.MaxDiscount {
+			d = *v.MaxDiscount
+		}
+	case DiscountFixed:
+		d = v.AmountOff
+	}
+	if d > subtotal {
+		d = subtotal
+	}
+	return d
+}
+
+// ApplyVoucherDB validates code for the user and applies it to quote (without redeeming it)
+func (h *Handler) ApplyVoucherDB(code string, userID int, quote *PriceQuote) (*Voucher, error) {
+	v, err := h.GetVoucherByCodeDB(NormalizeVoucherCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Applies(*quote, GetCurrentTime()); err != nil {
+		return nil, err
+	}
+	if err := checkVoucherUsage(h.DB, v, userID); err != nil {
+		return nil, err
+	}
+
+	quote.Discount = v.Discount(quote.Subtotal)
+	quote.Total = quote.Subtotal - quote.Discount
+	quote.VoucherCode = v.Code
+	return v, nil
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVoucherApplies(t *testing.T) {
+	ict := time.FixedZone("ICT", 7*60*60)
+	SetVenueLocation(ict)
+	t.Cleanup(func() { SetVenueLocation(nil) })
+
+	intPtr := func(n int) *int { return &n }
+	strPtr := func(s string) *string { return &s }
+	from := time.Date(2030, 3, 1, 0, 0, 0, 0, ict)
+	until := time.Date(2030, 4, 1, 0, 0, 0, 0, ict)
+	base := Voucher{Code: "SPRING", DiscountType: DiscountPercent, PercentOff: 10, ValidFrom: from, ValidUntil: until, Active: true}
+	// Tennis court 3, 18:00-19:00 at the venue
+	quote := func(start time.Time) PriceQuote {
+		return PriceQuote{CourtID: 3, SportType: "tennis", StartTime: start, EndTime: start.Add(time.Hour)}
+	}
+	evening := time.Date(2030, 3, 10, 18, 0, 0, 0, ict)
+
+	for _, tc := range []struct {
+		name   string
+		change func(v *Voucher)
+		start  time.Time
+		now    time.Time
+		ok     bool
+	}{
+		{"no restrictions", nil, evening, from, true},
+		{"before valid_from", nil, evening, from.Add(-time.Second), false},
+		{"last moment", nil, evening, until.Add(-time.Second), true},
+		{"at valid_until", nil, evening, until, false},
+		{"inactive", func(v *Voucher) { v.Active = false }, evening, from, false},
+		{"same sport", func(v *Voucher) { v.SportType = strPtr("tennis") }, evening, from, true},
+		{"other sport", func(v *Voucher) { v.SportType = strPtr("badminton") }, evening, from, false},
+		{"same court", func(v *Voucher) { v.CourtID = intPtr(3) }, evening, from, true},
+		{"other court", func(v *Voucher) { v.CourtID = intPtr(4) }, evening, from, false},
+		{"inside the band", func(v *Voucher) { v.StartHour, v.EndHour = intPtr(17), intPtr(19) }, evening, from, true},
+		{"starts before the band", func(v *Voucher) { v.StartHour, v.EndHour = intPtr(18), intPtr(22) }, evening.Add(-30 * time.Minute), from, false},
+		{"ends after the band", func(v *Voucher) { v.StartHour, v.EndHour = intPtr(9), intPtr(18) }, evening.Add(-30 * time.Minute), from, false},
+		// 11:00Z is 18:00 at the venue, not 11:00
+		{"band in venue time", func(v *Voucher) { v.StartHour, v.EndHour = intPtr(9), intPtr(12) }, evening.UTC(), from, false},
+	} {
+		v := base
+		if tc.change != nil {
+			tc.change(&v)
+		}
+		if err := v.Applies(quote(tc.start), tc.now); (err == nil) != tc.ok {
+			t.Errorf("%s: Applies = %v, want ok %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestVoucherDiscount(t *testing.T) {
+	maxDiscount := Money(30_00)
+	for _, tc := range []struct {
+		v    Voucher
+		want Money
+	}{
+		{Voucher{DiscountType: DiscountPercent, PercentOff: 10}, 20_00},
+		{Voucher{DiscountType: DiscountPercent, PercentOff: 50, MaxDiscount: &maxDiscount}, 30_00},
+		{Voucher{DiscountType: DiscountFixed, AmountOff: 50_00}, 50_00},
+		{Voucher{DiscountType: DiscountFixed, AmountOff: 500_00}, 200_00},
+	} {
+		if got := tc.v.Discount(200_00); got != tc.want {
+			t.Errorf("%+v: Discount(200.00) = %s, want %s", tc.v, got, tc.want)
+		}
+	}
+}
+
+// voucherFixture is a database with a free-with-voucher tennis court
+type voucherFixture struct {
+	h     *Handler
+	court Court
+	next  time.Time
+}
+
+func newVoucherFixture(t *testing.T, globalLimit, perUserLimit int) (*voucherFixture, *Voucher) {
+	h := newSQLiteHandler(t)
+	now := GetCurrentTime()
+	v, err := h.CreateVoucherDB(Voucher{
+		Code: "FREE", DiscountType: DiscountPercent, PercentOff: 100,
+		ValidFrom: now.Add(-time.Hour), ValidUntil: now.Add(30 * 24 * time.Hour),
+		GlobalLimit: &globalLimit, PerUserLimit: &perUserLimit, Active: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &voucherFixture{
+		h:     h,
+		court: mustCreateCourt(t, h.Stores, "tennis", "T1", 1),
+		next:  now.Add(48 * time.Hour).Truncate(time.Hour),
+	}, v
+}
+
+// quote prices the next free hour of the court at 200.00
+func (f *voucherFixture) quote() PriceQuote {
+	start := f.next
+	f.next = f.next.Add(time.Hour)
+	return PriceQuote{CourtID: f.court.CourtID, SportType: "tennis", StartTime: start, EndTime: start.Add(time.Hour), Subtotal: 200_00, Total: 200_00}
+}
+
+// book creates a booking for quote redeeming v
+func (f *voucherFixture) book(user User, quote PriceQuote, v *Voucher) (Booking, error) {
+	b, _, err := f.h.Bookings.CreateBooking(NewBooking{
+		UserID: user.UserID, CourtID: quote.CourtID, StartTime: quote.StartTime, EndTime: quote.EndTime,
+		Quote: quote, Voucher: v,
+	})
+	return b, err
+}
+
+// apply applies the code and books with it
+func (f *voucherFixture) apply(user User) (Booking, error) {
+	q := f.quote()
+	v, err := f.h.ApplyVoucherDB("free", user.UserID, &q)
+	if err != nil {
+		return Booking{}, err
+	}
+	return f.book(user, q, v)
+}
+
+func TestVoucherUsageLimits(t *testing.T) {
+	f, _ := newVoucherFixture(t, 2, 1)
+	alice := mustCreateUser(t, f.h.Stores, "alice")
+	bob := mustCreateUser(t, f.h.Stores, "bob")
+	carol := mustCreateUser(t, f.h.Stores, "carol")
+
+	first, err := f.apply(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Price != 0 || first.BookingStatus != StatusConfirmed {
+		t.Errorf("booking with 100%% voucher = %s %s, want free and confirmed", first.Price, first.BookingStatus)
+	}
+	if _, err := f.apply(alice); err != errVoucherLimitReached {
+		t.Errorf("second use by alice = %v, want %v", err, errVoucherLimitReached)
+	}
+	if _, err := f.apply(bob); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.apply(carol); err != errVoucherExhausted {
+		t.Errorf("third use overall = %v, want %v", err, errVoucherExhausted)
+	}
+
+	// Cancelling a booking gives its redemption back
+	if _, err := f.h.Bookings.CancelBooking(first.BookingID, alice.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.apply(carol); err != nil {
+		t.Errorf("use after a cancellation = %v, want it to succeed", err)
+	}
+}
+
+func TestRedeemVoucherRechecksUnderLock(t *testing.T) {
+	f, v := newVoucherFixture(t, 1, 1)
+	alice := mustCreateUser(t, f.h.Stores, "alice")
+	bob := mustCreateUser(t, f.h.Stores, "bob")
+
+	// Both quotes pass the check before either booking redeems the code
+	qa, qb := f.quote(), f.quote()
+	va, err := f.h.ApplyVoucherDB("FREE", alice.UserID, &qa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vb, err := f.h.ApplyVoucherDB("FREE", bob.UserID, &qb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.book(alice, qa, va); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.book(bob, qb, vb); err != errVoucherExhausted {
+		t.Errorf("second redemption = %v, want %v", err, errVoucherExhausted)
+	}
+	if bookings, _ := f.h.Bookings.UserBookings(bob.UserID); len(bookings) != 0 {
+		t.Errorf("bob has %d bookings, want the failed one rolled back", len(bookings))
+	}
+
+	// Deactivated between quoting and booking
+	v.Active = false
+	v.GlobalLimit, v.PerUserLimit = nil, nil
+	if err := f.h.UpdateVoucherDB(*v); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.book(bob, qb, vb); err != errVoucherNotValid {
+		t.Errorf("redeeming a deactivated voucher = %v, want %v", err, errVoucherNotValid)
+	}
+}
+
+func TestConcurrentRedemptionsRespectGlobalLimit(t *testing.T) {
+	const limit, users = 3, 10
+	f, _ := newVoucherFixture(t, limit, 1)
+
+	type attempt struct {
+		user  User
+		quote PriceQuote
+		v     *Voucher
+	}
+	attempts := make([]attempt, users)
+	for i := range attempts {
+		u := mustCreateUser(t, f.h.Stores, fmt.Sprintf("user%d", i))
+		q := f.quote()
+		v, err := f.h.ApplyVoucherDB("FREE", u.UserID, &q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attempts[i] = attempt{u, q, v}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed := 0
+	for _, a := range attempts {
+		wg.Add(1)
+		go func(a attempt) {
+			defer wg.Done()
+			_, err := f.book(a.user, a.quote, a.v)
+			switch err {
+			case nil:
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			case errVoucherExhausted:
+			default:
+				t.Errorf("booking for %s: %v", a.user.UserName, err)
+			}
+		}(a)
+	}
+	wg.Wait()
+
+	if redeemed != limit {
+		t.Errorf("%d concurrent redemptions succeeded, want %d", redeemed, limit)
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Booking payment methods
+const (
+	PayByPromptPay = "promptpay"
+	PayByWallet    = "wallet"
+)
+
+// refundCutoff is how long before the start a paid booking can be cancelled with a refund
+var refundCutoff = 24 * time.Hour
+
+// SetRefundCutoff sets the cancellation refund policy
+func SetRefundCutoff(d time.Duration) {
+	refundCutoff = d
+}
+
+// WalletTransaction is one ledger movement as seen from the user's wallet
+type WalletTransaction struct {
+	TxID        int       `json:"transaction_id"`
+	Kind        string    `json:"kind"`
+	Amount      Money     `json:"amount"`
+	BookingID   *int      `json:"booking_id"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type LedgerReconciliation struct {
+	Balanced               bool  `json:"balanced"`
+	UnbalancedTransactions []int `json:"unbalanced_transactions"`
+	MismatchedAccounts     []int `json:"mismatched_accounts"`
+	LedgerTotal            Money `json:"ledger_total"`
+	WalletTotal            Money `json:"wallet_total"`
+}
+
+type TopUpRequest struct {
+	Amount Money  `json:"amount" binding:"required"`
+	Note   string `json:"note"`
+}
+
+// GET /api/wallet
+func (h *Handler) HandleGetWallet(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	balance, err := h.GetWalletBalanceDB(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "balance": balance, "currency": "THB"})
+}
+
+// GET /api/wallet/transactions
+func (h *Handler) HandleGetWalletTransactions(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	page, pageSize, err := ParsePagination(c, 20, 100)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	txs, total, err := h.GetWalletTransactionsDB(userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      txs,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// POST /api/admin/wallets/:userId/topup
+func (h *Handler) HandleTopUpWallet(c *gin.Context) {
+	uid, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req TopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	adminID := c.MustGet("userID").(int)
+
+	balance, err := h.TopUpWalletDB(uid, req.Amount, req.Note, adminID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "wallet topped up", "user_id": uid, "balance": balance})
+}
+
+// GET /api/admin/wallets/reconcile
+func (h *Handler) HandleReconcileLedger(c *gin.Context) {
+	report, err := h.ReconcileLedgerDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostLedgerRejectsUnbalancedTransactions(t *testing.T) {
+	h := newSQLiteHandler(t)
+	alice := mustCreateUser(t, h.Stores, "alice")
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	walletID, _, err := walletAccountTx(tx, alice.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topUpsID, err := systemAccountTx(tx, AccountTopUps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := postLedgerTx(tx, LedgerTopUp, nil, "", alice.UserID, []ledgerEntry{
+		{AccountID: topUpsID, Amount: -100_00},
+		{AccountID: walletID, Amount: 99_99},
+	}); err == nil {
+		t.Error("posting an unbalanced transaction succeeded")
+	}
+	if _, err := postLedgerTx(tx, LedgerTopUp, nil, "", alice.UserID, []ledgerEntry{
+		{AccountID: topUpsID, Amount: -100_00},
+		{AccountID: walletID, Amount: 100_00},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := h.ReconcileLedgerDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Balanced || r.WalletTotal != 100_00 {
+		t.Errorf("ledger = %+v, want balanced with 100.00 in wallets", r)
+	}
+}
+
+func TestConcurrentWalletDebitsDoNotOverspend(t *testing.T) {
+	const price, bookings = Money(100_00), 10
+	h := newSQLiteHandler(t)
+	alice := mustCreateUser(t, h.Stores, "alice")
+	admin := mustCreateUser(t, h.Stores, "admin")
+	court := mustCreateCourt(t, h.Stores, "tennis", "T1", 1)
+	if _, err := h.TopUpWalletDB(alice.UserID, 3*price+50_00, "", admin.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	start := GetCurrentTime().Add(48 * time.Hour).Truncate(time.Hour)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	paid := 0
+	for i := 0; i < bookings; i++ {
+		wg.Add(1)
+		go func(slot time.Time) {
+			defer wg.Done()
+			_, _, err := h.Bookings.CreateBooking(NewBooking{
+				UserID: alice.UserID, CourtID: court.CourtID, StartTime: slot, EndTime: slot.Add(time.Hour),
+				Quote: PriceQuote{Total: price}, PaymentMethod: PayByWallet,
+			})
+			switch err {
+			case nil:
+				mu.Lock()
+				paid++
+				mu.Unlock()
+			case errInsufficientBalance:
+			default:
+				t.Errorf("booking at %s: %v", slot, err)
+			}
+		}(start.Add(time.Duration(i) * time.Hour))
+	}
+	wg.Wait()
+
+	if paid != 3 {
+		t.Errorf("%d wallet bookings succeeded, want 3", paid)
+	}
+	if balance, _ := h.GetWalletBalanceDB(alice.UserID); balance != 50_00 {
+		t.Errorf("wallet balance = %s, want 50.00", balance)
+	}
+	if r, err := h.ReconcileLedgerDB(); err != nil || !r.Balanced {
+		t.Errorf("ledger = %+v, %v; want balanced", r, err)
+	}
+}
+
+func TestCancellationRefundsToWallet(t *testing.T) {
+	h := newSQLiteHandler(t)
+	alice := mustCreateUser(t, h.Stores, "alice")
+	admin := mustCreateUser(t, h.Stores, "admin")
+	court := mustCreateCourt(t, h.Stores, "tennis", "T1", 1)
+	if _, err := h.TopUpWalletDB(alice.UserID, 400_00, "", admin.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	book := func(start time.Time) Booking {
+		b, _, err := h.Bookings.CreateBooking(NewBooking{
+			UserID: alice.UserID, CourtID: court.CourtID, StartTime: start, EndTime: start.Add(time.Hour),
+			Quote: PriceQuote{Total: 200_00}, PaymentMethod: PayByWallet,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	now := GetCurrentTime()
+	early := book(now.Add(refundCutoff + 24*time.Hour).Truncate(time.Hour))
+	late := book(now.Add(refundCutoff / 2).Truncate(time.Hour))
+
+	if refund, err := h.Bookings.CancelBooking(early.BookingID, alice.UserID); err != nil || refund != 200_00 {
+		t.Errorf("cancelling in time = %s, %v; want 200.00 refunded", refund, err)
+	}
+	if refund, err := h.Bookings.CancelBooking(late.BookingID, alice.UserID); err != nil || refund != 0 {
+		t.Errorf("cancelling after the cutoff = %s, %v; want no refund", refund, err)
+	}
+	if _, err := h.Bookings.CancelBooking(early.BookingID, admin.UserID); err == nil {
+		t.Error("cancelling twice succeeded")
+	}
+
+	if balance, _ := h.GetWalletBalanceDB(alice.UserID); balance != 200_00 {
+		t.Errorf("wallet balance = %s, want 200.00", balance)
+	}
+	txs, total, err := h.GetWalletTransactionsDB(alice.UserID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, tx := range txs {
+		kinds = append(kinds, fmt.Sprintf("%s %s", tx.Kind, tx.Amount))
+	}
+	if want := "[refund 200.00 booking -200.00 booking -200.00 topup 400.00]"; total != 4 || fmt.Sprint(kinds) != want {
+		t.Errorf("wallet transactions = %v (%d), want %s", kinds, total, want)
+	}
+	if r, err := h.ReconcileLedgerDB(); err != nil || !r.Balanced || r.WalletTotal != 200_00 {
+		t.Errorf("ledger = %+v, %v; want balanced with 200.00 in wallets", r, err)
+	}
+}
+
+func TestReconcileLedgerFindsDrift(t *testing.T) {
+	h := newSQLiteHandler(t)
+	alice := mustCreateUser(t, h.Stores, "alice")
+	admin := mustCreateUser(t, h.Stores, "admin")
+	if _, err := h.TopUpWalletDB(alice.UserID, 100_00, "", admin.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	var walletID, txID int
+	if err := h.DB.QueryRow("SELECT AccountID FROM ledger_accounts WHERE UserID = $1", alice.UserID).Scan(&walletID); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.QueryRow("SELECT MAX(TxID) FROM ledger_transactions").Scan(&txID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cached balance changed behind the ledger's back
+	if _, err := h.DB.Exec("UPDATE ledger_accounts SET Balance = Balance + 1 WHERE AccountID = $1", walletID); err != nil {
+		t.Fatal(err)
+	}
+	r, err := h.ReconcileLedgerDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Balanced || fmt.Sprint(r.MismatchedAccounts) != fmt.Sprint([]int{walletID}) || len(r.UnbalancedTransactions) != 0 {
+		t.Errorf("after balance drift: %+v; want account %d mismatched", r, walletID)
+	}
+
+	// An entry written without its counterpart
+	if _, err := h.DB.Exec("INSERT INTO ledger_entries (TxID, AccountID, Amount) VALUES ($1, $2, 1)", txID, walletID); err != nil {
+		t.Fatal(err)
+	}
+	r, err = h.ReconcileLedgerDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Balanced || fmt.Sprint(r.UnbalancedTransactions) != fmt.Sprint([]int{txID}) || r.LedgerTotal != 1 {
+		t.Errorf("after a one-sided entry: %+v; want transaction %d unbalanced", r, txID)
+	}
+	// The stray entry and the drifted balance now agree again
+	if len(r.MismatchedAccounts) != 0 {
+		t.Errorf("mismatched accounts = %v, want none", r.MismatchedAccounts)
+	}
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PushSubscription is the browser PushSubscription saved by the frontend
+type PushSubscription struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	P256dh   string `json:"p256dh" binding:"required"`
+	Auth     string `json:"auth" binding:"required"`
+}
+
+// pushServiceHosts are the browser push services a subscription may point at (subdomains
+// included). Endpoints come from clients, so accepting any URL would let them make the
+// server POST into networks it can reach and they cannot.
+var pushServiceHosts = []string{
+	"fcm.googleapis.com",                // Chrome, Android
+	"updates.push.services.mozilla.com", // Firefox
+	"push.apple.com",                    // Safari
+	"notify.windows.com",                // Edge on Windows
+}
+
+var errInvalidPushEndpoint = fmt.Errorf("push endpoint must be an https URL of a known push service")
+
+// WebPushChannel sends encrypted Web Push messages (RFC 8291) signed with VAPID (RFC 8292)
+type WebPushChannel struct {
+	PublicKey  string // base64url uncompressed P-256 point, shared with the frontend
+	Subject    string // mailto: or https: contact for the push service
+	privateKey *ecdsa.PrivateKey
+	Client     *http.Client
+	h          *Handler // where the subscriptions are stored
+}
+
+// NewWebPushChannel builds a channel from base64url encoded VAPID keys
+func NewWebPushChannel(h *Handler, publicKey, privateKey, subject string) (*WebPushChannel, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %v", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %v", err)
+	}
+
+	return &WebPushChannel{
+		PublicKey:  publicKey,
+		Subject:    subject,
+		privateKey: key,
+		Client:     newPushClient(),
+		h:          h,
+	}, nil
+}
+
+func (w *WebPushChannel) Name() string { return ChannelWebPush }
+
+func (w *WebPushChannel) Send(to Recipient, n Notification) error {
+	subs, err := w.h.GetPushSubscriptionsDB(to.UserID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"event": n.Event,
+		"title": n.Title,
+		"body":  n.Message,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		status, err := w.push(sub, payload)
+		if err != nil {
+			log.Printf("Error sending web push to user %d: %v", to.UserID, err)
+			continue
+		}
+		// The browser unsubscribed; forget the endpoint
+		if status == http.StatusNotFound || status == http.StatusGone {
+			w.h.DeletePushSubscriptionDB(to.UserID, sub.Endpoint)
+		}
+	}
+	return nil
+}
+
+func (w *WebPushChannel) push(sub PushSubscription, payload []byte) (int, error) {
+	// Rows saved before endpoints were checked are not trusted either
+	if err := validatePushEndpoint(sub.Endpoint); err != nil {
+		return 0, err
+	}
+	body, err := encryptWebPushPayload(sub, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.Subject,
+	})
+	signed, err := token.SignedString(w.privateKey)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Authorization", "vapid t="+signed+", k="+w.PublicKey)
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusGone {
+		return resp.StatusCode, fmt.Errorf("push service returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// validatePushEndpoint accepts https URLs on the default port of a host in pushServiceHosts
+func validatePushEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return errInvalidPushEndpoint
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range pushServiceHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return nil
+		}
+	}
+	return errInvalidPushEndpoint
+}
+
+// newPushClient returns an HTTP client that only connects to public addresses and does not
+// follow redirects, so neither DNS nor the push service can send requests inwards
+func newPushClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), private in practice
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// encryptWebPushPayload encrypts a single aes128gcm record as described in RFC 8291
+func encryptWebPushPayload(sub PushSubscription, plaintext []byte) ([]byte, error) {
+	uaPublicRaw, err := base64.RawURLEncoding.DecodeString(sub.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh: %v", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(sub.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %v", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return sealWebPushRecord(uaPublicRaw, authSecret, asPrivate, salt, plaintext)
+}
+
+// sealWebPushRecord does the encryption of encryptWebPushPayload with a given application
+// server key and salt
+func sealWebPushRecord(uaPublicRaw, authSecret []byte, asPrivate *ecdh.PrivateKey, salt, plaintext []byte) ([]byte, error) {
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh: %v", err)
+	}
+	asPublicRaw := asPrivate.PublicKey().Bytes()
+
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicRaw...)
+	keyInfo = append(keyInfo, asPublicRaw...)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 marks the last (and only) record
+	record := append(append([]byte{}, plaintext...), 0x02)
+	ciphertext := gcm.Seal(nil, nonce, record, nil)
+
+	header := make([]byte, 0, 16+4+1+len(asPublicRaw))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, 4096)
+	header = append(header, byte(len(asPublicRaw)))
+	header = append(header, asPublicRaw...)
+
+	return append(header, ciphertext...), nil
+}
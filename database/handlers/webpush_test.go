@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"net"
+	"testing"
+)
+
+// Example from RFC 8291 appendix A
+func TestSealWebPushRecordRFC8291(t *testing.T) {
+	b64 := func(s string) []byte {
+		t.Helper()
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	asPrivate, err := ecdh.P256().NewPrivateKey(b64("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := sealWebPushRecord(
+		b64("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"),
+		b64("BTBZMqHH6r4Tts7J_aSIgg"),
+		asPrivate,
+		b64("DGv6ra1nlYgDCS1FRnbzlw"),
+		[]byte("When I grow up, I want to be a watermelon"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := b64("DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+	if !bytes.Equal(got, want) {
+		t.Errorf("record =\n%s\nwant\n%s", base64.RawURLEncoding.EncodeToString(got), base64.RawURLEncoding.EncodeToString(want))
+	}
+}
+
+func TestValidatePushEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		ok       bool
+	}{
+		{"https://fcm.googleapis.com/fcm/send/abc:def", true},
+		{"https://updates.push.services.mozilla.com/wpush/v2/abc", true},
+		{"https://web.push.apple.com/QGuA", true},
+		{"https://wns2-by3p.notify.windows.com/w/?token=abc", true},
+		{"https://FCM.googleapis.com:443/fcm/send/abc", true},
+		{"http://fcm.googleapis.com/fcm/send/abc", false},
+		{"https://fcm.googleapis.com:8443/fcm/send/abc", false},
+		{"https://user@fcm.googleapis.com/fcm/send/abc", false},
+		{"https://fcm.googleapis.com.evil.example/abc", false},
+		{"https://evilfcm.googleapis.com.example/abc", false},
+		{"https://127.0.0.1/admin", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://localhost/", false},
+		{"file:///etc/passwd", false},
+		{"not a url", false},
+	} {
+		if err := validatePushEndpoint(tc.endpoint); (err == nil) != tc.ok {
+			t.Errorf("validatePushEndpoint(%q) = %v, want ok %v", tc.endpoint, err, tc.ok)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for _, tc := range []struct {
+		ip     string
+		public bool
+	}{
+		{"142.250.1.95", true},
+		{"2607:f8b0:4005:80a::200a", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+	} {
+		if got := isPublicIP(net.ParseIP(tc.ip)); got != tc.public {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tc.ip, got, tc.public)
+		}
+	}
+}
+
+func TestSavePushSubscriptionKeepsOwner(t *testing.T) {
+	h := newSQLiteHandler(t)
+	alice := mustCreateUser(t, h.Stores, "alice")
+	mallory := mustCreateUser(t, h.Stores, "mallory")
+
+	sub := PushSubscription{Endpoint: "https://fcm.googleapis.com/fcm/send/alice", P256dh: "key1", Auth: "auth1"}
+	if err := h.SavePushSubscriptionDB(alice.UserID, sub); err != nil {
+		t.Fatal(err)
+	}
+	// Refreshing your own subscription is fine
+	sub.P256dh = "key2"
+	if err := h.SavePushSubscriptionDB(alice.UserID, sub); err != nil {
+		t.Fatalf("re-saving own subscription: %v", err)
+	}
+	if err := h.SavePushSubscriptionDB(mallory.UserID, sub); err != errPushSubscriptionTaken {
+		t.Fatalf("saving another user's endpoint = %v, want %v", err, errPushSubscriptionTaken)
+	}
+
+	subs, err := h.GetPushSubscriptionsDB(alice.UserID)
+	if err != nil || len(subs) != 1 || subs[0].P256dh != "key2" {
+		t.Fatalf("alice's subscriptions = %+v, %v", subs, err)
+	}
+}
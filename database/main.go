@@ -1,31 +1,96 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"main.go/config"
 	"main.go/handlers"
+	"main.go/sqlitedb"
 )
 
 func main() {
+	// Defaults, then CONFIG_FILE, then environment variables
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err.Error())
+	}
+	if cfg.Env != config.Production {
+		fmt.Printf("Running in %s mode\n", cfg.Env)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
 	// Initialize database
-	if err := InitDB(); err != nil {
+	db, err := InitDB(cfg.Database)
+	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize database: %v", err))
 	}
-	defer DB.Close()
+	defer db.Close()
 
-	// Set database connection for handlers
-	handlers.SetDB(DB)
+	// Storage used by handlers
+	stores := handlers.NewPostgresStores(db)
+	if cfg.Database.Driver == "sqlite" {
+		stores = handlers.NewSQLiteStores(db)
+	}
+	h := handlers.NewHandler(stores)
 
 	// Seed initial data
-	SeedCourts()
-	SeedUsers()
+	SeedCourts(h)
+	SeedUsers(cfg, h)
+
+	// Token signing keys, issuer and lifetimes
+	SetupAuth(cfg)
+
+	// Register notification channels
+	SetupNotifications(cfg, h)
+
+	// Payments for paid bookings
+	SetupPayments(cfg, h)
+
+	// Rate limiter state
+	SetupRateLimits(cfg, db)
+
+	// Opening hours, price bands and calendar days are those of the venue
+	venue, err := cfg.Venue.Location()
+	if err != nil {
+		panic(err.Error())
+	}
+	handlers.SetVenueLocation(venue)
+
+	// Anonymise deleted accounts once their grace period is over
+	handlers.SetAccountDeletionGrace(cfg.Privacy.AccountDeletionGrace.Duration)
+	h.StartAccountDeletionWorker(time.Hour)
+
+	// Push slot changes from any instance to live availability streams (SQLite has only one)
+	if cfg.Database.Driver == "sqlite" {
+		sqlitedb.SetNotifyHandler(h.PublishSlotChange)
+	} else if err := h.StartSlotListener(cfg.Database.DSN()); err != nil {
+		fmt.Printf("Warning: Live slot updates disabled: %v\n", err)
+	}
+
+	NewRouter(cfg, h).Run(cfg.Server.Addr)
+}
 
+// NewRouter builds the HTTP API served by h and the services set up in main
+func NewRouter(cfg *config.Config, h *handlers.Handler) *gin.Engine {
 	r := gin.Default()
 
-	// Enable CORS
+	// Only believe X-Forwarded-For from our own proxies; otherwise any caller could pick the
+	// IP that login lockouts and rate limits are keyed on
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("Invalid trusted proxies: %v", err))
+	}
+
+	// Enable CORS for the configured origins ("*" allows any)
 	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin := allowedOrigin(cfg.Server.CORSOrigins, c.GetHeader("Origin")); origin != "" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
@@ -38,52 +103,328 @@ func main() {
 		c.Next()
 	})
 
+	// Public keys for services that verify our tokens
+	r.GET("/.well-known/jwks.json", handlers.HandleJWKS)
+
+	// Rate limits per route group; authenticated requests are limited per user
+	authLimit := handlers.RateLimitMiddleware("auth")
+	publicLimit := handlers.RateLimitMiddleware("public")
+	bookingLimit := handlers.RateLimitMiddleware("bookings")
+	userLimit := handlers.RateLimitMiddleware("user")
+	adminLimit := handlers.RateLimitMiddleware("admin")
+
+	// Uploaded profile pictures
+	handlers.SetProfileUploadDir(cfg.Server.UploadDir, "")
+	r.Static(handlers.ProfileUploadURL(), handlers.ProfileUploadDir())
+
 	api := r.Group("/api")
 	{
 		// Auth endpoints (no auth required)
-		api.POST("/auth/register", handlers.HandleRegister)
-		api.POST("/auth/login", handlers.HandleLogin)
+		api.POST("/auth/register", authLimit, h.HandleRegister)
+		api.POST("/auth/login", authLimit, h.HandleLogin)
+		api.POST("/auth/refresh", authLimit, h.HandleRefreshToken)
+		api.POST("/auth/password/forgot", authLimit, h.HandleForgotPassword)
+		api.POST("/auth/password/reset", authLimit, h.HandleResetPassword)
+		api.POST("/auth/email/verify", authLimit, h.HandleVerifyEmail)
+		api.GET("/auth/oidc/login", authLimit, h.HandleOIDCLogin)
+		api.GET("/auth/oidc/callback", authLimit, h.HandleOIDCCallback)
+		api.POST("/auth/mfa/verify", authLimit, h.HandleVerifyMFA)
 
 		// Auth endpoints (auth required)
-		api.POST("/auth/logout", handlers.AuthMiddleware(), handlers.HandleLogout)
+		api.POST("/auth/logout", h.AuthMiddleware(), authLimit, h.HandleLogout)
+		api.POST("/auth/logout-all", h.AuthMiddleware(), authLimit, h.HandleLogoutAll)
+		api.POST("/auth/email/resend", h.AuthMiddleware(), authLimit, h.HandleResendVerification)
+
+		// Two-factor authentication (enrollment works without MFA so admins can set it up)
+		api.GET("/auth/mfa", h.AuthMiddleware(), userLimit, h.HandleGetMFAStatus)
+		api.POST("/auth/mfa/setup", h.AuthMiddleware(), authLimit, h.HandleSetupMFA)
+		api.POST("/auth/mfa/enable", h.AuthMiddleware(), authLimit, h.HandleEnableMFA)
+		api.POST("/auth/mfa/disable", h.AuthMiddleware(), authLimit, h.HandleDisableMFA)
+		api.POST("/auth/mfa/recovery-codes", h.AuthMiddleware(), authLimit, h.HandleRegenerateRecoveryCodes)
 
 		// User endpoints (auth required)
-		api.GET("/users/:id", handlers.AuthMiddleware(), handlers.HandleGetUserProfile)
+		// Own profile; registered before /users/:id so "me" is not taken as an id
+		me := api.Group("/users/me")
+		me.Use(h.AuthMiddleware(), userLimit)
+		{
+			me.GET("", h.HandleGetMyProfile)
+			me.PUT("", h.HandleUpdateMyProfile)
+			me.PUT("/password", authLimit, h.HandleChangePassword)
+			me.POST("/picture", h.HandleUploadProfilePicture)
+			me.DELETE("/picture", h.HandleDeleteProfilePicture)
 
-		// Admin endpoints (auth + admin required)
-		api.POST("/admin/bookings/reset", handlers.AuthMiddleware(), handlers.AdminMiddleware(), handlers.HandleResetBookings)
+			// PDPA: data export and account deletion
+			me.GET("/export", h.HandleExportMyData)
+			me.GET("/deletion", h.HandleGetAccountDeletion)
+			me.POST("/deletion", authLimit, h.HandleRequestAccountDeletion)
+			me.DELETE("/deletion", h.HandleCancelAccountDeletion)
+
+			// Terms / privacy policy acceptance
+			me.GET("/consents", h.HandleGetMyConsents)
+			me.POST("/consents", h.HandleAcceptPolicies)
+		}
+		api.GET("/users/:id", h.AuthMiddleware(), userLimit, h.HandleGetUserProfile)
+
+		// Staff and admin endpoints (auth + permission required)
+		api.POST("/admin/bookings/reset", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermBookingsReset), adminLimit, h.HandleResetBookings)
+		api.POST("/admin/announcements", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermAnnouncementsManage), adminLimit, h.HandleCreateAnnouncement)
+		api.PUT("/admin/courts/:courtId/status", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermCourtsManage), adminLimit, h.HandleUpdateCourtStatus)
+		api.PUT("/admin/users/:id/pricing-tier", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermPricingManage), adminLimit, h.HandleSetUserPricingTier)
+		api.POST("/admin/wallets/:userId/topup", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermWalletsTopUp), adminLimit, h.HandleTopUpWallet)
+		api.GET("/admin/wallets/reconcile", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermReportsView), adminLimit, h.HandleReconcileLedger)
+		api.GET("/admin/payments", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermPaymentsRefund), adminLimit, h.HandleGetPayments)
+		api.POST("/admin/payments/:paymentId/refunded", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermPaymentsRefund), adminLimit, h.HandleMarkPaymentRefunded)
+		api.GET("/admin/lockouts", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermUsersManage), adminLimit, h.HandleGetLockouts)
+		api.POST("/admin/lockouts/:lockoutId/unlock", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermUsersManage), adminLimit, h.HandleUnlockLockout)
+		api.POST("/admin/users/:id/unlock", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermUsersManage), adminLimit, h.HandleUnlockUser)
+		api.POST("/admin/users/:id/mfa/reset", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermUsersManage), adminLimit, h.HandleResetUserMFA)
+		api.GET("/admin/account-deletions", h.AuthMiddleware(), handlers.RequirePermission(handlers.PermUsersManage), adminLimit, h.HandleGetAccountDeletions)
+
+		// Policy document admin endpoints (auth + permission required)
+		policies := api.Group("/admin/policies")
+		policies.Use(h.AuthMiddleware(), handlers.RequirePermission(handlers.PermPoliciesManage), adminLimit)
+		{
+			policies.GET("", h.HandleGetPolicies)
+			policies.POST("", h.HandleCreatePolicy)
+			policies.PUT("/:documentId", h.HandleUpdatePolicy)
+			policies.POST("/:documentId/publish", h.HandlePublishPolicy)
+		}
+
+		// Roles and permissions (admin only, so no other role can grant itself more)
+		roles := api.Group("/admin")
+		roles.Use(h.AuthMiddleware(), handlers.AdminMiddleware(), adminLimit)
+		{
+			roles.GET("/roles", h.HandleGetRoles)
+			roles.PUT("/roles/:role/permissions", h.HandleSetRolePermissions)
+			roles.PUT("/users/:id/role", h.HandleSetUserRole)
+		}
+
+		// Pricing admin endpoints (auth + permission required)
+		pricing := api.Group("/admin/pricing")
+		pricing.Use(h.AuthMiddleware(), handlers.RequirePermission(handlers.PermPricingManage), adminLimit)
+		{
+			pricing.GET("", h.HandleGetPricingConfig)
+			pricing.PUT("/rates", h.HandleUpsertPriceRate)
+			pricing.DELETE("/rates/:rateId", h.HandleDeletePriceRate)
+			pricing.POST("/bands", h.HandleCreatePriceBand)
+			pricing.DELETE("/bands/:bandId", h.HandleDeletePriceBand)
+			pricing.PUT("/tiers", h.HandleSetPricingTiers)
+		}
+
+		// Voucher admin endpoints (auth + permission required)
+		vouchers := api.Group("/admin/vouchers")
+		vouchers.Use(h.AuthMiddleware(), handlers.RequirePermission(handlers.PermVouchersManage), adminLimit)
+		{
+			vouchers.GET("", h.HandleGetVouchers)
+			vouchers.POST("", h.HandleCreateVoucher)
+			vouchers.GET("/report", h.HandleGetVoucherReport)
+			vouchers.GET("/:voucherId", h.HandleGetVoucher)
+			vouchers.PUT("/:voucherId", h.HandleUpdateVoucher)
+			vouchers.DELETE("/:voucherId", h.HandleDeleteVoucher)
+			vouchers.GET("/:voucherId/redemptions", h.HandleGetVoucherRedemptions)
+		}
+
+		// Current terms of service and privacy policy (public)
+		api.GET("/policies", publicLimit, h.HandleGetCurrentPolicies)
 
 		// Court endpoints (public)
-		api.GET("/sports", handlers.HandleGetSportTypes)
-		api.GET("/courts", handlers.HandleGetCourts)
-		api.GET("/courts/:sportType", handlers.HandleGetCourtsBySportTypeParam)
+		api.GET("/sports", publicLimit, h.HandleGetSportTypes)
+		api.GET("/courts", publicLimit, h.HandleGetCourts)
+		api.GET("/courts/:sportType", publicLimit, h.HandleGetCourtsBySportTypeParam)
 
 		// Slots endpoints (public)
-		api.GET("/slots/available", handlers.HandleGetAvailableSlots)
+		api.GET("/slots/available", publicLimit, h.HandleGetAvailableSlots)
+		api.GET("/slots/stream", publicLimit, h.HandleStreamSlots)
 
-		// Booking endpoints (auth required)
+		// Pricing endpoints (auth required)
+		api.GET("/pricing/quote", h.AuthMiddleware(), userLimit, h.HandleGetPriceQuote)
+
+		// Booking endpoints (auth required, current policies accepted)
 		auth := api.Group("/bookings")
-		auth.Use(handlers.AuthMiddleware())
+		auth.Use(h.AuthMiddleware(), bookingLimit, h.RequirePolicyAcceptance())
+		{
+			auth.POST("", h.RequireVerifiedEmail(), h.HandleCreateBooking)
+			auth.GET("/history", h.HandleGetBookingHistory)
+			auth.DELETE("/:bookingId", h.HandleDeleteBooking)
+			auth.GET("/:bookingId/payment", h.HandleGetBookingPayment)
+		}
+
+		// Wallet endpoints (auth required)
+		wallet := api.Group("/wallet")
+		wallet.Use(h.AuthMiddleware(), userLimit)
+		{
+			wallet.GET("", h.HandleGetWallet)
+			wallet.GET("/transactions", h.HandleGetWalletTransactions)
+		}
+
+		// Payment provider webhook (authenticated by the provider's signature)
+		api.POST("/payments/webhook", h.HandlePaymentWebhook)
+
+		// Notification endpoints (auth required)
+		notifications := api.Group("/notifications")
+		notifications.Use(h.AuthMiddleware(), userLimit)
 		{
-			auth.POST("", handlers.HandleCreateBooking)
-			auth.GET("/history", handlers.HandleGetBookingHistory)
-			auth.DELETE("/:bookingId", handlers.HandleDeleteBooking)
+			notifications.GET("", h.HandleGetNotifications)
+			notifications.GET("/unread-count", h.HandleGetUnreadNotificationCount)
+			notifications.POST("/read-all", h.HandleMarkAllNotificationsRead)
+			notifications.POST("/:notificationId/read", h.HandleMarkNotificationRead)
+			notifications.DELETE("/:notificationId", h.HandleDeleteNotification)
+			notifications.GET("/preferences", h.HandleGetNotificationPreferences)
+			notifications.PUT("/preferences", h.HandleUpdateNotificationPreferences)
+			notifications.POST("/push-subscriptions", h.HandleAddPushSubscription)
+			notifications.DELETE("/push-subscriptions", h.HandleDeletePushSubscription)
 		}
 	}
 
-	r.Run(":8080")
+	return r
 }
 
-func SeedUsers() {
+// allowedOrigin returns the Access-Control-Allow-Origin value for a request origin, or ""
+func allowedOrigin(allowed []string, origin string) string {
+	for _, o := range allowed {
+		if o == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func SeedUsers(cfg *config.Config, h *handlers.Handler) {
 	// Seed users to database
-	if err := handlers.SeedAdminDB(); err != nil {
+	if err := h.SeedAdmin(cfg.Admin.Password); err != nil {
 		fmt.Printf("Warning: Failed to seed admin user: %v\n", err)
 	}
+	if err := h.SeedRolePermissionsDB(); err != nil {
+		fmt.Printf("Warning: Failed to seed role permissions: %v\n", err)
+	}
 }
 
-func SeedCourts() {
+func SeedCourts(h *handlers.Handler) {
 	// Seed courts to database
-	if err := handlers.SeedCourtsDB(); err != nil {
+	if err := h.SeedCourts(); err != nil {
 		fmt.Printf("Warning: Failed to seed courts: %v\n", err)
 	}
 }
+
+func SetupAuth(cfg *config.Config) {
+	auth := cfg.Auth
+	handlers.SetTokenLifetimes(auth.AccessTokenTTL.Duration, auth.RefreshTokenTTL.Duration)
+	handlers.SetTokenIssuer(auth.Issuer, auth.Audience)
+	handlers.SetJWTSecret(auth.JWTSecret)
+
+	// Previous public keys stay loaded for verification while their tokens can still be valid.
+	// Each entry is [kid=]path.
+	for _, entry := range auth.VerifyKeyFiles {
+		kid, path, found := strings.Cut(entry, "=")
+		if !found {
+			kid, path = "", entry
+		}
+		if err := handlers.LoadVerificationKeyFile(path, kid); err != nil {
+			panic(fmt.Sprintf("Failed to load JWT verification key: %v", err))
+		}
+	}
+
+	// Account emails (password reset) go through SMTP when configured, otherwise to the log
+	var mailer handlers.Mailer
+	if m := smtpMailer(cfg.SMTP); m != nil {
+		mailer = m
+	}
+	handlers.SetAccountMailer(mailer, cfg.Server.BaseURL)
+
+	// Allowed email domains limit registration, e.g. "uni.th,student.uni.th"
+	handlers.SetEmailVerification(auth.EmailVerifySecret, auth.AllowedEmailDomains)
+	handlers.SetMFAEncryptionKey(auth.MFAEncryptionKey)
+	if auth.MFAEncryptionKey == "" {
+		fmt.Println("Warning: no MFA encryption key set, two-factor enrollments will not survive a restart")
+	}
+
+	// University SSO (OpenID Connect) is enabled when an issuer is set
+	if oidc := cfg.OIDC; oidc.Issuer != "" {
+		handlers.SetOIDCProvider(&handlers.OIDCProvider{
+			Issuer:         oidc.Issuer,
+			ClientID:       oidc.ClientID,
+			ClientSecret:   oidc.ClientSecret,
+			RedirectURL:    oidc.RedirectURL,
+			Scopes:         oidc.Scopes,
+			StudentIDClaim: oidc.StudentIDClaim,
+		})
+	}
+
+	if auth.SigningKeyFile != "" {
+		if err := handlers.LoadSigningKeyFile(auth.SigningKeyFile, auth.KeyID); err != nil {
+			panic(fmt.Sprintf("Failed to load JWT signing key: %v", err))
+		}
+	} else {
+		fmt.Println("Warning: no JWT signing key file set, signing tokens with the HS256 secret")
+	}
+}
+
+// smtpMailer returns the SMTP mailer, or nil when no SMTP host is configured
+func smtpMailer(smtp config.SMTPConfig) *handlers.SMTPMailer {
+	if smtp.Host == "" {
+		return nil
+	}
+	return &handlers.SMTPMailer{
+		Host:     smtp.Host,
+		Port:     smtp.Port,
+		Username: smtp.Username,
+		Password: smtp.Password,
+		From:     smtp.From,
+	}
+}
+
+func SetupRateLimits(cfg *config.Config, db *sql.DB) {
+	// Buckets are per process unless the postgres store shares them between instances
+	if cfg.RateLimit.Store == "postgres" {
+		handlers.SetRateLimitStore(handlers.NewPostgresRateLimitStore(db))
+	}
+}
+
+func SetupNotifications(cfg *config.Config, h *handlers.Handler) {
+	n := cfg.Notifications
+
+	// In-app inbox is always on; other channels are enabled when configured
+	handlers.RegisterNotificationChannel(handlers.NewInAppChannel(h))
+
+	if mailer := smtpMailer(cfg.SMTP); mailer != nil {
+		handlers.RegisterNotificationChannel(&handlers.EmailChannel{Mailer: mailer})
+	}
+
+	if n.LineChannelAccessToken != "" {
+		handlers.RegisterNotificationChannel(handlers.NewLineChannel(n.LineChannelAccessToken))
+	}
+
+	if n.VAPIDPrivateKey != "" {
+		ch, err := handlers.NewWebPushChannel(h, n.VAPIDPublicKey, n.VAPIDPrivateKey, n.VAPIDSubject)
+		if err != nil {
+			fmt.Printf("Warning: Web push disabled: %v\n", err)
+		} else {
+			handlers.RegisterNotificationChannel(ch)
+		}
+	}
+
+	if n.Log {
+		handlers.RegisterNotificationChannel(&handlers.LogChannel{})
+	}
+}
+
+func SetupPayments(cfg *config.Config, h *handlers.Handler) {
+	p := cfg.Payments
+
+	switch p.Provider {
+	case "promptpay":
+		handlers.SetPaymentProvider(&handlers.PromptPayProvider{
+			PromptPayID:   p.PromptPayID,
+			WebhookSecret: p.WebhookSecret,
+		}, p.HoldTTL.Duration)
+	case "fake":
+		handlers.SetPaymentProvider(handlers.NewFakePaymentProvider(), p.HoldTTL.Duration)
+	}
+
+	handlers.SetRefundCutoff(p.RefundCutoff.Duration)
+
+	h.StartPaymentExpiryWorker(time.Minute)
+}
@@ -0,0 +1,521 @@
+package main
+
+// End-to-end tests of the HTTP API. The router from NewRouter runs against a fresh SQLite
+// database with a fixed clock, and each response is compared with a golden file in
+// testdata/api. After an intended change to the API, rewrite the files with
+//
+//	go test -run TestAPI -update .
+//
+// and review the diff.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"main.go/config"
+	"main.go/handlers"
+	"main.go/sqlitedb"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/api")
+
+// maskedFields change on every run (tokens, payment references, database timestamps) and are
+// replaced before comparing; the golden files only record that they were there
+var maskedFields = map[string]bool{
+	"token":         true,
+	"refresh_token": true,
+	"mfa_token":     true,
+	"reference":     true,
+	"qr_payload":    true,
+	"created_at":    true,
+	"updated_at":    true,
+}
+
+// testClock is a clock the test can move
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testMailer hands account emails to the test
+type testMailer struct {
+	mails chan string
+}
+
+func (m *testMailer) SendMail(to, subject, body string) error {
+	m.mails <- body
+	return nil
+}
+
+type testAPI struct {
+	t       *testing.T
+	handler *handlers.Handler
+	router  http.Handler
+	clock   *testClock
+	mailer  *testMailer
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	// Golden files record times in UTC whatever the machine's zone
+	time.Local = time.UTC
+	os.Exit(m.Run())
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.Path = filepath.Join(t.TempDir(), "courts.db")
+	cfg.Server.UploadDir = t.TempDir()
+	db, err := InitDB(cfg.Database)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	h := handlers.NewHandler(handlers.NewSQLiteStores(db))
+	SeedCourts(h)
+	SeedUsers(cfg, h)
+	SetupAuth(cfg)
+	handlers.SetPaymentProvider(handlers.NewFakePaymentProvider(), cfg.Payments.HoldTTL.Duration)
+	handlers.SetRateLimitStore(handlers.NewMemoryRateLimitStore())
+
+	api := &testAPI{
+		t:       t,
+		handler: h,
+		clock:   &testClock{now: time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)},
+		mailer:  &testMailer{mails: make(chan string, 10)},
+	}
+	handlers.SetClock(api.clock)
+	sqlitedb.SetNow(api.clock.Now)
+	t.Cleanup(func() {
+		handlers.SetClock(nil)
+		sqlitedb.SetNow(nil)
+	})
+	// A venue on UTC keeps the golden files readable
+	handlers.SetVenueLocation(time.UTC)
+	handlers.SetAccountMailer(api.mailer, "")
+	api.router = NewRouter(cfg, h)
+	return api
+}
+
+// do sends a JSON request and returns the status and decoded body
+func (a *testAPI) do(method, path, token string, body any) (int, map[string]any) {
+	a.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+
+	var decoded map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+		a.t.Fatalf("%s %s: response is not a JSON object: %s", method, path, rec.Body.String())
+	}
+	return rec.Code, decoded
+}
+
+// step sends a request and checks the response against testdata/api/<test>/<name>.json
+func (a *testAPI) step(name, method, path, token string, body any, wantStatus int) map[string]any {
+	a.t.Helper()
+	status, got := a.do(method, path, token, body)
+	if status != wantStatus {
+		a.t.Fatalf("%s: %s %s returned %d, want %d: %v", name, method, path, status, wantStatus, got)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(map[string]any{
+		"request": method + " " + path,
+		"status":  status,
+		"body":    mask(copyJSON(a.t, got)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	actual := buf.Bytes()
+
+	golden := filepath.Join("testdata", "api", a.t.Name(), name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			a.t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, actual, 0o644); err != nil {
+			a.t.Fatal(err)
+		}
+		return got
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		a.t.Fatalf("%s: %v (run with -update to create it)", name, err)
+	}
+	if !bytes.Equal(actual, want) {
+		a.t.Errorf("%s: response differs from %s\ngot:\n%s\nwant:\n%s", name, golden, actual, want)
+	}
+	return got
+}
+
+// emailToken returns the token from the next account email (verification or password reset)
+func (a *testAPI) emailToken() string {
+	a.t.Helper()
+	var body string
+	select {
+	case body = <-a.mailer.mails:
+	case <-time.After(5 * time.Second):
+		a.t.Fatal("no account email was sent")
+	}
+	m := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(body)
+	if m == nil {
+		a.t.Fatalf("no token in account email: %q", body)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return token
+}
+
+// member registers a verified member who accepted policies and returns their access token
+func (a *testAPI) member(username string, policies ...int) string {
+	a.t.Helper()
+	a.must("POST", "/api/auth/register", "", map[string]any{
+		"first_name":        username,
+		"username":          username,
+		"password":          username + "-secret",
+		"email":             username + "@example.com",
+		"accepted_policies": policies,
+	}, http.StatusCreated)
+	a.must("POST", "/api/auth/email/verify", "", map[string]any{"token": a.emailToken()}, http.StatusOK)
+	return a.login(username)
+}
+
+// login signs in a member created by member and returns their access token
+func (a *testAPI) login(username string) string {
+	a.t.Helper()
+	login := a.must("POST", "/api/auth/login", "", map[string]any{
+		"username": username,
+		"password": username + "-secret",
+	}, http.StatusOK)
+	return login["user"].(map[string]any)["token"].(string)
+}
+
+// admin signs in as the seeded admin, enrolling two-factor authentication, and returns the
+// token of the MFA session; admin routes refuse sessions without it
+func (a *testAPI) admin() string {
+	a.t.Helper()
+	login := a.must("POST", "/api/auth/login", "", map[string]any{
+		"username": "somchai_k",
+		"password": config.Default().Admin.Password,
+	}, http.StatusOK)
+	return a.enrollMFA(login["user"].(map[string]any)["token"].(string))
+}
+
+func (a *testAPI) enrollMFA(token string) string {
+	a.t.Helper()
+	setup := a.must("POST", "/api/auth/mfa/setup", token, nil, http.StatusOK)
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(setup["secret"].(string))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	enabled := a.must("POST", "/api/auth/mfa/enable", token, map[string]any{"code": totp(secret, a.clock.Now())}, http.StatusOK)
+	return enabled["user"].(map[string]any)["token"].(string)
+}
+
+// must is do for requests that are set-up rather than under test
+func (a *testAPI) must(method, path, token string, body any, wantStatus int) map[string]any {
+	a.t.Helper()
+	status, got := a.do(method, path, token, body)
+	if status != wantStatus {
+		a.t.Fatalf("%s %s returned %d, want %d: %v", method, path, status, wantStatus, got)
+	}
+	return got
+}
+
+func TestAPIBookingFlow(t *testing.T) {
+	api := newTestAPI(t)
+
+	api.step("register", "POST", "/api/auth/register", "", map[string]any{
+		"first_name":   "Alice",
+		"last_name":    "Chen",
+		"username":     "alice",
+		"password":     "alice-secret",
+		"email":        "alice@example.com",
+		"phone_number": "0812345678",
+		"student_id":   "6512345678",
+	}, http.StatusCreated)
+	api.step("verify_email", "POST", "/api/auth/email/verify", "", map[string]any{"token": api.emailToken()}, http.StatusOK)
+
+	login := api.step("login", "POST", "/api/auth/login", "", map[string]any{
+		"username": "alice",
+		"password": "alice-secret",
+	}, http.StatusOK)
+	token := login["user"].(map[string]any)["token"].(string)
+
+	// Tomorrow 10:00-11:00 on the first badminton court (free, no payment)
+	booking := map[string]any{
+		"court_id":     1,
+		"booking_date": "2030-03-05",
+		"start_time":   "2030-03-05T10:00:00Z",
+		"end_time":     "2030-03-05T11:00:00Z",
+	}
+	created := api.step("book", "POST", "/api/bookings", token, booking, http.StatusCreated)
+	bookingID := int(created["booking_id"].(float64))
+
+	api.step("book_conflict", "POST", "/api/bookings", token, map[string]any{
+		"court_id":     1,
+		"booking_date": "2030-03-05",
+		"start_time":   "2030-03-05T10:30:00Z",
+		"end_time":     "2030-03-05T11:30:00Z",
+	}, http.StatusConflict)
+	api.step("slots_booked", "GET", "/api/slots/available?sportType=badminton&date=2030-03-05", "", nil, http.StatusOK)
+
+	api.step("cancel", "DELETE", fmt.Sprintf("/api/bookings/%d", bookingID), token, nil, http.StatusOK)
+	api.step("history_cancelled", "GET", "/api/bookings/history", token, nil, http.StatusOK)
+
+	// The cancelled slot can be booked again
+	rebooked := api.step("rebook", "POST", "/api/bookings", token, booking, http.StatusCreated)
+	rebookedID := int(rebooked["booking_id"].(float64))
+
+	adminLogin := api.step("admin_login", "POST", "/api/auth/login", "", map[string]any{
+		"username": "somchai_k",
+		"password": config.Default().Admin.Password,
+	}, http.StatusOK)
+	adminToken := adminLogin["user"].(map[string]any)["token"].(string)
+	api.step("admin_reset_without_mfa", "POST", "/api/admin/bookings/reset", adminToken, nil, http.StatusForbidden)
+	// Without 2FA the admin session gets no more than a member's
+	api.step("admin_cancel_without_mfa", "DELETE", fmt.Sprintf("/api/bookings/%d", rebookedID), adminToken, nil, http.StatusForbidden)
+	api.step("admin_payment_without_mfa", "GET", fmt.Sprintf("/api/bookings/%d/payment", rebookedID), adminToken, nil, http.StatusNotFound)
+	api.step("admin_profile_without_mfa", "GET", fmt.Sprintf("/api/users/%d", int(login["user"].(map[string]any)["user_id"].(float64))), adminToken, nil, http.StatusOK)
+	api.step("member_reset", "POST", "/api/admin/bookings/reset", token, nil, http.StatusForbidden)
+
+	adminToken = api.enrollMFA(adminToken)
+	api.step("admin_reset", "POST", "/api/admin/bookings/reset", adminToken, nil, http.StatusOK)
+	api.step("history_after_reset", "GET", "/api/bookings/history", token, nil, http.StatusOK)
+	api.step("slots_after_reset", "GET", "/api/slots/available?sportType=badminton&date=2030-03-05", "", nil, http.StatusOK)
+}
+
+func TestAPIPaymentHoldExpiry(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	// Bob signs up before there are terms to accept
+	bob := api.member("bob")
+
+	api.step("set_rate", "PUT", "/api/admin/pricing/rates", admin, map[string]any{
+		"sport_type":  "tennis",
+		"hourly_rate": 200,
+	}, http.StatusOK)
+
+	// Terms published now (by the test clock) have to be accepted before booking
+	draft := api.must("POST", "/api/admin/policies", admin, map[string]any{
+		"kind":    "terms",
+		"version": "1",
+		"title":   "Terms of use",
+		"body":    "Be nice to the courts.",
+	}, http.StatusCreated)
+	documentID := int(draft["data"].(map[string]any)["document_id"].(float64))
+	api.step("publish_terms", "POST", fmt.Sprintf("/api/admin/policies/%d/publish", documentID), admin, nil, http.StatusOK)
+	api.step("current_policies", "GET", "/api/policies", "", nil, http.StatusOK)
+
+	alice := api.member("alice", documentID)
+
+	// First tennis court tomorrow 18:00-19:00; Alice's unpaid booking holds it for 15 minutes
+	booking := map[string]any{
+		"court_id":     7,
+		"booking_date": "2030-03-05",
+		"start_time":   "2030-03-05T18:00:00Z",
+		"end_time":     "2030-03-05T19:00:00Z",
+	}
+	api.step("book_held", "POST", "/api/bookings", alice, booking, http.StatusCreated)
+
+	api.step("book_without_consent", "POST", "/api/bookings", bob, booking, http.StatusForbidden)
+	api.step("accept_terms", "POST", "/api/users/me/consents", bob, map[string]any{"document_ids": []int{documentID}}, http.StatusOK)
+	api.step("consents", "GET", "/api/users/me/consents", bob, nil, http.StatusOK)
+	api.step("book_during_hold", "POST", "/api/bookings", bob, booking, http.StatusConflict)
+
+	// Once the hold runs out the slot is free even before the expiry worker has run. Access
+	// tokens run on the same clock, so both have to sign in again.
+	api.clock.Advance(16 * time.Minute)
+	api.step("token_expired", "POST", "/api/bookings", bob, booking, http.StatusUnauthorized)
+	alice, bob = api.login("alice"), api.login("bob")
+	api.step("book_after_hold", "POST", "/api/bookings", bob, booking, http.StatusCreated)
+
+	if expired, err := api.handler.ExpirePendingBookingsDB(); err != nil || expired != 1 {
+		t.Fatalf("ExpirePendingBookingsDB = %d, %v; want 1 expired hold", expired, err)
+	}
+	api.step("history_expired", "GET", "/api/bookings/history", alice, nil, http.StatusOK)
+}
+
+func TestAPIAccountTokenExpiry(t *testing.T) {
+	api := newTestAPI(t)
+
+	// Verification links are good for 48 hours
+	api.must("POST", "/api/auth/register", "", map[string]any{
+		"first_name": "Carol",
+		"username":   "carol",
+		"password":   "carol-secret",
+		"email":      "carol@example.com",
+	}, http.StatusCreated)
+	verification := api.emailToken()
+	api.clock.Advance(49 * time.Hour)
+	api.step("verify_expired", "POST", "/api/auth/email/verify", "", map[string]any{"token": verification}, http.StatusBadRequest)
+
+	token := api.login("carol")
+	api.must("POST", "/api/auth/email/resend", token, nil, http.StatusAccepted)
+	api.step("verify_resent", "POST", "/api/auth/email/verify", "", map[string]any{"token": api.emailToken()}, http.StatusOK)
+
+	// Password reset links are good for an hour
+	api.must("POST", "/api/auth/password/forgot", "", map[string]any{"email": "carol@example.com"}, http.StatusAccepted)
+	reset := api.emailToken()
+	api.clock.Advance(61 * time.Minute)
+	api.step("reset_expired", "POST", "/api/auth/password/reset", "", map[string]any{
+		"token":        reset,
+		"new_password": "carol-new-secret",
+	}, http.StatusBadRequest)
+
+	api.must("POST", "/api/auth/password/forgot", "", map[string]any{"email": "carol@example.com"}, http.StatusAccepted)
+	reset = api.emailToken()
+	api.clock.Advance(59 * time.Minute)
+	api.step("reset_in_time", "POST", "/api/auth/password/reset", "", map[string]any{
+		"token":        reset,
+		"new_password": "carol-new-secret",
+	}, http.StatusOK)
+	api.step("login_new_password", "POST", "/api/auth/login", "", map[string]any{
+		"username": "carol",
+		"password": "carol-new-secret",
+	}, http.StatusOK)
+}
+
+func TestAPIEmailChangeResendLimit(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.member("dave")
+
+	// The registration email was just sent, so the new address waits out the cooldown
+	api.step("change_email_throttled", "PUT", "/api/users/me", token, map[string]any{"email": "dave@uni.example"}, http.StatusOK)
+	select {
+	case <-api.mailer.mails:
+		t.Fatal("verification email sent during the resend cooldown")
+	default:
+	}
+
+	api.clock.Advance(3 * time.Minute)
+	api.step("change_email", "PUT", "/api/users/me", token, map[string]any{"email": "dave@example.com"}, http.StatusOK)
+	api.step("verify_new_email", "POST", "/api/auth/email/verify", "", map[string]any{"token": api.emailToken()}, http.StatusOK)
+}
+
+func TestClientIPIgnoresUntrustedForwardedFor(t *testing.T) {
+	api := newTestAPI(t)
+	for _, tc := range []struct {
+		proxies []string
+		want    string
+	}{
+		{nil, "192.0.2.1"},
+		{[]string{"10.0.0.0/8"}, "192.0.2.1"},
+		{[]string{"192.0.2.0/24"}, "203.0.113.7"},
+	} {
+		cfg := config.Default()
+		cfg.Server.TrustedProxies = tc.proxies
+		r := NewRouter(cfg, api.handler)
+		r.GET("/test/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+		req := httptest.NewRequest("GET", "/test/ip", nil) // from 192.0.2.1
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got := rec.Body.String(); got != tc.want {
+			t.Errorf("trusted proxies %v: client IP = %s, want %s", tc.proxies, got, tc.want)
+		}
+	}
+}
+
+// Internal functions
+
+// mask replaces the values of maskedFields anywhere in v
+func mask(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			if maskedFields[k] && val != nil {
+				x[k] = "<masked>"
+			} else {
+				x[k] = mask(val)
+			}
+		}
+	case []any:
+		for i := range x {
+			x[i] = mask(x[i])
+		}
+	}
+	return v
+}
+
+// copyJSON deep-copies a decoded JSON value, so masking it leaves the original usable
+func copyJSON(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c any
+	if err := json.Unmarshal(data, &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// totp is the six digit code an authenticator app shows for secret at t (RFC 6238)
+func totp(secret []byte, t time.Time) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(t.Unix()/30))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1_000_000)
+}
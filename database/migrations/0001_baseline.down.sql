@@ -0,0 +1,39 @@
+-- Drops the whole schema, data included
+DROP TABLE IF EXISTS
+	role_permissions,
+	policy_acceptances,
+	policy_documents,
+	account_deletions,
+	mfa_challenges,
+	mfa_recovery_codes,
+	user_mfa,
+	user_identities,
+	oidc_logins,
+	rate_limit_buckets,
+	login_lockouts,
+	login_attempts,
+	email_verification_sends,
+	password_resets,
+	refresh_tokens,
+	auth_sessions,
+	voucher_redemptions,
+	vouchers,
+	ledger_entries,
+	ledger_transactions,
+	ledger_accounts,
+	payments,
+	price_tiers,
+	price_bands,
+	price_rates,
+	push_subscriptions,
+	notifications,
+	notification_preferences,
+	bookings,
+	courts,
+	users
+	CASCADE;
+
+DROP FUNCTION IF EXISTS notify_court_change();
+DROP FUNCTION IF EXISTS notify_slot_change();
+DROP FUNCTION IF EXISTS update_modified_column();
+DROP FUNCTION IF EXISTS reject_ledger_change();
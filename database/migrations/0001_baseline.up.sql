@@ -0,0 +1,451 @@
+-- Baseline: the schema the server used to create at startup. Every statement is idempotent so
+-- databases created before migrations existed (by the server or by docker/init.sql) adopt it
+-- without changes.
+
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+	UserID SERIAL PRIMARY KEY,
+	FirstName VARCHAR(100) NOT NULL,
+	LastName VARCHAR(100),
+	UserName VARCHAR(50) UNIQUE NOT NULL,
+	PasswordHash VARCHAR(255) NOT NULL,
+	Email VARCHAR(100) UNIQUE,
+	PhoneNumber VARCHAR(15),
+	StudentID VARCHAR(20) UNIQUE,
+	Role VARCHAR(20) DEFAULT 'Member' CHECK (Role IN ('Member', 'Staff', 'Coach', 'ClubManager', 'Admin')),
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create courts table
+CREATE TABLE IF NOT EXISTS courts (
+	CourtID SERIAL PRIMARY KEY,
+	CourtName VARCHAR(100) NOT NULL,
+	SportType VARCHAR(50) NOT NULL,
+	CourtNumber INT NOT NULL,
+	Status VARCHAR(20) DEFAULT 'Available',
+	UNIQUE (SportType, CourtNumber)
+);
+
+-- Create bookings table
+CREATE TABLE IF NOT EXISTS bookings (
+	BookingID SERIAL PRIMARY KEY,
+	CourtID INT REFERENCES courts(CourtID) ON DELETE CASCADE,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	StartTime TIMESTAMP WITH TIME ZONE NOT NULL,
+	EndTime TIMESTAMP WITH TIME ZONE NOT NULL,
+	BookingStatus VARCHAR(20) DEFAULT 'Confirmed',
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE
+);
+
+-- LINE Messaging user id used for push notifications
+ALTER TABLE users ADD COLUMN IF NOT EXISTS LineUserID VARCHAR(64);
+
+-- Create notification preferences table (rows override the defaults)
+CREATE TABLE IF NOT EXISTS notification_preferences (
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	EventType VARCHAR(50) NOT NULL,
+	Channel VARCHAR(20) NOT NULL,
+	Enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	PRIMARY KEY (UserID, EventType, Channel)
+);
+
+-- Create in-app notifications table
+CREATE TABLE IF NOT EXISTS notifications (
+	NotificationID SERIAL PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	EventType VARCHAR(50) NOT NULL,
+	Title VARCHAR(200) NOT NULL,
+	Message TEXT NOT NULL,
+	ReadAt TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create web push subscriptions table
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+	SubscriptionID SERIAL PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Endpoint TEXT UNIQUE NOT NULL,
+	P256dh VARCHAR(200) NOT NULL,
+	Auth VARCHAR(100) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Pricing: tier per user, base rates, time bands and tier multipliers (amounts in satang)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS PricingTier VARCHAR(20) NOT NULL DEFAULT 'Member'
+	CHECK (PricingTier IN ('Member', 'Student', 'Guest'));
+
+CREATE TABLE IF NOT EXISTS price_rates (
+	RateID SERIAL PRIMARY KEY,
+	SportType VARCHAR(50) NOT NULL,
+	CourtID INT REFERENCES courts(CourtID) ON DELETE CASCADE,
+	HourlyRate INT NOT NULL CHECK (HourlyRate >= 0)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_price_rates_target ON price_rates(SportType, COALESCE(CourtID, 0));
+
+CREATE TABLE IF NOT EXISTS price_bands (
+	BandID SERIAL PRIMARY KEY,
+	Name VARCHAR(50) NOT NULL,
+	DayType VARCHAR(10) NOT NULL CHECK (DayType IN ('all', 'weekday', 'weekend')),
+	StartHour INT NOT NULL CHECK (StartHour BETWEEN 0 AND 23),
+	EndHour INT NOT NULL CHECK (EndHour BETWEEN 1 AND 24),
+	RatePercent INT NOT NULL CHECK (RatePercent >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS price_tiers (
+	Tier VARCHAR(20) PRIMARY KEY CHECK (Tier IN ('Member', 'Student', 'Guest')),
+	RatePercent INT NOT NULL CHECK (RatePercent >= 0)
+);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS Price INT NOT NULL DEFAULT 0;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS PriceBreakdown JSONB;
+
+-- Paid bookings hold their slot as PendingPayment until HoldExpiresAt
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS HoldExpiresAt TIMESTAMP WITH TIME ZONE;
+
+-- Create payments table
+CREATE TABLE IF NOT EXISTS payments (
+	PaymentID SERIAL PRIMARY KEY,
+	BookingID INT REFERENCES bookings(BookingID) ON DELETE CASCADE NOT NULL,
+	Provider VARCHAR(20) NOT NULL,
+	Reference VARCHAR(64) UNIQUE NOT NULL,
+	Amount INT NOT NULL,
+	Status VARCHAR(20) NOT NULL DEFAULT 'Pending',
+	QRPayload TEXT NOT NULL,
+	ExpiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+	PaidAt TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Wallet ledger (double-entry, append-only; amounts in satang)
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+	AccountID SERIAL PRIMARY KEY,
+	Code VARCHAR(50) UNIQUE NOT NULL,
+	UserID INT UNIQUE REFERENCES users(UserID) ON DELETE RESTRICT,
+	Balance BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+	TxID SERIAL PRIMARY KEY,
+	Kind VARCHAR(20) NOT NULL CHECK (Kind IN ('topup', 'booking', 'refund')),
+	BookingID INT,
+	Description VARCHAR(200) NOT NULL,
+	CreatedBy INT,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+	EntryID SERIAL PRIMARY KEY,
+	TxID INT REFERENCES ledger_transactions(TxID) NOT NULL,
+	AccountID INT REFERENCES ledger_accounts(AccountID) NOT NULL,
+	Amount BIGINT NOT NULL CHECK (Amount <> 0)
+);
+
+CREATE OR REPLACE FUNCTION reject_ledger_change()
+RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'ledger is append-only';
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS ledger_transactions_append_only ON ledger_transactions;
+CREATE TRIGGER ledger_transactions_append_only
+BEFORE UPDATE OR DELETE ON ledger_transactions
+FOR EACH ROW
+EXECUTE FUNCTION reject_ledger_change();
+
+DROP TRIGGER IF EXISTS ledger_entries_append_only ON ledger_entries;
+CREATE TRIGGER ledger_entries_append_only
+BEFORE UPDATE OR DELETE ON ledger_entries
+FOR EACH ROW
+EXECUTE FUNCTION reject_ledger_change();
+
+-- Promo codes; NULL restriction/limit columns mean "no restriction"
+CREATE TABLE IF NOT EXISTS vouchers (
+	VoucherID SERIAL PRIMARY KEY,
+	Code VARCHAR(32) UNIQUE NOT NULL,
+	Description VARCHAR(200) NOT NULL DEFAULT '',
+	DiscountType VARCHAR(10) NOT NULL CHECK (DiscountType IN ('percent', 'fixed')),
+	PercentOff INT NOT NULL DEFAULT 0 CHECK (PercentOff BETWEEN 0 AND 100),
+	AmountOff INT NOT NULL DEFAULT 0 CHECK (AmountOff >= 0),
+	MaxDiscount INT CHECK (MaxDiscount >= 0),
+	ValidFrom TIMESTAMP WITH TIME ZONE NOT NULL,
+	ValidUntil TIMESTAMP WITH TIME ZONE NOT NULL,
+	GlobalLimit INT CHECK (GlobalLimit > 0),
+	PerUserLimit INT CHECK (PerUserLimit > 0),
+	SportType VARCHAR(50),
+	CourtID INT REFERENCES courts(CourtID) ON DELETE CASCADE,
+	StartHour INT CHECK (StartHour BETWEEN 0 AND 23),
+	EndHour INT CHECK (EndHour BETWEEN 1 AND 24),
+	Active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS voucher_redemptions (
+	RedemptionID SERIAL PRIMARY KEY,
+	VoucherID INT REFERENCES vouchers(VoucherID) ON DELETE RESTRICT NOT NULL,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	BookingID INT UNIQUE REFERENCES bookings(BookingID) ON DELETE CASCADE NOT NULL,
+	Discount INT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login sessions and rotating refresh tokens (only SHA-256 hashes are stored).
+-- Bumping users.TokenVersion revokes every session of that user.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS TokenVersion INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS auth_sessions (
+	SessionID VARCHAR(32) PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	TokenVersion INT NOT NULL,
+	IPAddress VARCHAR(45),
+	UserAgent VARCHAR(255),
+	RevokedAt TIMESTAMP WITH TIME ZONE,
+	RevokeReason VARCHAR(50),
+	LastUsedAt TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	TokenID SERIAL PRIMARY KEY,
+	SessionID VARCHAR(32) REFERENCES auth_sessions(SessionID) ON DELETE CASCADE NOT NULL,
+	TokenHash CHAR(64) UNIQUE NOT NULL,
+	ExpiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+	UsedAt TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Single-use password reset tokens (SHA-256 hashes only)
+CREATE TABLE IF NOT EXISTS password_resets (
+	ResetID SERIAL PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	TokenHash CHAR(64) UNIQUE NOT NULL,
+	ExpiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+	UsedAt TIMESTAMP WITH TIME ZONE,
+	RequestIP VARCHAR(45),
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Email verification. Accounts that existed before verification was introduced are
+-- treated as verified; new ones start unverified.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS EmailVerifiedAt TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ALTER COLUMN EmailVerifiedAt DROP DEFAULT;
+
+CREATE TABLE IF NOT EXISTS email_verification_sends (
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login attempts per username/IP and the lockouts they triggered (audit trail)
+CREATE TABLE IF NOT EXISTS login_attempts (
+	AttemptID SERIAL PRIMARY KEY,
+	UserName VARCHAR(50) NOT NULL,
+	IPAddress VARCHAR(45) NOT NULL,
+	Success BOOLEAN NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+	LockoutID SERIAL PRIMARY KEY,
+	Scope VARCHAR(10) NOT NULL CHECK (Scope IN ('user', 'ip')),
+	Subject VARCHAR(64) NOT NULL,
+	Failures INT NOT NULL,
+	LockedUntil TIMESTAMP WITH TIME ZONE NOT NULL,
+	UnlockedAt TIMESTAMP WITH TIME ZONE,
+	UnlockedBy INT REFERENCES users(UserID) ON DELETE SET NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Token buckets for API rate limiting when state is shared between instances
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+	BucketKey VARCHAR(128) PRIMARY KEY,
+	Tokens DOUBLE PRECISION NOT NULL,
+	Allowed BOOLEAN NOT NULL,
+	UpdatedAt TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Single sign-on: logins in progress and identities linked to local users
+CREATE TABLE IF NOT EXISTS oidc_logins (
+	StateHash CHAR(64) PRIMARY KEY,
+	Nonce VARCHAR(64) NOT NULL,
+	CodeVerifier VARCHAR(128) NOT NULL,
+	ExpiresAt TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+	Issuer VARCHAR(255) NOT NULL,
+	Subject VARCHAR(255) NOT NULL,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Email VARCHAR(100),
+	LastLoginAt TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (Issuer, Subject)
+);
+
+-- Two-factor authentication (TOTP secrets are AES-GCM encrypted)
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS MFA BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+	UserID INT PRIMARY KEY REFERENCES users(UserID) ON DELETE CASCADE,
+	Secret TEXT NOT NULL,
+	Enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	EnabledAt TIMESTAMP WITH TIME ZONE,
+	LastUsedStep BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+	CodeID SERIAL PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	CodeHash CHAR(64) NOT NULL,
+	UsedAt TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+	ChallengeHash CHAR(64) PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Attempts INT NOT NULL DEFAULT 0,
+	ExpiresAt TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Profile picture URL (self-service profile)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS ProfilePicture VARCHAR(255);
+
+-- PDPA account deletion: requests wait out a grace period, then the user row is anonymised
+ALTER TABLE users ADD COLUMN IF NOT EXISTS DeletedAt TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+	RequestID SERIAL PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Reason VARCHAR(500),
+	RequestedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ScheduledFor TIMESTAMP WITH TIME ZONE NOT NULL,
+	CancelledAt TIMESTAMP WITH TIME ZONE,
+	CompletedAt TIMESTAMP WITH TIME ZONE
+);
+
+-- Versioned terms of service / privacy policy and who accepted which version
+CREATE TABLE IF NOT EXISTS policy_documents (
+	DocumentID SERIAL PRIMARY KEY,
+	Kind VARCHAR(20) NOT NULL CHECK (Kind IN ('terms', 'privacy')),
+	Version VARCHAR(20) NOT NULL,
+	Title VARCHAR(200) NOT NULL,
+	Body TEXT NOT NULL,
+	PublishedAt TIMESTAMP WITH TIME ZONE,
+	PublishedBy INT REFERENCES users(UserID),
+	CreatedBy INT REFERENCES users(UserID),
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (Kind, Version)
+);
+
+CREATE TABLE IF NOT EXISTS policy_acceptances (
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	DocumentID INT REFERENCES policy_documents(DocumentID) NOT NULL,
+	IPAddress VARCHAR(45),
+	AcceptedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (UserID, DocumentID)
+);
+
+-- Role-based access control: staff roles and what each role may do (Admin may do everything)
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (Role IN ('Member', 'Staff', 'Coach', 'ClubManager', 'Admin'));
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	Role VARCHAR(20) NOT NULL CHECK (Role IN ('Member', 'Staff', 'Coach', 'ClubManager')),
+	Permission VARCHAR(50) NOT NULL,
+	GrantedBy INT REFERENCES users(UserID) ON DELETE SET NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (Role, Permission)
+);
+
+-- Create update trigger function
+CREATE OR REPLACE FUNCTION update_modified_column()
+RETURNS TRIGGER AS $$
+BEGIN
+	NEW.updated_at = now();
+	RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Create triggers
+DROP TRIGGER IF EXISTS update_users_modtime ON users;
+CREATE TRIGGER update_users_modtime
+BEFORE UPDATE ON users
+FOR EACH ROW
+EXECUTE FUNCTION update_modified_column();
+
+DROP TRIGGER IF EXISTS update_bookings_modtime ON bookings;
+CREATE TRIGGER update_bookings_modtime
+BEFORE UPDATE ON bookings
+FOR EACH ROW
+EXECUTE FUNCTION update_modified_column();
+
+-- Publish slot changes so every API instance can update its live streams
+CREATE OR REPLACE FUNCTION notify_slot_change()
+RETURNS TRIGGER AS $$
+DECLARE
+	rec RECORD;
+	sport VARCHAR(50);
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec := OLD;
+	ELSE
+		rec := NEW;
+	END IF;
+	SELECT SportType INTO sport FROM courts WHERE CourtID = rec.CourtID;
+	PERFORM pg_notify('slot_changes', json_build_object(
+		'op', TG_OP,
+		'court_id', rec.CourtID,
+		'sport_type', sport,
+		'start_time', rec.StartTime,
+		'end_time', rec.EndTime
+	)::text);
+	RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION notify_court_change()
+RETURNS TRIGGER AS $$
+BEGIN
+	PERFORM pg_notify('slot_changes', json_build_object(
+		'op', TG_OP,
+		'court_id', NEW.CourtID,
+		'sport_type', NEW.SportType
+	)::text);
+	RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS bookings_notify_slot_change ON bookings;
+CREATE TRIGGER bookings_notify_slot_change
+AFTER INSERT OR UPDATE OR DELETE ON bookings
+FOR EACH ROW
+EXECUTE FUNCTION notify_slot_change();
+
+DROP TRIGGER IF EXISTS courts_notify_slot_change ON courts;
+CREATE TRIGGER courts_notify_slot_change
+AFTER UPDATE OF Status ON courts
+FOR EACH ROW
+EXECUTE FUNCTION notify_court_change();
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_bookings_court_time ON bookings(CourtID, StartTime, EndTime);
+CREATE INDEX IF NOT EXISTS idx_bookings_user ON bookings(UserID);
+CREATE INDEX IF NOT EXISTS idx_courts_sport ON courts(SportType);
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(UserID, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_bookings_pending ON bookings(HoldExpiresAt) WHERE BookingStatus = 'PendingPayment';
+CREATE INDEX IF NOT EXISTS idx_payments_booking ON payments(BookingID);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries(AccountID, TxID);
+CREATE INDEX IF NOT EXISTS idx_voucher_redemptions_voucher ON voucher_redemptions(VoucherID, UserID);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user ON auth_sessions(UserID);
+CREATE INDEX IF NOT EXISTS idx_email_verification_sends_user ON email_verification_sends(UserID, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(UserName, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(IPAddress, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_lockouts_subject ON login_lockouts(Scope, Subject, created_at);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(UserID);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(UserID, CodeHash);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_pending ON account_deletions(UserID) WHERE CancelledAt IS NULL AND CompletedAt IS NULL;
+CREATE INDEX IF NOT EXISTS idx_policy_documents_current ON policy_documents(Kind, PublishedAt DESC) WHERE PublishedAt IS NOT NULL;
@@ -0,0 +1,17 @@
+-- Databases created by the old docker/init.sql have Thai sport types, unlike the English ones
+-- the server seeds and the frontend filters on, and plaintext "012345" password hashes. Map the
+-- sports to the English names and lock the plaintext accounts; their owners can set a
+-- password through the reset flow.
+--
+-- There is no down file: which courts had Thai names and which hashes were plaintext is not
+-- kept, so the migration cannot be undone and rolling it back fails.
+UPDATE courts SET SportType = CASE SportType
+		WHEN 'แบดมินตัน' THEN 'badminton'
+		WHEN 'บาสเกตบอล' THEN 'basketball'
+		WHEN 'เทนนิส' THEN 'tennis'
+		WHEN 'วอลเลย์บอล' THEN 'volleyball'
+	END
+WHERE SportType IN ('แบดมินตัน', 'บาสเกตบอล', 'เทนนิส', 'วอลเลย์บอล');
+
+UPDATE users SET PasswordHash = '!'
+WHERE PasswordHash NOT LIKE '$2_$%' AND PasswordHash <> '!';
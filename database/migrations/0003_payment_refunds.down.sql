@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_status;
+UPDATE payments SET Status = 'RefundRequired' WHERE Status = 'Refunded';
+ALTER TABLE payments DROP COLUMN IF EXISTS RefundedBy, DROP COLUMN IF EXISTS RefundedAt;
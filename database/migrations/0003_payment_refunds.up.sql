@@ -0,0 +1,6 @@
+-- Payments flagged RefundRequired are paid back outside the system; an admin then marks them
+-- Refunded, recording when and by whom.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS RefundedAt TIMESTAMP WITH TIME ZONE;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS RefundedBy INT REFERENCES users(UserID) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(Status, PaymentID);
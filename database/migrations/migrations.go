@@ -0,0 +1,294 @@
+// Package migrations applies the versioned schema migrations embedded in the binary.
+//
+// Migrations are pairs of files named NNNN_name.up.sql and NNNN_name.down.sql, applied in
+// version order, each in its own transaction. A migration without a down file is
+// irreversible: Down refuses to roll it back. Applied versions are recorded in
+// schema_migrations, and a Postgres advisory lock keeps two instances from migrating at once.
+//
+// SQLite databases (see package sqlitedb) use the translations in sqlite/, which keep the same
+// versions and names as the Postgres files so both dialects always describe the same schema.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"main.go/sqlitedb"
+)
+
+//go:embed *.sql sqlite/*.sql
+var files embed.FS
+
+// lockID is the advisory lock held while migrating
+const lockID = 727_001
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status of one migration in the database
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+	// Unknown is set for versions applied by a newer binary
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// All returns the embedded migrations for db's dialect in version order
+func All(db *sql.DB) ([]Migration, error) {
+	dir := "."
+	if sqlitedb.Is(db) {
+		dir = "sqlite"
+	}
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %s", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(files, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	all := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		all = append(all, *mig)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// Up applies every pending migration and returns how many ran
+func Up(db *sql.DB) (int, error) {
+	return UpTo(db, 0)
+}
+
+// UpTo applies pending migrations up to and including target (0 means all)
+func UpTo(db *sql.DB, target int) (int, error) {
+	all, err := All(db)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range all {
+			if target > 0 && mig.Version > target {
+				break
+			}
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := run(conn, mig, mig.Up, true); err != nil {
+				return err
+			}
+			log.Printf("✅ Migration %04d_%s applied", mig.Version, mig.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps applied migrations and returns how many were rolled back
+func Down(db *sql.DB, steps int) (int, error) {
+	all, err := All(db)
+	if err != nil {
+		return 0, err
+	}
+	known := map[int]Migration{}
+	for _, mig := range all {
+		known[mig.Version] = mig
+	}
+
+	rolledBack := 0
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, v := range versions {
+			if rolledBack == steps {
+				break
+			}
+			mig, ok := known[v]
+			if !ok {
+				return fmt.Errorf("migration %d was applied by a newer version and cannot be rolled back here", v)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %04d_%s is irreversible (it has no down file)", mig.Version, mig.Name)
+			}
+			if err := run(conn, mig, mig.Down, false); err != nil {
+				return err
+			}
+			log.Printf("✅ Migration %04d_%s rolled back", mig.Version, mig.Name)
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// List reports every known or applied migration
+func List(db *sql.DB) ([]Status, error) {
+	all, err := All(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	err = withLock(db, func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range all {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if at, ok := done[mig.Version]; ok {
+				s.AppliedAt = &at
+				delete(done, mig.Version)
+			}
+			statuses = append(statuses, s)
+		}
+		for v, at := range done {
+			at := at
+			statuses = append(statuses, Status{Version: v, AppliedAt: &at, Unknown: true})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// Internal functions
+
+// withLock runs fn on one connection while holding the migration lock
+func withLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer conn.Close()
+
+	// SQLite serialises the migration transactions itself
+	if !sqlitedb.Is(db) {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+			return fmt.Errorf("cannot take migration lock: %v", err)
+		}
+		defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID)
+	}
+
+	_, err = conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			Version INT PRIMARY KEY,
+			Name VARCHAR(200) NOT NULL,
+			AppliedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT Version, AppliedAt FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	done := map[int]time.Time{}
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		done[v] = at
+	}
+	return done, rows.Err()
+}
+
+// run executes one migration file and records it, all in one transaction
+func run(conn *sql.Conn, mig Migration, script string, up bool) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	defer tx.Rollback()
+
+	direction := "up"
+	if !up {
+		direction = "down"
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s %s failed: %v", mig.Version, mig.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (Version, Name) VALUES ($1, $2)", mig.Version, mig.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE Version = $1", mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"main.go/sqlitedb"
+)
+
+var (
+	createTable = regexp.MustCompile(`(?is)CREATE\s+(?:UNLOGGED\s+)?TABLE\s+IF\s+NOT\s+EXISTS\s+(\w+)\s*\((.*?)\n\);`)
+	addColumn   = regexp.MustCompile(`(?i)ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+IF\s+NOT\s+EXISTS\s+(\w+)`)
+	createIndex = regexp.MustCompile(`(?i)CREATE\s+(?:UNIQUE\s+)?INDEX\s+IF\s+NOT\s+EXISTS\s+(\w+)\s+ON\s+(\w+)`)
+	// Table constraints, as opposed to column definitions
+	tableConstraint = regexp.MustCompile(`(?i)^(PRIMARY\s+KEY|UNIQUE|FOREIGN\s+KEY|CONSTRAINT|CHECK)\b`)
+)
+
+// postgresOnlyTables are the Postgres tables the SQLite baseline leaves out on purpose
+var postgresOnlyTables = map[string]bool{
+	// The shared rate limiter needs Postgres
+	"rate_limit_buckets": true,
+}
+
+// TestSQLiteSchemaMatchesPostgres applies the SQLite migrations and checks that they create the
+// tables, columns and indexes the Postgres migrations describe
+func TestSQLiteSchemaMatchesPostgres(t *testing.T) {
+	want, err := postgresSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlitedb.Open(filepath.Join(t.TempDir(), "parity.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	rows, err := db.Query(`SELECT type, name, tbl_name FROM sqlite_master
+		WHERE type IN ('table', 'index') AND name NOT LIKE 'sqlite_%' AND tbl_name <> 'schema_migrations'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for rows.Next() {
+		var kind, name, table string
+		if err := rows.Scan(&kind, &name, &table); err != nil {
+			t.Fatal(err)
+		}
+		if kind == "table" {
+			tables = append(tables, name)
+			got["table "+strings.ToLower(name)] = true
+		} else {
+			got[fmt.Sprintf("index %s on %s", strings.ToLower(name), strings.ToLower(table))] = true
+		}
+	}
+	rows.Close()
+	for _, table := range tables {
+		cols, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for cols.Next() {
+			var col string
+			if err := cols.Scan(&col); err != nil {
+				t.Fatal(err)
+			}
+			got[fmt.Sprintf("column %s.%s", strings.ToLower(table), strings.ToLower(col))] = true
+		}
+		cols.Close()
+	}
+
+	for _, item := range sortedKeys(want) {
+		if !got[item] {
+			t.Errorf("SQLite schema is missing %s", item)
+		}
+	}
+	for _, item := range sortedKeys(got) {
+		if !want[item] {
+			t.Errorf("SQLite schema has %s, which Postgres does not", item)
+		}
+	}
+}
+
+// postgresSchema lists the tables, columns and indexes created by the Postgres up migrations
+func postgresSchema() (map[string]bool, error) {
+	names, err := fs.Glob(files, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := map[string]bool{}
+	for _, name := range names {
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+		sql := stripComments(string(data))
+
+		for _, m := range createTable.FindAllStringSubmatch(sql, -1) {
+			table := strings.ToLower(m[1])
+			if postgresOnlyTables[table] {
+				continue
+			}
+			schema["table "+table] = true
+			for _, def := range splitTopLevel(m[2]) {
+				if def == "" || tableConstraint.MatchString(def) {
+					continue
+				}
+				schema[fmt.Sprintf("column %s.%s", table, strings.ToLower(strings.Fields(def)[0]))] = true
+			}
+		}
+		for _, m := range addColumn.FindAllStringSubmatch(sql, -1) {
+			schema[fmt.Sprintf("column %s.%s", strings.ToLower(m[1]), strings.ToLower(m[2]))] = true
+		}
+		for _, m := range createIndex.FindAllStringSubmatch(sql, -1) {
+			schema[fmt.Sprintf("index %s on %s", strings.ToLower(m[1]), strings.ToLower(m[2]))] = true
+		}
+	}
+	return schema, nil
+}
+
+func stripComments(sql string) string {
+	lines := strings.Split(sql, "\n")
+	for i, line := range lines {
+		if j := strings.Index(line, "--"); j >= 0 {
+			lines[i] = line[:j]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitTopLevel splits a table body on the commas outside parentheses
+func splitTopLevel(body string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range body {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(body[start:]))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
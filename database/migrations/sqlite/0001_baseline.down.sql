@@ -0,0 +1,31 @@
+-- Drops the whole schema, data included (triggers go with their tables)
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS policy_acceptances;
+DROP TABLE IF EXISTS policy_documents;
+DROP TABLE IF EXISTS account_deletions;
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS oidc_logins;
+DROP TABLE IF EXISTS login_lockouts;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS email_verification_sends;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS auth_sessions;
+DROP TABLE IF EXISTS voucher_redemptions;
+DROP TABLE IF EXISTS vouchers;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS price_tiers;
+DROP TABLE IF EXISTS price_bands;
+DROP TABLE IF EXISTS price_rates;
+DROP TABLE IF EXISTS push_subscriptions;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS courts;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,413 @@
+-- Baseline for SQLite, matching the Postgres baseline table for table. Differences:
+--   * SERIAL columns are INTEGER PRIMARY KEY AUTOINCREMENT and JSONB is TEXT
+--   * timestamps are UTC text in the layout sqlitedb writes, so they compare as text
+--   * trigger functions are inlined into each trigger
+--   * there is no rate_limit_buckets table; the shared rate limiter needs Postgres
+
+CREATE TABLE IF NOT EXISTS users (
+	UserID INTEGER PRIMARY KEY AUTOINCREMENT,
+	FirstName VARCHAR(100) NOT NULL,
+	LastName VARCHAR(100),
+	UserName VARCHAR(50) UNIQUE NOT NULL,
+	PasswordHash VARCHAR(255) NOT NULL,
+	Email VARCHAR(100) UNIQUE,
+	PhoneNumber VARCHAR(15),
+	StudentID VARCHAR(20) UNIQUE,
+	Role VARCHAR(20) DEFAULT 'Member' CHECK (Role IN ('Member', 'Staff', 'Coach', 'ClubManager', 'Admin')),
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	updated_at TIMESTAMP,
+	LineUserID VARCHAR(64),
+	PricingTier VARCHAR(20) NOT NULL DEFAULT 'Member' CHECK (PricingTier IN ('Member', 'Student', 'Guest')),
+	TokenVersion INT NOT NULL DEFAULT 0,
+	EmailVerifiedAt TIMESTAMP,
+	ProfilePicture VARCHAR(255),
+	DeletedAt TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS courts (
+	CourtID INTEGER PRIMARY KEY AUTOINCREMENT,
+	CourtName VARCHAR(100) NOT NULL,
+	SportType VARCHAR(50) NOT NULL,
+	CourtNumber INT NOT NULL,
+	Status VARCHAR(20) DEFAULT 'Available',
+	UNIQUE (SportType, CourtNumber)
+);
+
+CREATE TABLE IF NOT EXISTS bookings (
+	BookingID INTEGER PRIMARY KEY AUTOINCREMENT,
+	CourtID INT REFERENCES courts(CourtID) ON DELETE CASCADE,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	StartTime TIMESTAMP NOT NULL,
+	EndTime TIMESTAMP NOT NULL,
+	BookingStatus VARCHAR(20) DEFAULT 'Confirmed',
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	updated_at TIMESTAMP,
+	Price INT NOT NULL DEFAULT 0,
+	PriceBreakdown TEXT,
+	HoldExpiresAt TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	EventType VARCHAR(50) NOT NULL,
+	Channel VARCHAR(20) NOT NULL,
+	Enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	PRIMARY KEY (UserID, EventType, Channel)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+	NotificationID INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	EventType VARCHAR(50) NOT NULL,
+	Title VARCHAR(200) NOT NULL,
+	Message TEXT NOT NULL,
+	ReadAt TIMESTAMP,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+	SubscriptionID INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Endpoint TEXT UNIQUE NOT NULL,
+	P256dh VARCHAR(200) NOT NULL,
+	Auth VARCHAR(100) NOT NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS price_rates (
+	RateID INTEGER PRIMARY KEY AUTOINCREMENT,
+	SportType VARCHAR(50) NOT NULL,
+	CourtID INT REFERENCES courts(CourtID) ON DELETE CASCADE,
+	HourlyRate INT NOT NULL CHECK (HourlyRate >= 0)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_price_rates_target ON price_rates(SportType, COALESCE(CourtID, 0));
+
+CREATE TABLE IF NOT EXISTS price_bands (
+	BandID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Name VARCHAR(50) NOT NULL,
+	DayType VARCHAR(10) NOT NULL CHECK (DayType IN ('all', 'weekday', 'weekend')),
+	StartHour INT NOT NULL CHECK (StartHour BETWEEN 0 AND 23),
+	EndHour INT NOT NULL CHECK (EndHour BETWEEN 1 AND 24),
+	RatePercent INT NOT NULL CHECK (RatePercent >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS price_tiers (
+	Tier VARCHAR(20) PRIMARY KEY CHECK (Tier IN ('Member', 'Student', 'Guest')),
+	RatePercent INT NOT NULL CHECK (RatePercent >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS payments (
+	PaymentID INTEGER PRIMARY KEY AUTOINCREMENT,
+	BookingID INT REFERENCES bookings(BookingID) ON DELETE CASCADE NOT NULL,
+	Provider VARCHAR(20) NOT NULL,
+	Reference VARCHAR(64) UNIQUE NOT NULL,
+	Amount INT NOT NULL,
+	Status VARCHAR(20) NOT NULL DEFAULT 'Pending',
+	QRPayload TEXT NOT NULL,
+	ExpiresAt TIMESTAMP NOT NULL,
+	PaidAt TIMESTAMP,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+-- Wallet ledger (double-entry, append-only; amounts in satang)
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+	AccountID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Code VARCHAR(50) UNIQUE NOT NULL,
+	UserID INT UNIQUE REFERENCES users(UserID) ON DELETE RESTRICT,
+	Balance BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+	TxID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Kind VARCHAR(20) NOT NULL CHECK (Kind IN ('topup', 'booking', 'refund')),
+	BookingID INT,
+	Description VARCHAR(200) NOT NULL,
+	CreatedBy INT,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+	EntryID INTEGER PRIMARY KEY AUTOINCREMENT,
+	TxID INT REFERENCES ledger_transactions(TxID) NOT NULL,
+	AccountID INT REFERENCES ledger_accounts(AccountID) NOT NULL,
+	Amount BIGINT NOT NULL CHECK (Amount <> 0)
+);
+
+CREATE TRIGGER IF NOT EXISTS ledger_transactions_append_only_update
+BEFORE UPDATE ON ledger_transactions
+BEGIN
+	SELECT RAISE(ABORT, 'ledger is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS ledger_transactions_append_only_delete
+BEFORE DELETE ON ledger_transactions
+BEGIN
+	SELECT RAISE(ABORT, 'ledger is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS ledger_entries_append_only_update
+BEFORE UPDATE ON ledger_entries
+BEGIN
+	SELECT RAISE(ABORT, 'ledger is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS ledger_entries_append_only_delete
+BEFORE DELETE ON ledger_entries
+BEGIN
+	SELECT RAISE(ABORT, 'ledger is append-only');
+END;
+
+-- Promo codes; NULL restriction/limit columns mean "no restriction"
+CREATE TABLE IF NOT EXISTS vouchers (
+	VoucherID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Code VARCHAR(32) UNIQUE NOT NULL,
+	Description VARCHAR(200) NOT NULL DEFAULT '',
+	DiscountType VARCHAR(10) NOT NULL CHECK (DiscountType IN ('percent', 'fixed')),
+	PercentOff INT NOT NULL DEFAULT 0 CHECK (PercentOff BETWEEN 0 AND 100),
+	AmountOff INT NOT NULL DEFAULT 0 CHECK (AmountOff >= 0),
+	MaxDiscount INT CHECK (MaxDiscount >= 0),
+	ValidFrom TIMESTAMP NOT NULL,
+	ValidUntil TIMESTAMP NOT NULL,
+	GlobalLimit INT CHECK (GlobalLimit > 0),
+	PerUserLimit INT CHECK (PerUserLimit > 0),
+	SportType VARCHAR(50),
+	CourtID INT REFERENCES courts(CourtID) ON DELETE CASCADE,
+	StartHour INT CHECK (StartHour BETWEEN 0 AND 23),
+	EndHour INT CHECK (EndHour BETWEEN 1 AND 24),
+	Active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS voucher_redemptions (
+	RedemptionID INTEGER PRIMARY KEY AUTOINCREMENT,
+	VoucherID INT REFERENCES vouchers(VoucherID) ON DELETE RESTRICT NOT NULL,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	BookingID INT UNIQUE REFERENCES bookings(BookingID) ON DELETE CASCADE NOT NULL,
+	Discount INT NOT NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+-- Login sessions and rotating refresh tokens (only SHA-256 hashes are stored)
+CREATE TABLE IF NOT EXISTS auth_sessions (
+	SessionID VARCHAR(32) PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	TokenVersion INT NOT NULL,
+	IPAddress VARCHAR(45),
+	UserAgent VARCHAR(255),
+	RevokedAt TIMESTAMP,
+	RevokeReason VARCHAR(50),
+	LastUsedAt TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	MFA BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	TokenID INTEGER PRIMARY KEY AUTOINCREMENT,
+	SessionID VARCHAR(32) REFERENCES auth_sessions(SessionID) ON DELETE CASCADE NOT NULL,
+	TokenHash CHAR(64) UNIQUE NOT NULL,
+	ExpiresAt TIMESTAMP NOT NULL,
+	UsedAt TIMESTAMP,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+	ResetID INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	TokenHash CHAR(64) UNIQUE NOT NULL,
+	ExpiresAt TIMESTAMP NOT NULL,
+	UsedAt TIMESTAMP,
+	RequestIP VARCHAR(45),
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS email_verification_sends (
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+	AttemptID INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserName VARCHAR(50) NOT NULL,
+	IPAddress VARCHAR(45) NOT NULL,
+	Success BOOLEAN NOT NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+	LockoutID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Scope VARCHAR(10) NOT NULL CHECK (Scope IN ('user', 'ip')),
+	Subject VARCHAR(64) NOT NULL,
+	Failures INT NOT NULL,
+	LockedUntil TIMESTAMP NOT NULL,
+	UnlockedAt TIMESTAMP,
+	UnlockedBy INT REFERENCES users(UserID) ON DELETE SET NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+-- Single sign-on: logins in progress and identities linked to local users
+CREATE TABLE IF NOT EXISTS oidc_logins (
+	StateHash CHAR(64) PRIMARY KEY,
+	Nonce VARCHAR(64) NOT NULL,
+	CodeVerifier VARCHAR(128) NOT NULL,
+	ExpiresAt TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+	Issuer VARCHAR(255) NOT NULL,
+	Subject VARCHAR(255) NOT NULL,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Email VARCHAR(100),
+	LastLoginAt TIMESTAMP,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	PRIMARY KEY (Issuer, Subject)
+);
+
+-- Two-factor authentication (TOTP secrets are AES-GCM encrypted)
+CREATE TABLE IF NOT EXISTS user_mfa (
+	UserID INT PRIMARY KEY REFERENCES users(UserID) ON DELETE CASCADE,
+	Secret TEXT NOT NULL,
+	Enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	EnabledAt TIMESTAMP,
+	LastUsedStep BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+	CodeID INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	CodeHash CHAR(64) NOT NULL,
+	UsedAt TIMESTAMP,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+	ChallengeHash CHAR(64) PRIMARY KEY,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Attempts INT NOT NULL DEFAULT 0,
+	ExpiresAt TIMESTAMP NOT NULL
+);
+
+-- PDPA account deletion: requests wait out a grace period, then the user row is anonymised
+CREATE TABLE IF NOT EXISTS account_deletions (
+	RequestID INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	Reason VARCHAR(500),
+	RequestedAt TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	ScheduledFor TIMESTAMP NOT NULL,
+	CancelledAt TIMESTAMP,
+	CompletedAt TIMESTAMP
+);
+
+-- Versioned terms of service / privacy policy and who accepted which version
+CREATE TABLE IF NOT EXISTS policy_documents (
+	DocumentID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Kind VARCHAR(20) NOT NULL CHECK (Kind IN ('terms', 'privacy')),
+	Version VARCHAR(20) NOT NULL,
+	Title VARCHAR(200) NOT NULL,
+	Body TEXT NOT NULL,
+	PublishedAt TIMESTAMP,
+	PublishedBy INT REFERENCES users(UserID),
+	CreatedBy INT REFERENCES users(UserID),
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	UNIQUE (Kind, Version)
+);
+
+CREATE TABLE IF NOT EXISTS policy_acceptances (
+	UserID INT REFERENCES users(UserID) ON DELETE CASCADE NOT NULL,
+	DocumentID INT REFERENCES policy_documents(DocumentID) NOT NULL,
+	IPAddress VARCHAR(45),
+	AcceptedAt TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	PRIMARY KEY (UserID, DocumentID)
+);
+
+-- Role-based access control: staff roles and what each role may do (Admin may do everything)
+CREATE TABLE IF NOT EXISTS role_permissions (
+	Role VARCHAR(20) NOT NULL CHECK (Role IN ('Member', 'Staff', 'Coach', 'ClubManager')),
+	Permission VARCHAR(50) NOT NULL,
+	GrantedBy INT REFERENCES users(UserID) ON DELETE SET NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+	PRIMARY KEY (Role, Permission)
+);
+
+-- Keep updated_at current unless the statement set it itself
+CREATE TRIGGER IF NOT EXISTS update_users_modtime
+AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+	UPDATE users SET updated_at = now() WHERE UserID = NEW.UserID;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_bookings_modtime
+AFTER UPDATE ON bookings
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+	UPDATE bookings SET updated_at = now() WHERE BookingID = NEW.BookingID;
+END;
+
+-- Publish slot changes to the live availability streams (delivered by sqlitedb on commit)
+CREATE TRIGGER IF NOT EXISTS bookings_notify_slot_insert
+AFTER INSERT ON bookings
+BEGIN
+	SELECT pg_notify('slot_changes', json_object(
+		'op', 'INSERT',
+		'court_id', NEW.CourtID,
+		'sport_type', (SELECT SportType FROM courts WHERE CourtID = NEW.CourtID),
+		'start_time', strftime('%Y-%m-%dT%H:%M:%fZ', NEW.StartTime),
+		'end_time', strftime('%Y-%m-%dT%H:%M:%fZ', NEW.EndTime)
+	));
+END;
+
+-- Skips the nested update made by update_bookings_modtime so each change is published once
+CREATE TRIGGER IF NOT EXISTS bookings_notify_slot_update
+AFTER UPDATE ON bookings
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+	SELECT pg_notify('slot_changes', json_object(
+		'op', 'UPDATE',
+		'court_id', NEW.CourtID,
+		'sport_type', (SELECT SportType FROM courts WHERE CourtID = NEW.CourtID),
+		'start_time', strftime('%Y-%m-%dT%H:%M:%fZ', NEW.StartTime),
+		'end_time', strftime('%Y-%m-%dT%H:%M:%fZ', NEW.EndTime)
+	));
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookings_notify_slot_delete
+AFTER DELETE ON bookings
+BEGIN
+	SELECT pg_notify('slot_changes', json_object(
+		'op', 'DELETE',
+		'court_id', OLD.CourtID,
+		'sport_type', (SELECT SportType FROM courts WHERE CourtID = OLD.CourtID),
+		'start_time', strftime('%Y-%m-%dT%H:%M:%fZ', OLD.StartTime),
+		'end_time', strftime('%Y-%m-%dT%H:%M:%fZ', OLD.EndTime)
+	));
+END;
+
+CREATE TRIGGER IF NOT EXISTS courts_notify_slot_change
+AFTER UPDATE OF Status ON courts
+BEGIN
+	SELECT pg_notify('slot_changes', json_object(
+		'op', 'UPDATE',
+		'court_id', NEW.CourtID,
+		'sport_type', NEW.SportType
+	));
+END;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_court_time ON bookings(CourtID, StartTime, EndTime);
+CREATE INDEX IF NOT EXISTS idx_bookings_user ON bookings(UserID);
+CREATE INDEX IF NOT EXISTS idx_courts_sport ON courts(SportType);
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(UserID, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_bookings_pending ON bookings(HoldExpiresAt) WHERE BookingStatus = 'PendingPayment';
+CREATE INDEX IF NOT EXISTS idx_payments_booking ON payments(BookingID);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries(AccountID, TxID);
+CREATE INDEX IF NOT EXISTS idx_voucher_redemptions_voucher ON voucher_redemptions(VoucherID, UserID);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user ON auth_sessions(UserID);
+CREATE INDEX IF NOT EXISTS idx_email_verification_sends_user ON email_verification_sends(UserID, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(UserName, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(IPAddress, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_lockouts_subject ON login_lockouts(Scope, Subject, created_at);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(UserID);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(UserID, CodeHash);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_pending ON account_deletions(UserID) WHERE CancelledAt IS NULL AND CompletedAt IS NULL;
+CREATE INDEX IF NOT EXISTS idx_policy_documents_current ON policy_documents(Kind, PublishedAt DESC) WHERE PublishedAt IS NOT NULL;
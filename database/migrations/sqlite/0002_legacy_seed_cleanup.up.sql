@@ -0,0 +1,3 @@
+-- Nothing to clean up: SQLite databases were never created by docker/init.sql. The version
+-- exists so both dialects stay numbered alike.
+SELECT 1;
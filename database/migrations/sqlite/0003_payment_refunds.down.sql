@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_payments_status;
+UPDATE payments SET Status = 'RefundRequired' WHERE Status = 'Refunded';
+ALTER TABLE payments DROP COLUMN RefundedBy;
+ALTER TABLE payments DROP COLUMN RefundedAt;
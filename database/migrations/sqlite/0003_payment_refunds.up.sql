@@ -0,0 +1,7 @@
+-- Payments flagged RefundRequired are paid back outside the system; an admin then marks them
+-- Refunded, recording when and by whom. RefundedBy has no foreign key here because SQLite
+-- cannot drop a column that has one; users are anonymised rather than deleted anyway.
+ALTER TABLE payments ADD COLUMN RefundedAt TIMESTAMP;
+ALTER TABLE payments ADD COLUMN RefundedBy INT;
+
+CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(Status, PaymentID);
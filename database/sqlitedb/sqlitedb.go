@@ -0,0 +1,408 @@
+// Package sqlitedb opens an embedded SQLite database that can stand in for Postgres on a single
+// machine. The handlers' queries are written for Postgres, so the driver registered here covers
+// the parts of that dialect they use:
+//
+//   - NOW(), GREATEST and LEAST are provided as SQL functions; NOW() reads the clock set
+//     with SetNow
+//   - every transaction starts with BEGIN IMMEDIATE, so writers are serialised by the database
+//     lock; FOR UPDATE clauses are dropped and pg_advisory_xact_lock is a no-op
+//   - pg_notify queues payloads that are handed to the notify handler when the writing
+//     transaction commits, and dropped when it rolls back
+//   - timestamps are stored as UTC text that sorts chronologically and are read back as local
+//     time; BOOLEAN columns are read back as bools
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// DriverName is the database/sql driver registered by this package
+const DriverName = "sqlite-pg"
+
+// timeFormat is how timestamps are stored; the schema's column defaults use the same layout
+// so values written by SQL and by Go compare correctly as text
+const timeFormat = "2006-01-02 15:04:05.000000+00:00"
+
+// timeLayouts are tried when reading timestamps; the second one is CURRENT_TIMESTAMP's
+var timeLayouts = []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05"}
+
+var (
+	timestampText = regexp.MustCompile(`^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d\.\d{6}\+00:00$`)
+	forUpdate     = regexp.MustCompile(`(?i)\s+FOR\s+UPDATE(\s+OF\s+\w+(\s*,\s*\w+)*)?`)
+
+	// rewritten caches query text after dialect rewrites
+	rewritten sync.Map
+)
+
+// Notifications raised with pg_notify by the transaction currently writing. SQLite lets one
+// connection write at a time, so the queue only ever holds that transaction's payloads.
+var notify struct {
+	mu      sync.Mutex
+	pending []notification
+	handler func(channel, payload string)
+}
+
+type notification struct {
+	channel string
+	payload string
+}
+
+// clock is the time source of the SQL now() function
+var clock struct {
+	mu  sync.RWMutex
+	now func() time.Time
+}
+
+func init() {
+	d := &sqlite.Driver{}
+	d.MustRegisterScalarFunction("now", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return currentTime().UTC().Format(timeFormat), nil
+	})
+	d.MustRegisterScalarFunction("greatest", -1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return pick(args, 1)
+	})
+	d.MustRegisterScalarFunction("least", -1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return pick(args, -1)
+	})
+	d.MustRegisterScalarFunction("hashtext", 1, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return int64(0), nil
+	})
+	d.MustRegisterScalarFunction("pg_advisory_xact_lock", 1, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return nil, nil
+	})
+	d.MustRegisterScalarFunction("pg_notify", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		channel, _ := args[0].(string)
+		payload, _ := args[1].(string)
+		notify.mu.Lock()
+		notify.pending = append(notify.pending, notification{channel, payload})
+		notify.mu.Unlock()
+		return nil, nil
+	})
+	d.RegisterConnectionHook(func(c sqlite.ExecQuerierContext, _ string) error {
+		hooks, ok := c.(sqlite.HookRegisterer)
+		if !ok {
+			return fmt.Errorf("sqlite connection does not support hooks")
+		}
+		hooks.RegisterCommitHook(func() int32 {
+			deliverPending()
+			return 0
+		})
+		hooks.RegisterRollbackHook(func() {
+			notify.mu.Lock()
+			notify.pending = nil
+			notify.mu.Unlock()
+		})
+		return nil
+	})
+
+	sql.Register(DriverName, &pgDriver{d})
+}
+
+// SetNotifyHandler sets the function that receives pg_notify payloads once their transaction
+// has committed. It is called on its own goroutine and must not block for long.
+func SetNotifyHandler(fn func(channel, payload string)) {
+	notify.mu.Lock()
+	notify.handler = fn
+	notify.mu.Unlock()
+}
+
+// SetNow sets the time source of the SQL now() function, so tests can move it along with
+// their own clock (nil means time.Now)
+func SetNow(fn func() time.Time) {
+	clock.mu.Lock()
+	clock.now = fn
+	clock.mu.Unlock()
+}
+
+// Open opens (creating if needed) the database file at path
+func Open(path string) (*sql.DB, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	dsn := "file:" + path +
+		"?_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+	return sql.Open(DriverName, dsn)
+}
+
+// Is reports whether db was opened with this package
+func Is(db *sql.DB) bool {
+	_, ok := db.Driver().(*pgDriver)
+	return ok
+}
+
+// Internal functions
+
+// currentTime returns the time according to the clock set with SetNow
+func currentTime() time.Time {
+	clock.mu.RLock()
+	defer clock.mu.RUnlock()
+	if clock.now == nil {
+		return time.Now()
+	}
+	return clock.now()
+}
+
+// deliverPending runs inside the commit, while this connection still holds the write lock
+func deliverPending() {
+	notify.mu.Lock()
+	pending, handler := notify.pending, notify.handler
+	notify.pending = nil
+	notify.mu.Unlock()
+
+	if handler == nil || len(pending) == 0 {
+		return
+	}
+	go func() {
+		for _, n := range pending {
+			handler(n.channel, n.payload)
+		}
+	}()
+}
+
+// pick returns the largest (sign 1) or smallest (sign -1) non-NULL argument
+func pick(args []driver.Value, sign int) (driver.Value, error) {
+	var best driver.Value
+	for _, v := range args {
+		if v == nil {
+			continue
+		}
+		if best == nil {
+			best = v
+			continue
+		}
+		c, err := compare(v, best)
+		if err != nil {
+			return nil, err
+		}
+		if c*sign > 0 {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+func compare(a, b driver.Value) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, y), nil
+		case float64:
+			return cmpOrdered(float64(x), y), nil
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, float64(y)), nil
+		case float64:
+			return cmpOrdered(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func rewrite(query string) string {
+	if q, ok := rewritten.Load(query); ok {
+		return q.(string)
+	}
+	q := forUpdate.ReplaceAllString(query, "")
+	rewritten.Store(query, q)
+	return q
+}
+
+func convertArgs(args []driver.NamedValue) []driver.NamedValue {
+	for i := range args {
+		if t, ok := args[i].Value.(time.Time); ok {
+			args[i].Value = t.UTC().Format(timeFormat)
+		}
+	}
+	return args
+}
+
+// Column kinds that need converting on the way out
+const (
+	plainColumn = iota
+	boolColumn
+	timeColumn
+)
+
+func convertValue(v driver.Value, kind int) driver.Value {
+	switch x := v.(type) {
+	case time.Time:
+		return x.In(time.Local)
+	case string:
+		if kind == timeColumn || timestampText.MatchString(x) {
+			for _, layout := range timeLayouts {
+				if t, err := time.Parse(layout, x); err == nil {
+					return t.In(time.Local)
+				}
+			}
+		}
+	case int64:
+		if kind == boolColumn {
+			return x != 0
+		}
+	}
+	return v
+}
+
+// pgDriver wraps the SQLite driver with the rewrites described in the package comment
+type pgDriver struct {
+	inner *sqlite.Driver
+}
+
+func (d *pgDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.inner.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{c}, nil
+}
+
+type conn struct {
+	inner driver.Conn
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	s, err := c.inner.(driver.ConnPrepareContext).PrepareContext(ctx, rewrite(query))
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{s}, nil
+}
+
+func (c *conn) Close() error { return c.inner.Close() }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.inner.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.inner.(driver.ExecerContext).ExecContext(ctx, rewrite(query), convertArgs(args))
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r, err := c.inner.(driver.QueryerContext).QueryContext(ctx, rewrite(query), convertArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	return newRows(r), nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.inner.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.inner.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+type stmt struct {
+	inner driver.Stmt
+}
+
+func (s *stmt) Close() error  { return s.inner.Close() }
+func (s *stmt) NumInput() int { return s.inner.NumInput() }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.inner.(driver.StmtExecContext).ExecContext(ctx, convertArgs(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	r, err := s.inner.(driver.StmtQueryContext).QueryContext(ctx, convertArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	return newRows(r), nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type rows struct {
+	inner driver.Rows
+	kinds []int
+}
+
+func newRows(r driver.Rows) *rows {
+	res := &rows{inner: r, kinds: make([]int, len(r.Columns()))}
+	if typed, ok := r.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		for i := range res.kinds {
+			switch decl := strings.ToUpper(typed.ColumnTypeDatabaseTypeName(i)); {
+			case decl == "BOOLEAN":
+				res.kinds[i] = boolColumn
+			case strings.HasPrefix(decl, "TIMESTAMP"):
+				res.kinds[i] = timeColumn
+			}
+		}
+	}
+	return res
+}
+
+func (r *rows) Columns() []string { return r.inner.Columns() }
+func (r *rows) Close() error      { return r.inner.Close() }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if err := r.inner.Next(dest); err != nil {
+		return err
+	}
+	for i := range dest {
+		dest[i] = convertValue(dest[i], r.kinds[i])
+	}
+	return nil
+}
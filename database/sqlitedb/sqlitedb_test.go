@@ -0,0 +1,27 @@
+package sqlitedb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNowFollowsSetNow(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "now.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	want := time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+	SetNow(func() time.Time { return want })
+	t.Cleanup(func() { SetNow(nil) })
+
+	var got time.Time
+	if err := db.QueryRow("SELECT NOW()").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("NOW() = %s, want %s", got, want)
+	}
+}